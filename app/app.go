@@ -1,39 +1,70 @@
 package app
 
 import (
+	"database/sql"
 	"efficient-api/domain"
+	"efficient-api/services"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"log"
-	"os"
+	"net/http"
 )
 
-var (
-	router = gin.Default()
-)
-
-func init() {
-	//loads values from .env into the system
-	if err := godotenv.Load(); err != nil {
-		log.Print("sad .env file found")
-	}
+//Deps are what the app is built on. The ones left nil are built from the Config.
+type Deps struct {
+	Repository domain.MessageRepository
+	Service    services.MessageService
 }
 
-func StartApp() {
-
-	dbdriver := os.Getenv("DBDRIVER")
-	username := os.Getenv("USERNAME")
-	password := os.Getenv("PASSWORD")
-	host := os.Getenv("HOST")
-	database := os.Getenv("DATABASE")
-	port := os.Getenv("PORT")
-
-	domain.MessageRepo.Initialize(dbdriver, username, password, port, host, database)
-	fmt.Println("DATABASE STARTED")
-
-	routes()
+//ServiceOptions are the options of the service that cfg sets: the message rules, the quotas and the delete policy
+func ServiceOptions(cfg Config) []services.Option {
+	return []services.Option{services.WithRules(cfg.Rules), services.WithQuotas(cfg.Quotas), services.WithDeletePolicy(cfg.OnDelete)}
+}
 
-	router.Run(":8080")
+//NewRepository connects to the database of cfg, and its replicas, and adds retries, a circuit breaker and a cache on top
+func NewRepository(cfg Config) domain.MessageRepository {
+	var replicas []*sql.DB
+	for _, addr := range cfg.ReplicaHosts {
+		replica, err := sql.Open(cfg.DBDriver, fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local", cfg.DBUser, cfg.DBPassword, addr, cfg.DBName))
+		if err != nil {
+			log.Fatal("This is the error connecting to the replica:", err)
+		}
+		replicas = append(replicas, replica)
+	}
+	settings := domain.DefaultReplicaSettings()
+	if cfg.ReplicaCheckInterval > 0 {
+		settings.CheckInterval = cfg.ReplicaCheckInterval
+	}
+	if cfg.StickyWindow > 0 {
+		settings.StickyWindow = cfg.StickyWindow
+	}
+	repo, _ := domain.Connect(cfg.DBDriver, cfg.DBUser, cfg.DBPassword, cfg.DBPort, cfg.DBHost, cfg.DBName, settings, replicas...)
+	//a short failover of the database should not fail the requests that were running
+	repo = domain.NewRetryingRepository(repo, domain.DefaultRetryPolicy())
+	//when the database is down for longer than that, fail fast instead of piling up requests.
+	//The breaker goes outside the retries, so that one request counts once and an open breaker is not retried.
+	breaker := domain.DefaultBreakerSettings()
+	if cfg.BreakerFailureRatio > 0 {
+		breaker.FailureRatio = cfg.BreakerFailureRatio
+	}
+	repo = domain.NewCircuitBreaker(repo, breaker)
+	//hot messages are served from memory. A client that just wrote skips the cache, so it reads its writes from the primary.
+	if cfg.CacheSize > 0 {
+		repo = domain.NewCachingRepository(repo, domain.NewLRUCache(cfg.CacheSize, cfg.CacheTTL))
+	}
+	return repo
 }
 
+//New returns the handler serving the REST and GraphQL apis. Every call builds its own router,
+//so tests can run several apps side by side.
+func New(cfg Config, deps Deps) http.Handler {
+	if deps.Repository == nil {
+		deps.Repository = NewRepository(cfg)
+	}
+	if deps.Service == nil {
+		deps.Service = services.NewMessagesService(deps.Repository, ServiceOptions(cfg)...)
+	}
+	router := gin.Default()
+	routes(router, cfg, deps)
+	return router
+}
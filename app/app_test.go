@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//The rules and the error format belong to each app, so two apps with different settings can run side by side
+func TestNew_Settings_Per_App(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	strict := Config{
+		Problems: error_utils.Problems{ByDefault: true, TypeBaseURL: "https://errors.example.com"},
+		Rules:    domain.DefaultRules(),
+	}
+	strict.Rules.Checkers = []domain.Checker{domain.Blocklist("darn")}
+	apps := map[string]http.Handler{
+		"strict":  New(strict, Deps{Repository: domain.NewMemoryRepository()}),
+		"default": New(Config{}, Deps{Repository: domain.NewMemoryRepository()}),
+	}
+
+	post := func(app string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(`{"title": "darn", "body": "the body"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		apps[app].ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("strict")
+	assert.EqualValues(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.EqualValues(t, error_utils.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"type":"https://errors.example.com/invalid_request"`)
+	assert.Contains(t, rr.Body.String(), error_utils.CodeTitleBlocked)
+
+	rr = post("default")
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+}
+
+//Without a secret, the tenant header is only trusted once TRUST_TENANT_HEADER says so
+func TestConfigFromEnv_Tenant_Header_Not_Trusted_By_Default(t *testing.T) {
+	t.Setenv("TENANT_JWT_SECRET", "")
+	t.Setenv("TRUST_TENANT_HEADER", "")
+	assert.False(t, ConfigFromEnv().TenantAuth.TrustHeader)
+
+	t.Setenv("TRUST_TENANT_HEADER", "true")
+	assert.True(t, ConfigFromEnv().TenantAuth.TrustHeader)
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"efficient-api/domain"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	//loads values from .env into the system
+	if err := godotenv.Load(); err != nil {
+		log.Print("sad .env file found")
+	}
+}
+
+//Config is how the app is set up. ConfigFromEnv reads it from the environment, see .env.example.
+type Config struct {
+	DBDriver   string
+	DBUser     string
+	DBPassword string
+	DBHost     string
+	DBPort     string
+	DBName     string
+	//Reads go to these replicas, given as host:port. They use the credentials of the primary.
+	ReplicaHosts []string
+	//How often a replica is pinged, and how long a client reads from the primary after a write. 0 is the default of domain.DefaultReplicaSettings.
+	ReplicaCheckInterval time.Duration
+	StickyWindow         time.Duration
+
+	HTTPAddr string
+	GRPCPort string
+
+	//How the errors are rendered: whether clients that do not say which format they accept get problem documents, and their types
+	Problems error_utils.Problems
+	//The rules messages are validated against. Rules with no lengths, as in a zero Config, are domain.DefaultRules.
+	Rules domain.Rules
+
+	BreakerFailureRatio float64
+	//CacheSize 0 turns the cache off
+	CacheSize int
+	CacheTTL  time.Duration
+
+	//With a secret, every request carries a token naming its tenant; without one, the X-Tenant-Id header
+	//is only trusted when TrustHeader is set, and else every request acts for domain.DefaultTenant
+	TenantAuth auth_utils.TenantAuth
+	//How many messages each tenant can hold
+	Quotas domain.Quotas
+	//What deleting a message does to its replies, one of domain.OnDeleteRestrict, OnDeleteCascade or OnDeleteDetach
+	OnDelete string
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		DBDriver:   os.Getenv("DBDRIVER"),
+		DBUser:     os.Getenv("USERNAME"),
+		DBPassword: os.Getenv("PASSWORD"),
+		DBHost:     os.Getenv("HOST"),
+		DBPort:     os.Getenv("PORT"),
+		DBName:     os.Getenv("DATABASE"),
+		HTTPAddr:   ":8080",
+		GRPCPort:   os.Getenv("GRPC_PORT"),
+		Problems: error_utils.Problems{
+			ByDefault:   os.Getenv("ERROR_FORMAT") == "problem",
+			TypeBaseURL: os.Getenv("PROBLEM_TYPE_BASE_URL"),
+		},
+		Rules:     domain.DefaultRules(),
+		CacheSize: 1000,
+		CacheTTL:  30 * time.Second,
+		OnDelete:  domain.OnDeleteRestrict,
+	}
+	if cfg.GRPCPort == "" {
+		cfg.GRPCPort = "9090"
+	}
+	if hosts := os.Getenv("REPLICA_HOSTS"); hosts != "" {
+		for _, host := range strings.Split(hosts, ",") {
+			cfg.ReplicaHosts = append(cfg.ReplicaHosts, strings.TrimSpace(host))
+		}
+	}
+	if interval, err := time.ParseDuration(os.Getenv("REPLICA_CHECK_INTERVAL")); err == nil && interval > 0 {
+		cfg.ReplicaCheckInterval = interval
+	}
+	if window, err := time.ParseDuration(os.Getenv("STICKY_WINDOW")); err == nil && window > 0 {
+		cfg.StickyWindow = window
+	}
+	if blocklist := os.Getenv("MESSAGE_BLOCKLIST"); blocklist != "" {
+		//words that are not allowed in titles and bodies
+		cfg.Rules.Checkers = append(cfg.Rules.Checkers, domain.Blocklist(strings.Split(blocklist, ",")...))
+	}
+	if ratio, err := strconv.ParseFloat(os.Getenv("BREAKER_FAILURE_RATIO"), 64); err == nil && ratio > 0 && ratio <= 1 {
+		cfg.BreakerFailureRatio = ratio
+	}
+	if size, err := strconv.Atoi(os.Getenv("CACHE_SIZE")); err == nil {
+		cfg.CacheSize = size
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_TTL")); err == nil {
+		cfg.CacheTTL = ttl
+	}
+	if secret := os.Getenv("TENANT_JWT_SECRET"); secret != "" {
+		cfg.TenantAuth.Secret = []byte(secret)
+	}
+	if trust, err := strconv.ParseBool(os.Getenv("TRUST_TENANT_HEADER")); err == nil {
+		cfg.TenantAuth.TrustHeader = trust
+	}
+	if quota, err := strconv.Atoi(os.Getenv("TENANT_QUOTA")); err == nil {
+		cfg.Quotas.Default = quota
+	}
+	//TENANT_QUOTAS overrides the quota of some tenants, e.g. "acme=10000,globex=500"
+	if quotas := os.Getenv("TENANT_QUOTAS"); quotas != "" {
+		cfg.Quotas.Tenants = make(map[string]int)
+		for _, pair := range strings.Split(quotas, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if quota, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				cfg.Quotas.Tenants[strings.TrimSpace(parts[0])] = quota
+			}
+		}
+	}
+	if policy := os.Getenv("DELETE_REPLIES"); domain.ValidOnDelete(policy) {
+		cfg.OnDelete = policy
+	}
+	return cfg
+}
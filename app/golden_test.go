@@ -0,0 +1,22 @@
+package app
+
+import (
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/auth_utils"
+	"flag"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/golden with the responses received")
+
+//Every request file of testdata/golden is a scenario, adding one needs no Go code. Run with -update to write the golden files.
+func TestAPI_Golden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	messagestest.RunGolden(t, "testdata/golden", func(repo domain.MessageRepository) http.Handler {
+		//the scenarios name their tenant in the header
+		return New(Config{TenantAuth: auth_utils.TenantAuth{TrustHeader: true}}, Deps{Repository: repo})
+	}, messagestest.Update(*update))
+}
@@ -1,11 +1,44 @@
 package app
 
-import "efficient-api/controllers"
-
-func routes() {
-	router.GET("/messages/:message_id", controllers.GetMessage)
-	router.GET("/messages", controllers.GetAllMessages)
-	router.POST("/messages", controllers.CreateMessage)
-	router.PUT("/messages/:message_id", controllers.UpdateMessage)
-	router.DELETE("/messages/:message_id", controllers.DeleteMessage)
+import (
+	"efficient-api/controllers"
+	"efficient-api/gql"
+	"efficient-api/openapi"
+	"expvar"
+	"github.com/gin-gonic/gin"
+)
+
+func routes(router *gin.Engine, cfg Config, deps Deps) {
+	//tells clients apart, so that each reads its own writes when reads go to replicas
+	router.Use(controllers.Session)
+
+	//the apis only see the messages of the tenant of the request
+	api := router.Group("/", controllers.Tenant(cfg.TenantAuth, cfg.Problems))
+
+	messages := controllers.NewMessagesController(deps.Service, cfg.Problems)
+	api.GET("/messages/:message_id", messages.GetMessage)
+	api.GET("/messages", messages.GetAllMessages)
+	api.POST("/messages", messages.CreateMessage)
+	api.PUT("/messages/:message_id", messages.UpdateMessage)
+	api.DELETE("/messages/:message_id", messages.DeleteMessage)
+	api.GET("/messages/:message_id/replies", messages.ListReplies)
+	api.GET("/messages/:message_id/thread", messages.GetThread)
+	api.GET("/messages/export", messages.ExportMessages)
+	api.POST("/messages/import", messages.ImportMessages)
+
+	tags := controllers.NewTagsController(deps.Service, cfg.Problems)
+	api.GET("/tags", tags.ListTags)
+	api.PUT("/tags/:tag", tags.RenameTag)
+	api.POST("/tags/merge", tags.MergeTags)
+
+	api.POST("/graphql", gql.NewHandler(deps.Service, cfg.Problems))
+
+	router.GET("/openapi.json", openapi.SpecHandler)
+	router.GET("/docs", openapi.UIHandler)
+	router.GET("/docs/assets/*filepath", openapi.AssetsHandler)
+
+	router.GET("/ready", controllers.NewHealthController(deps.Repository).Ready)
+
+	//metrics, e.g. the retries of the database calls
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
 }
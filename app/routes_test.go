@@ -0,0 +1,44 @@
+package app
+
+import (
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+//These routes serve the documentation itself or are meant for operators, so they are not part of it
+var undocumentedRoutes = map[string]bool{
+	"GET /openapi.json":          true,
+	"GET /docs":                  true,
+	"GET /docs/assets/*filepath": true,
+	"GET /debug/vars":            true,
+	"GET /ready":                 true,
+}
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+//When a route is added to or removed from routes() without updating the OpenAPI document (or the other way around)
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	repo := domain.NewMessageRepository(nil)
+	routes(router, Config{}, Deps{Repository: repo, Service: services.NewMessagesService(repo)})
+
+	registered := make([]string, 0)
+	for _, route := range router.Routes() {
+		op := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		if !undocumentedRoutes[op] {
+			registered = append(registered, op)
+		}
+	}
+	sort.Strings(registered)
+
+	documented, err := openapi.Operations()
+	assert.Nil(t, err)
+	assert.EqualValues(t, documented, registered)
+}
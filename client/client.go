@@ -0,0 +1,346 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryWait = 100 * time.Millisecond
+	defaultPageSize  = domain.DefaultListLimit
+)
+
+//Client talks to the messages REST api. Every method returns the api's error as an error_utils.MessageErr,
+//so callers can switch on Status() exactly like the server code does.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	tenant     string
+	retries    int
+	retryWait  time.Duration
+}
+
+type Option func(*Client)
+
+//WithHTTPClient replaces http.DefaultClient, eg. to set timeouts or a custom transport
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+//WithToken sends the token as a bearer token on every request
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+//WithTenant sends the tenant in the X-Tenant-Id header on every request. A server requiring tokens
+//takes the tenant from the token instead, and refuses a header naming another one. A server without tokens
+//only accepts a tenant other than the default one when it trusts the header.
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.tenant = tenant
+	}
+}
+
+//WithRetries retries idempotent requests (GET, PUT and DELETE) up to n times when the server could not be reached
+//or answered with a 5xx, waiting wait, then twice as long, and so on between attempts. Creates are never retried.
+func WithRetries(n int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.retryWait = wait
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retryWait:  defaultRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) Get(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	var msg domain.Message
+	if err := c.do(ctx, http.MethodGet, "/messages/"+strconv.FormatInt(msgId, 10), nil, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+//GetAll returns every message in one response. Prefer Iterate for large tables.
+func (c *Client) GetAll(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
+	var msgs []domain.Message
+	if err := c.do(ctx, http.MethodGet, "/messages", nil, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+//List returns one page of messages. An empty page means there are no more messages.
+func (c *Client) List(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	query := url.Values{}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("after", strconv.FormatInt(opts.AfterId, 10))
+	if opts.Title != "" {
+		query.Set("title", opts.Title)
+	}
+	for _, tag := range opts.Tags {
+		query.Add("tag", tag)
+	}
+	if opts.Match != "" {
+		query.Set("match", opts.Match)
+	}
+	var msgs []domain.Message
+	if err := c.do(ctx, http.MethodGet, "/messages?"+query.Encode(), nil, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+//Replies returns one page of the direct replies of the message. An empty page means there are no more replies.
+func (c *Client) Replies(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	query := url.Values{}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("after", strconv.FormatInt(opts.AfterId, 10))
+	var msgs []domain.Message
+	if err := c.do(ctx, http.MethodGet, "/messages/"+strconv.FormatInt(msgId, 10)+"/replies?"+query.Encode(), nil, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+//Thread returns the message with its replies nested, down to depth levels below it. A depth of 0 leaves it to the server.
+func (c *Client) Thread(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr) {
+	path := "/messages/" + strconv.FormatInt(msgId, 10) + "/thread"
+	if depth > 0 {
+		path += "?depth=" + strconv.Itoa(depth)
+	}
+	var thread domain.Thread
+	if err := c.do(ctx, http.MethodGet, path, nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (c *Client) Create(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	var created domain.Message
+	if err := c.do(ctx, http.MethodPost, "/messages", msg, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) Update(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	var updated domain.Message
+	if err := c.do(ctx, http.MethodPut, "/messages/"+strconv.FormatInt(msg.Id, 10), msg, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	return c.do(ctx, http.MethodDelete, "/messages/"+strconv.FormatInt(msgId, 10), nil, nil)
+}
+
+//Tags returns the tags carried by the messages, with the number of messages carrying each
+func (c *Client) Tags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	var tags []domain.TagCount
+	if err := c.do(ctx, http.MethodGet, "/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+//RenameTag renames the tag on every message carrying it. Like any PUT it is retried, so a rename whose response
+//was lost comes back as a 404 once it is tried again.
+func (c *Client) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	return c.do(ctx, http.MethodPut, "/tags/"+url.PathEscape(from), map[string]string{"name": to}, nil)
+}
+
+//MergeTags replaces the tags from with the tag into on every message carrying them
+func (c *Client) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	body := map[string]interface{}{"from": from, "into": into}
+	return c.do(ctx, http.MethodPost, "/tags/merge", body, nil)
+}
+
+//Import sends the messages read from r, written in format (jsonl or csv), to be saved by the api in one request.
+//Replies and tags are kept as in an export. onDuplicate is one of domain.OnDuplicateSkip, OnDuplicateOverwrite
+//or OnDuplicateFail, empty leaves it to the api. The report tells what became of every line.
+func (c *Client) Import(ctx context.Context, r io.Reader, format, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("cannot read the messages to import: %s", err.Error()))
+	}
+	query := url.Values{}
+	query.Set("format", format)
+	if onDuplicate != "" {
+		query.Set("on_duplicate", onDuplicate)
+	}
+	var report domain.ImportReport
+	if err := c.do(ctx, http.MethodPost, "/messages/import?"+query.Encode(), upload{contentType: message_formats.ContentType(format), body: b}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+//upload is a request body sent as it is, rather than encoded as json
+type upload struct {
+	contentType string
+	body        []byte
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error_utils.MessageErr {
+	var payload []byte
+	contentType := "application/json"
+	if u, ok := in.(upload); ok {
+		payload, contentType = u.body, u.contentType
+	} else if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("invalid request body: %s", err.Error()))
+		}
+	}
+	attempts := 1
+	if method != http.MethodPost {
+		attempts += c.retries
+	}
+
+	var lastErr error_utils.MessageErr
+	wait := c.retryWait
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return contextError(ctx)
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		var retry bool
+		retry, lastErr = c.send(ctx, method, path, payload, contentType, out)
+		if !retry {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+//send makes a single request. It reports whether the failure, if any, is worth retrying.
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, contentType string, out interface{}) (bool, error_utils.MessageErr) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return false, error_utils.NewBadRequestError(fmt.Sprintf("invalid request: %s", err.Error()))
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-Id", c.tenant)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, contextError(ctx)
+		}
+		return true, error_utils.NewInternalServerError(fmt.Sprintf("error when calling the messages api: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, contextError(ctx)
+		}
+		return true, error_utils.NewInternalServerError(fmt.Sprintf("error when reading the messages api response: %s", err.Error()))
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode >= http.StatusInternalServerError, decodeError(resp.StatusCode, respBody)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return false, error_utils.NewInternalServerError(fmt.Sprintf("invalid response from the messages api: %s", err.Error()))
+		}
+	}
+	return false, nil
+}
+
+//decodeError turns an error response into a MessageErr. Bodies that are not a message error,
+//eg. from a proxy in front of the api, are reported with the response status.
+func decodeError(status int, body []byte) error_utils.MessageErr {
+	if apiErr, err := error_utils.NewApiErrFromBytes(body); err == nil && apiErr.Status() != 0 {
+		return apiErr
+	}
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	switch status {
+	case http.StatusNotFound:
+		return error_utils.NewNotFoundError(message)
+	case http.StatusBadRequest:
+		return error_utils.NewBadRequestError(message)
+	case http.StatusUnauthorized:
+		return error_utils.NewUnauthorizedError(message)
+	case http.StatusForbidden:
+		return error_utils.NewForbiddenError(message)
+	case http.StatusRequestEntityTooLarge:
+		return error_utils.NewRequestTooLargeError(message)
+	case http.StatusUnprocessableEntity:
+		return error_utils.NewUnprocessibleEntityError(message)
+	case http.StatusConflict:
+		return error_utils.NewConflictError(message)
+	case http.StatusServiceUnavailable:
+		return error_utils.NewServiceUnavailableError(message)
+	case http.StatusInternalServerError:
+		return error_utils.NewInternalServerError(message)
+	}
+	return error_utils.NewStatusError(status, message)
+}
+
+//contextError reports a call given up because ctx was canceled or its deadline passed. The api did not fail,
+//so it is a canceled error rather than a server error, and it is not retried.
+func contextError(ctx context.Context) error_utils.MessageErr {
+	err := error_utils.NewCanceledError(fmt.Sprintf("the call to the messages api was given up: %s", ctx.Err().Error()))
+	if ctx.Err() == context.DeadlineExceeded {
+		return error_utils.WithCode(err, error_utils.CodeDeadlineExceeded)
+	}
+	return error_utils.WithCode(err, error_utils.CodeCanceled)
+}
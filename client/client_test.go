@@ -0,0 +1,380 @@
+package client
+
+import (
+	"context"
+	"efficient-api/controllers"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+//newServer serves the real controllers, backed by the mocked service
+func newServer(sm *messagestest.Service) *messagestest.Server {
+	mc := controllers.NewMessagesController(sm, error_utils.Problems{})
+	r := gin.New()
+	r.GET("/messages/:message_id", mc.GetMessage)
+	r.GET("/messages", mc.GetAllMessages)
+	r.POST("/messages", mc.CreateMessage)
+	r.PUT("/messages/:message_id", mc.UpdateMessage)
+	r.DELETE("/messages/:message_id", mc.DeleteMessage)
+	r.POST("/messages/import", mc.ImportMessages)
+	r.GET("/messages/:message_id/replies", mc.ListReplies)
+	r.GET("/messages/:message_id/thread", mc.GetThread)
+	tc := controllers.NewTagsController(sm, error_utils.Problems{})
+	r.GET("/tags", tc.ListTags)
+	r.PUT("/tags/:tag", tc.RenameTag)
+	r.POST("/tags/merge", tc.MergeTags)
+	return messagestest.NewServer(r)
+}
+
+func TestClient_Get_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId, Title: "the title", Body: "the body"}, nil
+	}
+	msg, err := New(srv.URL).Get(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "the title", msg.Title)
+	assert.EqualValues(t, "the body", msg.Body)
+}
+
+func TestClient_Get_Not_Found(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("message not found")
+	}
+	msg, err := New(srv.URL).Get(context.Background(), 1)
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+	assert.EqualValues(t, "message not found", err.Message())
+	assert.EqualValues(t, "not_found", err.Error())
+}
+
+func TestClient_Create_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		message.Id = 1
+		return message, nil
+	}
+	msg, err := New(srv.URL).Create(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "the title", msg.Title)
+}
+
+func TestClient_Create_Invalid_Request(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid title")
+	}
+	msg, err := New(srv.URL).Create(context.Background(), &domain.Message{Body: "the body"})
+	assert.Nil(t, msg)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, "Please enter a valid title", err.Message())
+}
+
+func TestClient_Update_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return message, nil
+	}
+	msg, err := New(srv.URL).Update(context.Background(), &domain.Message{Id: 1, Title: "update title", Body: "update body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "update title", msg.Title)
+}
+
+func TestClient_Delete_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.DeleteMessageFunc = func(ctx context.Context, msgId int64) error_utils.MessageErr {
+		return nil
+	}
+	err := New(srv.URL).Delete(context.Background(), 1)
+	assert.Nil(t, err)
+}
+
+func TestClient_Replies(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.ListRepliesFunc = func(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 3, ParentId: msgId}}, nil
+	}
+	replies, err := New(srv.URL).Replies(context.Background(), 1, domain.ListOptions{AfterId: 2})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.Message{{Id: 3, ParentId: 1}}, replies)
+	sm.AssertCalled(t, "ListReplies", int64(1), domain.ListOptions{Limit: defaultPageSize, AfterId: 2})
+}
+
+func TestClient_Thread(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.GetThreadFunc = func(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr) {
+		return domain.NewThread(msgId, []domain.Message{{Id: msgId, ReplyCount: 1}, {Id: 2, ParentId: msgId}}), nil
+	}
+	thread, err := New(srv.URL).Thread(context.Background(), 1, 3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, thread.Id)
+	if assert.EqualValues(t, 1, len(thread.Replies)) {
+		assert.EqualValues(t, 2, thread.Replies[0].Id)
+	}
+	sm.AssertCalled(t, "GetThread", int64(1), 3)
+
+	_, err = New(srv.URL).Thread(context.Background(), 1, 0)
+	assert.Nil(t, err)
+	sm.AssertCalled(t, "GetThread", int64(1), domain.DefaultThreadDepth)
+}
+
+func TestClient_List_Tags(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Tags: []string{"go", "news"}}}, nil
+	}
+	msgs, err := New(srv.URL).List(context.Background(), domain.ListOptions{Tags: []string{"news", "go"}, Match: domain.MatchAll})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.Message{{Id: 1, Tags: []string{"go", "news"}}}, msgs)
+	sm.AssertCalled(t, "ListMessages", domain.ListOptions{Limit: defaultPageSize, Tags: []string{"go", "news"}, Match: domain.MatchAll})
+}
+
+func TestClient_Tags(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.ListTagsFunc = func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+		return []domain.TagCount{{Name: "go", Count: 2}}, nil
+	}
+	sm.RenameTagFunc = func(ctx context.Context, from, to string) error_utils.MessageErr {
+		return nil
+	}
+	sm.MergeTagsFunc = func(ctx context.Context, from []string, into string) error_utils.MessageErr {
+		return error_utils.NewNotFoundError("no tag matching given names")
+	}
+	c := New(srv.URL)
+
+	tags, err := c.Tags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 2}}, tags)
+
+	assert.Nil(t, c.RenameTag(context.Background(), "golang", "go"))
+	sm.AssertCalled(t, "RenameTag", "golang", "go")
+
+	err = c.MergeTags(context.Background(), []string{"golang"}, "go")
+	if assert.NotNil(t, err) {
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	}
+	sm.AssertCalled(t, "MergeTags", []string{"golang"}, "go")
+}
+
+func TestClient_Iterate(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	all := []domain.Message{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}}
+	calls := 0
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		calls++
+		page := make([]domain.Message, 0)
+		for _, msg := range all {
+			if msg.Id > opts.AfterId && len(page) < opts.Limit {
+				page = append(page, msg)
+			}
+		}
+		return page, nil
+	}
+	it := New(srv.URL).Iterate(context.Background(), domain.ListOptions{Limit: 2})
+	ids := make([]int64, 0)
+	for it.Next() {
+		ids = append(ids, it.Message().Id)
+	}
+	assert.Nil(t, it.Err())
+	assert.EqualValues(t, []int64{1, 2, 3, 4, 5}, ids)
+	assert.EqualValues(t, 3, calls) //the last page is short, so there is no need to ask for a fourth one
+}
+
+func TestClient_Iterate_Error(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error getting messages")
+	}
+	it := New(srv.URL).Iterate(context.Background(), domain.ListOptions{})
+	assert.False(t, it.Next())
+	assert.EqualValues(t, http.StatusInternalServerError, it.Err().Status())
+}
+
+func TestClient_Retries_Idempotent_Requests(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId, Title: "the title", Body: "the body"}, nil
+	}
+	srv := newServer(sm)
+	defer srv.Close()
+	srv.Script(http.MethodGet, "/messages/1", messagestest.Response{Status: http.StatusBadGateway}, messagestest.Drop())
+
+	msg, err := New(srv.URL, WithRetries(2, time.Millisecond)).Get(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, 3, len(srv.Requests()))
+	sm.AssertNumberOfCalls(t, "GetMessage", 1)
+}
+
+func TestClient_Does_Not_Retry_Create(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	srv := newServer(sm)
+	defer srv.Close()
+	srv.Script(http.MethodPost, "/messages", messagestest.Response{Status: http.StatusBadGateway, Body: []byte("bad gateway")})
+
+	msg, err := New(srv.URL, WithRetries(2, time.Millisecond)).Create(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, msg)
+	assert.EqualValues(t, http.StatusBadGateway, err.Status())
+	assert.EqualValues(t, "bad_gateway", err.Error())
+	assert.EqualValues(t, "bad gateway", err.Message())
+	assert.EqualValues(t, 1, len(srv.Requests()))
+	sm.AssertNotCalled(t, "CreateMessage")
+}
+
+func TestClient_Decodes_Faults(t *testing.T) {
+	t.Parallel()
+	srv := newServer(&messagestest.Service{})
+	defer srv.Close()
+	srv.Script(http.MethodDelete, "/messages/1", messagestest.Fault(error_utils.NewServiceUnavailableError("the database is not available")))
+
+	err := New(srv.URL).Delete(context.Background(), 1)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusServiceUnavailable, err.Status())
+	assert.EqualValues(t, "the database is not available", err.Message())
+}
+
+//Answers that are not message errors, eg. from a proxy in front of the api, keep their status
+func TestClient_Keeps_The_Status_Of_Other_Errors(t *testing.T) {
+	t.Parallel()
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests} {
+		srv := newServer(&messagestest.Service{})
+		srv.Script(http.MethodGet, "/messages/1", messagestest.Response{Status: status, Body: []byte("<html>go away</html>")})
+		_, err := New(srv.URL).Get(context.Background(), 1)
+		srv.Close()
+		assert.EqualValues(t, status, err.Status())
+		assert.EqualValues(t, "<html>go away</html>", err.Message())
+	}
+}
+
+//A call given up by the caller is not a failure of the api, and is not retried
+func TestClient_Context_Errors(t *testing.T) {
+	t.Parallel()
+	srv := newServer(&messagestest.Service{})
+	defer srv.Close()
+	srv.Script(http.MethodGet, "/messages/1", messagestest.Slow(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := New(srv.URL, WithRetries(2, time.Millisecond)).Get(ctx, 1)
+	assert.EqualValues(t, error_utils.StatusClientClosedRequest, err.Status())
+	assert.EqualValues(t, error_utils.CodeDeadlineExceeded, err.Code())
+	assert.EqualValues(t, 1, len(srv.Requests()))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = New(srv.URL).Get(ctx, 1)
+	assert.EqualValues(t, error_utils.StatusClientClosedRequest, err.Status())
+	assert.EqualValues(t, error_utils.CodeCanceled, err.Code())
+}
+
+func TestClient_Sends_Token(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.DeleteMessageFunc = func(ctx context.Context, msgId int64) error_utils.MessageErr {
+		return nil
+	}
+	srv := newServer(sm)
+	defer srv.Close()
+
+	err := New(srv.URL, WithToken("secret")).Delete(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "Bearer secret", srv.Requests()[0].Header.Get("Authorization"))
+	sm.AssertCalled(t, "DeleteMessage", int64(1))
+}
+
+func TestClient_Import(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	var read []domain.Message
+	sm.ImportMessagesFunc = func(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+		assert.EqualValues(t, domain.OnDuplicateSkip, onDuplicate)
+		for {
+			msg, err := r.Read()
+			if err != nil {
+				break
+			}
+			read = append(read, *msg)
+		}
+		return &domain.ImportReport{Created: len(read), Errors: []domain.ImportError{}}, nil
+	}
+	srv := newServer(sm)
+	defer srv.Close()
+
+	input := "id,title,body,created_at,parent_id,tags\n1,first,body,,,go\n2,second,body,,1,\n"
+	report, err := New(srv.URL).Import(context.Background(), strings.NewReader(input), message_formats.CSV, domain.OnDuplicateSkip)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, report.Created)
+	assert.EqualValues(t, 2, len(read))
+	assert.EqualValues(t, []string{"go"}, read[0].Tags)
+	assert.EqualValues(t, 1, read[1].ParentId)
+	assert.EqualValues(t, "text/csv; charset=utf-8", srv.Requests()[0].Header.Get("Content-Type"))
+}
+
+func TestClient_Sends_Tenant(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId}, nil
+	}
+	srv := newServer(sm)
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithTenant("acme")).Get(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "acme", srv.Requests()[0].Header.Get("X-Tenant-Id"))
+}
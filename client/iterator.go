@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+)
+
+//Iterator walks through every message matching the list options, one page at a time.
+//
+//	it := c.Iterate(ctx, domain.ListOptions{})
+//	for it.Next() {
+//		msg := it.Message()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator struct {
+	client *Client
+	ctx    context.Context
+	opts   domain.ListOptions
+	page   []domain.Message
+	pos    int
+	done   bool
+	err    error_utils.MessageErr
+}
+
+//Iterate returns an iterator over the messages. opts.Limit is used as the page size.
+func (c *Client) Iterate(ctx context.Context, opts domain.ListOptions) *Iterator {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	return &Iterator{client: c, ctx: ctx, opts: opts, pos: -1}
+}
+
+//Next advances to the next message, fetching the next page when needed. It returns false when
+//there are no more messages or an error occurred.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.pos++
+	if it.pos < len(it.page) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+	page, err := it.client.List(it.ctx, it.opts)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if len(page) < it.opts.Limit {
+		it.done = true
+	}
+	if len(page) == 0 {
+		return false
+	}
+	it.page = page
+	it.pos = 0
+	it.opts.AfterId = page[len(page)-1].Id
+	return true
+}
+
+//Message returns the current message
+func (it *Iterator) Message() domain.Message {
+	return it.page[it.pos]
+}
+
+//Err returns the error that stopped the iteration, if any
+func (it *Iterator) Err() error_utils.MessageErr {
+	return it.err
+}
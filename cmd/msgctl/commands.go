@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(e.stderr, "usage: msgctl "+commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+//parseArgs parses the flags of a command, which may come before or after its positional arguments,
+//and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, nargs int) ([]string, bool) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, false
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != nargs {
+		fs.Usage()
+		return nil, false
+	}
+	return positional, true
+}
+
+func parseId(e *env, arg string) (int64, bool) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		fmt.Fprintln(e.stderr, "error: message id should be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+func runGet(e *env, args []string) int {
+	fs := newFlagSet(e, "get")
+	pos, ok := parseArgs(fs, args, 1)
+	if !ok {
+		return exitUsage
+	}
+	id, ok := parseId(e, pos[0])
+	if !ok {
+		return exitUsage
+	}
+	msg, err := e.client.Get(context.Background(), id)
+	if err != nil {
+		return e.fail(err)
+	}
+	return e.print([]domain.Message{*msg}, true)
+}
+
+func runList(e *env, args []string) int {
+	fs := newFlagSet(e, "list")
+	limit := fs.Int("limit", domain.DefaultListLimit, "number of messages per page")
+	after := fs.Int64("after", 0, "only messages with a greater id")
+	title := fs.String("title", "", "only messages whose title contains this text")
+	all := fs.Bool("all", false, "list every page, not only the first one")
+	if _, ok := parseArgs(fs, args, 0); !ok {
+		return exitUsage
+	}
+	opts := domain.ListOptions{Limit: *limit, AfterId: *after, Title: *title}
+	if !*all {
+		msgs, err := e.client.List(context.Background(), opts)
+		if err != nil {
+			return e.fail(err)
+		}
+		return e.print(msgs, false)
+	}
+	msgs, err := e.collect(opts)
+	if err != nil {
+		return e.fail(err)
+	}
+	return e.print(msgs, false)
+}
+
+func runSearch(e *env, args []string) int {
+	fs := newFlagSet(e, "search")
+	pos, ok := parseArgs(fs, args, 1)
+	if !ok {
+		return exitUsage
+	}
+	msgs, err := e.collect(domain.ListOptions{Limit: domain.MaxListLimit, Title: pos[0]})
+	if err != nil {
+		return e.fail(err)
+	}
+	return e.print(msgs, false)
+}
+
+func (e *env) collect(opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	msgs := make([]domain.Message, 0)
+	it := e.client.Iterate(context.Background(), opts)
+	for it.Next() {
+		msgs = append(msgs, it.Message())
+	}
+	return msgs, it.Err()
+}
+
+func runCreate(e *env, args []string) int {
+	fs := newFlagSet(e, "create")
+	title := fs.String("title", "", "title of the message")
+	body := fs.String("body", "", "body of the message")
+	if _, ok := parseArgs(fs, args, 0); !ok {
+		return exitUsage
+	}
+	msg, err := e.client.Create(context.Background(), &domain.Message{Title: *title, Body: *body})
+	if err != nil {
+		return e.fail(err)
+	}
+	return e.print([]domain.Message{*msg}, true)
+}
+
+func runUpdate(e *env, args []string) int {
+	fs := newFlagSet(e, "update")
+	title := fs.String("title", "", "new title of the message")
+	body := fs.String("body", "", "new body of the message")
+	pos, ok := parseArgs(fs, args, 1)
+	if !ok {
+		return exitUsage
+	}
+	id, ok := parseId(e, pos[0])
+	if !ok {
+		return exitUsage
+	}
+	msg, err := e.client.Update(context.Background(), &domain.Message{Id: id, Title: *title, Body: *body})
+	if err != nil {
+		return e.fail(err)
+	}
+	return e.print([]domain.Message{*msg}, true)
+}
+
+func runDelete(e *env, args []string) int {
+	fs := newFlagSet(e, "delete")
+	pos, ok := parseArgs(fs, args, 1)
+	if !ok {
+		return exitUsage
+	}
+	id, ok := parseId(e, pos[0])
+	if !ok {
+		return exitUsage
+	}
+	if err := e.client.Delete(context.Background(), id); err != nil {
+		return e.fail(err)
+	}
+	fmt.Fprintf(e.stdout, "message %d deleted\n", id)
+	return exitOK
+}
+
+//runImport sends the file to the api, which saves every message of it. Ids in the file are not kept, but replies
+//are linked to their parent and tags are kept, so an export can be imported back. It exits with exitInvalid
+//when a line failed, and with the code of the error when the api refused the whole import.
+func runImport(e *env, args []string) int {
+	fs := newFlagSet(e, "import")
+	format := fs.String("format", "", "jsonl or csv, guessed from the file extension when empty")
+	onDuplicate := fs.String("on-duplicate", domain.OnDuplicateFail, "what to do with a title that is already taken: skip, overwrite or fail")
+	pos, ok := parseArgs(fs, args, 1)
+	if !ok {
+		return exitUsage
+	}
+	if *format == "" {
+		*format = message_formats.FromFileName(pos[0])
+	}
+	if !message_formats.IsSupported(*format) {
+		fmt.Fprintf(e.stderr, "error: unsupported format %q\n", *format)
+		return exitUsage
+	}
+	f, err := os.Open(pos[0])
+	if err != nil {
+		fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+		return exitUsage
+	}
+	defer f.Close()
+
+	report, apiErr := e.client.Import(context.Background(), f, *format, *onDuplicate)
+	if apiErr != nil {
+		return e.fail(apiErr)
+	}
+	for _, lineErr := range report.Errors {
+		fmt.Fprintf(e.stderr, "line %d: %s\n", lineErr.Line, lineErr.Message)
+	}
+	fmt.Fprintf(e.stdout, "%d created, %d updated, %d skipped, %d failed\n", report.Created, report.Updated, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		return exitInvalid
+	}
+	return exitOK
+}
+
+func runExport(e *env, args []string) int {
+	fs := newFlagSet(e, "export")
+	format := fs.String("format", message_formats.JSONLines, "jsonl or csv")
+	out := fs.String("out", "", "file to write to, instead of stdout")
+	if _, ok := parseArgs(fs, args, 0); !ok {
+		return exitUsage
+	}
+	dst := e.stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+			return exitUsage
+		}
+		defer f.Close()
+		dst = f
+	}
+	w, err := message_formats.NewWriter(dst, *format)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+		return exitUsage
+	}
+	it := e.client.Iterate(context.Background(), domain.ListOptions{Limit: domain.MaxListLimit})
+	for it.Next() {
+		if err := w.Write(it.Message()); err != nil {
+			fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+			return exitUsage
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(e.stderr, "error: %s\n", err.Error())
+		return exitUsage
+	}
+	if err := it.Err(); err != nil {
+		return e.fail(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,136 @@
+//msgctl manages messages through the REST api.
+//
+//	msgctl [-url URL] [-token TOKEN] [-tenant TENANT] [-o table|json|yaml] <command> [flags] [args]
+//
+//The exit code is 0 on success, 1 for usage or local errors, 3 when the api rejected the request (400/401/403/409/413/422),
+//4 when a message was not found (404) and 5 when the api failed (5xx).
+package main
+
+import (
+	"efficient-api/client"
+	"efficient-api/utils/error_utils"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	exitOK          = 0
+	exitUsage       = 1
+	exitInvalid     = 3
+	exitNotFound    = 4
+	exitServerError = 5
+)
+
+type command struct {
+	usage string
+	run   func(env *env, args []string) int
+}
+
+var commands map[string]command
+
+//commands is filled in init, since the commands refer back to it for their usage
+func init() {
+	commands = map[string]command{
+		"get":    {usage: "get <id>", run: runGet},
+		"list":   {usage: "list [-limit n] [-after id] [-title text] [-all]", run: runList},
+		"search": {usage: "search <text>", run: runSearch},
+		"create": {usage: "create -title title -body body", run: runCreate},
+		"update": {usage: "update <id> -title title -body body", run: runUpdate},
+		"delete": {usage: "delete <id>", run: runDelete},
+		"import": {usage: "import [-format jsonl|csv] [-on-duplicate skip|overwrite|fail] <file>", run: runImport},
+		"export": {usage: "export [-format jsonl|csv] [-out file]", run: runExport},
+	}
+}
+
+//env is what every command runs with
+type env struct {
+	client *client.Client
+	output string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("msgctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseURL := fs.String("url", getenv("MSGCTL_URL", "http://localhost:8080"), "base url of the messages api")
+	token := fs.String("token", os.Getenv("MSGCTL_TOKEN"), "bearer token sent to the api")
+	tenant := fs.String("tenant", os.Getenv("MSGCTL_TENANT"), "tenant whose messages are managed")
+	output := fs.String("o", "table", "output format: table, json or yaml")
+	retries := fs.Int("retries", 2, "how many times idempotent requests are retried")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: msgctl [flags] <command> [command flags] [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(stderr, "  "+commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	if !isOutputFormat(*output) {
+		fmt.Fprintf(stderr, "unknown output format %q\n", *output)
+		return exitUsage
+	}
+	e := &env{
+		client: client.New(*baseURL, client.WithToken(*token), client.WithTenant(*tenant), client.WithRetries(*retries, 200*time.Millisecond)),
+		output: *output,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	return cmd.run(e, fs.Args()[1:])
+}
+
+//fail prints the api error and returns the exit code matching its status
+func (e *env) fail(err error_utils.MessageErr) int {
+	fmt.Fprintf(e.stderr, "error: %s (%d %s)\n", err.Message(), err.Status(), err.Error())
+	return exitCode(err)
+}
+
+func exitCode(err error_utils.MessageErr) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case err.Status() == http.StatusNotFound:
+		return exitNotFound
+	case err.Status() == http.StatusBadRequest || err.Status() == http.StatusUnprocessableEntity || err.Status() == http.StatusConflict,
+		err.Status() == http.StatusUnauthorized || err.Status() == http.StatusForbidden, err.Status() == http.StatusRequestEntityTooLarge:
+		return exitInvalid
+	case err.Status() >= http.StatusInternalServerError:
+		return exitServerError
+	}
+	return exitUsage
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
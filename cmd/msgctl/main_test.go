@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//imported is what the fake api received on /messages/import
+type imported struct {
+	query string
+	body  string
+}
+
+//fakeAPI answers like the messages api for a table holding two messages
+func fakeAPI() *httptest.Server {
+	return fakeImportAPI(nil)
+}
+
+//fakeImportAPI is fakeAPI, also sending what it received on /messages/import to imports when it is not nil.
+//It fails every line without a title.
+func fakeImportAPI(imports chan<- imported) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/messages/1":
+			w.Write([]byte(`{"id": 1, "title": "first title", "body": "first body", "tags": ["go"], "created_at": "2020-01-02T03:04:05Z", "reply_count": 1}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/messages/2":
+			w.Write([]byte(`{"id": 2, "parent_id": 1, "title": "second title", "body": "second body", "created_at": "2020-01-02T03:04:05Z"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/messages/import":
+			body, _ := ioutil.ReadAll(r.Body)
+			if imports != nil {
+				imports <- imported{query: r.URL.RawQuery, body: string(body)}
+			}
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			report := map[string]interface{}{"created": 0, "updated": 0, "skipped": 0, "failed": 0, "errors": []interface{}{}}
+			for i, line := range lines {
+				if strings.Contains(line, `"title": ""`) {
+					report["failed"] = report["failed"].(int) + 1
+					report["errors"] = append(report["errors"].([]interface{}), map[string]interface{}{"line": i + 1, "message": "Please enter a valid title"})
+					continue
+				}
+				report["created"] = report["created"].(int) + 1
+			}
+			json.NewEncoder(w).Encode(report)
+		case r.Method == http.MethodGet && r.URL.Path == "/messages":
+			if r.URL.Query().Get("after") == "0" {
+				w.Write([]byte(`[{"id": 1, "title": "first title", "body": "first body"}, {"id": 2, "title": "second title", "body": "second body"}]`))
+				return
+			}
+			w.Write([]byte(`[]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/messages":
+			var msg map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&msg)
+			if msg["title"] == "" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message": "Please enter a valid title", "status": 422, "error": "invalid_request"}`))
+				return
+			}
+			msg["id"] = 3
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(msg)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "no record matching given id", "status": 404, "error": "not_found"}`))
+		}
+	}))
+}
+
+func runCmd(srv *httptest.Server, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-url", srv.URL, "-retries", "0"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGet_Table(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, stdout, _ := runCmd(srv, "get", "1")
+	assert.EqualValues(t, exitOK, code)
+	assert.Contains(t, stdout, "ID")
+	assert.Contains(t, stdout, "first title")
+}
+
+func TestGet_Yaml(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, stdout, _ := runCmd(srv, "-o", "yaml", "get", "1")
+	assert.EqualValues(t, exitOK, code)
+	assert.Contains(t, stdout, "title: first title\n")
+	assert.Contains(t, stdout, "created_at: 2020-01-02T03:04:05Z\n")
+	assert.Contains(t, stdout, "tags:\n- go\n")
+	assert.Contains(t, stdout, "reply_count: 1\n")
+
+	_, stdout, _ = runCmd(srv, "-o", "yaml", "get", "2")
+	assert.Contains(t, stdout, "parent_id: 1\n")
+}
+
+func TestGet_Not_Found(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, _, stderr := runCmd(srv, "get", "10")
+	assert.EqualValues(t, exitNotFound, code)
+	assert.Contains(t, stderr, "no record matching given id")
+}
+
+func TestGet_Invalid_Id(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, _, _ := runCmd(srv, "get", "abc")
+	assert.EqualValues(t, exitUsage, code)
+}
+
+func TestList_Json(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, stdout, _ := runCmd(srv, "-o", "json", "list", "-all")
+	assert.EqualValues(t, exitOK, code)
+
+	var msgs []map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &msgs))
+	assert.EqualValues(t, 2, len(msgs))
+}
+
+func TestCreate_Invalid(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, _, stderr := runCmd(srv, "create", "-title", "", "-body", "the body")
+	assert.EqualValues(t, exitInvalid, code)
+	assert.Contains(t, stderr, "Please enter a valid title")
+}
+
+func TestExport_CSV(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, stdout, _ := runCmd(srv, "export", "-format", "csv")
+	assert.EqualValues(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.EqualValues(t, 3, len(lines))
+	assert.EqualValues(t, "id,title,body,created_at,parent_id,tags", lines[0])
+}
+
+func TestImport_Reports_Failures(t *testing.T) {
+	imports := make(chan imported, 1)
+	srv := fakeImportAPI(imports)
+	defer srv.Close()
+	dir, _ := ioutil.TempDir("", "msgctl")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "messages.jsonl")
+	ioutil.WriteFile(file, []byte(`{"title": "the title", "body": "the body"}`+"\n"+`{"title": "", "body": "the body"}`+"\n"), 0644)
+
+	code, stdout, stderr := runCmd(srv, "import", file)
+	assert.EqualValues(t, exitInvalid, code)
+	assert.Contains(t, stdout, "1 created, 0 updated, 0 skipped, 1 failed")
+	assert.Contains(t, stderr, "line 2: Please enter a valid title")
+	assert.EqualValues(t, "format=jsonl&on_duplicate=fail", (<-imports).query)
+}
+
+//The file goes to the api as it is, so the replies and tags of an export come back with it
+func TestImport_Keeps_Threads_And_Tags(t *testing.T) {
+	imports := make(chan imported, 1)
+	srv := fakeImportAPI(imports)
+	defer srv.Close()
+	dir, _ := ioutil.TempDir("", "msgctl")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "messages.csv")
+	content := "id,title,body,created_at,parent_id,tags\n1,first title,first body,2020-01-02T03:04:05Z,,go\n2,second title,second body,2020-01-02T03:04:05Z,1,\n"
+	ioutil.WriteFile(file, []byte(content), 0644)
+
+	code, stdout, _ := runCmd(srv, "import", "-on-duplicate", "skip", file)
+	assert.EqualValues(t, exitOK, code)
+	assert.Contains(t, stdout, "0 failed")
+	got := <-imports
+	assert.EqualValues(t, "format=csv&on_duplicate=skip", got.query)
+	assert.EqualValues(t, content, got.body)
+}
+
+func TestUnknown_Command(t *testing.T) {
+	srv := fakeAPI()
+	defer srv.Close()
+	code, _, stderr := runCmd(srv, "frobnicate")
+	assert.EqualValues(t, exitUsage, code)
+	assert.Contains(t, stderr, "unknown command")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"efficient-api/domain"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+//record is how a message is written in yaml, with the same field names as in json
+type record struct {
+	Id         int64     `yaml:"id"`
+	ParentId   int64     `yaml:"parent_id,omitempty"`
+	Title      string    `yaml:"title"`
+	Body       string    `yaml:"body"`
+	Tags       []string  `yaml:"tags,omitempty"`
+	CreatedAt  time.Time `yaml:"created_at"`
+	ReplyCount int64     `yaml:"reply_count"`
+}
+
+func isOutputFormat(format string) bool {
+	return format == outputTable || format == outputJSON || format == outputYAML
+}
+
+//print writes the messages in the chosen output format. A single message is written as an object
+//rather than a list in json and yaml.
+func (e *env) print(msgs []domain.Message, single bool) int {
+	var v interface{} = msgs
+	if single {
+		v = msgs[0]
+	}
+	switch e.output {
+	case outputJSON:
+		b, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Fprintln(e.stdout, string(b))
+	case outputYAML:
+		records := make([]record, 0, len(msgs))
+		for _, msg := range msgs {
+			records = append(records, record{Id: msg.Id, ParentId: msg.ParentId, Title: msg.Title, Body: msg.Body, Tags: msg.Tags, CreatedAt: msg.CreatedAt, ReplyCount: msg.ReplyCount})
+		}
+		var y interface{} = records
+		if single {
+			y = records[0]
+		}
+		b, _ := yaml.Marshal(y)
+		fmt.Fprint(e.stdout, string(b))
+	default:
+		tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTITLE\tBODY\tCREATED AT")
+		for _, msg := range msgs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", msg.Id, msg.Title, msg.Body, msg.CreatedAt.Format(time.RFC3339))
+		}
+		tw.Flush()
+	}
+	return exitOK
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"efficient-api/client"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	opGet    = "get"
+	opList   = "list"
+	opPost   = "post"
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+//operations in the order they are reported
+var operations = []string{opGet, opList, opPost, opPut, opDelete}
+
+const defaultMix = "get=60,list=10,post=10,put=10,delete=10"
+
+//mix is the weight of each operation, an operation is picked with a probability of its weight over the total
+type mix map[string]int
+
+func parseMix(s string) (mix, error) {
+	m := make(mix)
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%q should be operation=weight", part)
+		}
+		op := strings.ToLower(strings.TrimSpace(kv[0]))
+		if !isOperation(op) {
+			return nil, fmt.Errorf("unknown operation %q, expected one of %s", kv[0], strings.Join(operations, ", "))
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("the weight of %s should be a number not below 0", op)
+		}
+		m[op] += weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("at least one operation should have a weight")
+	}
+	return m, nil
+}
+
+func isOperation(op string) bool {
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+//pick returns an operation drawn at random according to the weights
+func (m mix) pick(rnd *rand.Rand) string {
+	total := 0
+	for _, op := range operations {
+		total += m[op]
+	}
+	n := rnd.Intn(total)
+	for _, op := range operations {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return operations[len(operations)-1]
+}
+
+//pool holds the ids of the messages created by the load, for the gets, puts and deletes to pick from
+type pool struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func newPool() *pool {
+	return &pool{}
+}
+
+func (p *pool) add(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = append(p.ids, id)
+}
+
+//random returns one of the ids, and false when there is none. With take, the id is removed from the pool.
+func (p *pool) random(rnd *rand.Rand, take bool) (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return 0, false
+	}
+	i := rnd.Intn(len(p.ids))
+	id := p.ids[i]
+	if take {
+		p.ids[i] = p.ids[len(p.ids)-1]
+		p.ids = p.ids[:len(p.ids)-1]
+	}
+	return id, true
+}
+
+//newHTTPClient keeps a connection open for every worker, instead of the 2 per host of http.DefaultTransport
+func newHTTPClient(timeout time.Duration, concurrency int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+	transport.MaxIdleConns = concurrency
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+type load struct {
+	client      *client.Client
+	mix         mix
+	concurrency int
+	//requests is the number of requests to send in total, 0 for no limit
+	requests int64
+	rate     int
+	pool     *pool
+	//prefix makes the titles of this run differ from the ones of other runs
+	prefix string
+
+	sent   int64
+	titles int64
+}
+
+func (l *load) title() string {
+	return fmt.Sprintf("%s %d", l.prefix, atomic.AddInt64(&l.titles, 1))
+}
+
+//seed creates n messages before the load starts, they are not part of the report
+func (l *load) seed(ctx context.Context, n int) error_utils.MessageErr {
+	for i := 0; i < n; i++ {
+		msg, err := l.client.Create(ctx, &domain.Message{Title: l.title(), Body: "seeded by msgload"})
+		if err != nil {
+			return err
+		}
+		l.pool.add(msg.Id)
+	}
+	return nil
+}
+
+//run sends requests from every worker until ctx is done or the requests were all sent
+func (l *load) run(ctx context.Context) *report {
+	var ticks <-chan time.Time
+	if l.rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(l.rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	start := time.Now()
+	results := make([]*report, l.concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < l.concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = l.work(ctx, ticks, rand.New(rand.NewSource(time.Now().UnixNano()+int64(w))))
+		}(w)
+	}
+	wg.Wait()
+
+	r := newReport()
+	for _, result := range results {
+		r.merge(result)
+	}
+	r.elapsed = time.Since(start)
+	return r
+}
+
+func (l *load) work(ctx context.Context, ticks <-chan time.Time, rnd *rand.Rand) *report {
+	r := newReport()
+	for {
+		if ticks != nil {
+			select {
+			case <-ticks:
+			case <-ctx.Done():
+				return r
+			}
+		}
+		if ctx.Err() != nil || (l.requests > 0 && atomic.AddInt64(&l.sent, 1) > l.requests) {
+			return r
+		}
+		op := l.mix.pick(rnd)
+		begin := time.Now()
+		op, err := l.do(ctx, op, rnd)
+		took := time.Since(begin)
+		//a request cut short by the end of the run says nothing about the api
+		if ctx.Err() != nil {
+			return r
+		}
+		r.add(op, took, err)
+	}
+}
+
+//do sends the request of the operation, and returns the operation it did: without a message to pick,
+//a get, put or delete creates one instead
+func (l *load) do(ctx context.Context, op string, rnd *rand.Rand) (string, error_utils.MessageErr) {
+	switch op {
+	case opGet:
+		if id, ok := l.pool.random(rnd, false); ok {
+			_, err := l.client.Get(ctx, id)
+			return op, err
+		}
+	case opList:
+		_, err := l.client.List(ctx, domain.ListOptions{Limit: 20})
+		return op, err
+	case opPut:
+		if id, ok := l.pool.random(rnd, false); ok {
+			_, err := l.client.Update(ctx, &domain.Message{Id: id, Title: l.title(), Body: "updated by msgload"})
+			return op, err
+		}
+	case opDelete:
+		if id, ok := l.pool.random(rnd, true); ok {
+			return op, l.client.Delete(ctx, id)
+		}
+	}
+	msg, err := l.client.Create(ctx, &domain.Message{Title: l.title(), Body: "created by msgload"})
+	if err == nil {
+		l.pool.add(msg.Id)
+	}
+	return opPost, err
+}
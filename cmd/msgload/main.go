@@ -0,0 +1,87 @@
+//msgload drives a mix of requests against a running messages api and reports their latency and errors.
+//
+//	msgload [-url URL] [-token TOKEN] [-duration 10s] [-requests n] [-concurrency 10] [-rate n] [-mix get=60,list=10,post=10,put=10,delete=10] [-seed 100]
+//
+//It first creates -seed messages, which the gets, puts and deletes pick from, then sends requests from -concurrency workers
+//until -duration is over or -requests were sent. The exit code is 0 when the load ran, 1 for usage errors, 2 when
+//the error rate went over -max-error-rate and 3 when the load could not start, as the api could not be reached or seeded.
+package main
+
+import (
+	"context"
+	"efficient-api/client"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	exitOK        = 0
+	exitUsage     = 1
+	exitTooFailed = 2
+	exitSetup     = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("msgload", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseURL := fs.String("url", getenv("MSGLOAD_URL", "http://localhost:8080"), "base url of the messages api")
+	token := fs.String("token", os.Getenv("MSGLOAD_TOKEN"), "bearer token sent to the api")
+	duration := fs.Duration("duration", 10*time.Second, "how long the load runs")
+	requests := fs.Int("requests", 0, "stop after this many requests, 0 for no limit")
+	concurrency := fs.Int("concurrency", 10, "how many requests are sent at once")
+	rate := fs.Int("rate", 0, "requests per second over all the workers, 0 for as many as the api answers")
+	mixFlag := fs.String("mix", defaultMix, "weight of each operation: get, list, post, put and delete")
+	seed := fs.Int("seed", 100, "how many messages are created before the load starts")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each request")
+	maxErrorRate := fs.Float64("max-error-rate", 1, "exit with 2 when more than this fraction of the requests failed")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	m, err := parseMix(*mixFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid -mix: %s\n", err.Error())
+		return exitUsage
+	}
+	if *concurrency < 1 || *duration <= 0 || *requests < 0 || *rate < 0 || *seed < 0 {
+		fmt.Fprintln(stderr, "-concurrency must be at least 1, -duration positive, and -requests, -rate and -seed not negative")
+		return exitUsage
+	}
+
+	l := &load{
+		client:      client.New(*baseURL, client.WithToken(*token), client.WithHTTPClient(newHTTPClient(*timeout, *concurrency))),
+		mix:         m,
+		concurrency: *concurrency,
+		requests:    int64(*requests),
+		rate:        *rate,
+		pool:        newPool(),
+		prefix:      fmt.Sprintf("msgload %d", time.Now().UnixNano()),
+	}
+	if err := l.seed(context.Background(), *seed); err != nil {
+		fmt.Fprintf(stderr, "error: cannot seed the messages: %s (%d %s)\n", err.Message(), err.Status(), err.Error())
+		return exitSetup
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	r := l.run(ctx)
+	r.print(stdout)
+	if r.errorRate() > *maxErrorRate {
+		fmt.Fprintf(stderr, "error rate %.2f%% is over %.2f%%\n", 100*r.errorRate(), 100**maxErrorRate)
+		return exitTooFailed
+	}
+	return exitOK
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"efficient-api/app"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = ioutil.Discard
+}
+
+//newAPI serves the real api on top of messages kept in memory
+func newAPI() *messagestest.Server {
+	return messagestest.NewServer(app.New(app.Config{}, app.Deps{Repository: domain.NewMemoryRepository()}))
+}
+
+func runLoad(srv *messagestest.Server, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-url", srv.URL}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Reports_Every_Operation(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+
+	code, stdout, stderr := runLoad(srv, "-requests", "300", "-concurrency", "1", "-seed", "20", "-max-error-rate", "0")
+	assert.EqualValues(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "300 requests in")
+	assert.Contains(t, stdout, "0 errors (0.00%)")
+	for _, op := range append(operations, "all") {
+		assert.Contains(t, stdout, "\n"+op+" ")
+	}
+	assert.NotContains(t, stdout, "errors by status")
+}
+
+//With several workers a get or put may pick a message another worker is deleting, and get a 404, but the api never fails
+func TestRun_Concurrent_Workers(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+
+	code, stdout, stderr := runLoad(srv, "-requests", "300", "-concurrency", "8", "-seed", "50", "-max-error-rate", "0.1")
+	assert.EqualValues(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "300 requests in")
+	assert.NotRegexp(t, `\n  5\d\d: `, stdout)
+}
+
+func TestRun_Counts_Errors_By_Status(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+	faults := make([]messagestest.Response, 10)
+	for i := range faults {
+		faults[i] = messagestest.Fault(error_utils.NewServiceUnavailableError("the database is not available"))
+	}
+	srv.Script(http.MethodGet, "", faults...)
+
+	code, stdout, stderr := runLoad(srv, "-requests", "50", "-concurrency", "1", "-seed", "5", "-mix", "get=1", "-max-error-rate", "0.1")
+	assert.EqualValues(t, exitTooFailed, code)
+	assert.Contains(t, stdout, "50 requests in")
+	assert.Contains(t, stdout, "10 errors (20.00%)")
+	assert.Contains(t, stdout, "503: 10")
+	assert.Contains(t, stderr, "error rate 20.00% is over 10.00%")
+}
+
+func TestRun_Stops_After_Duration(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+
+	start := time.Now()
+	code, stdout, _ := runLoad(srv, "-duration", "100ms", "-rate", "100", "-seed", "1")
+	assert.EqualValues(t, exitOK, code)
+	assert.True(t, time.Since(start) < 2*time.Second)
+	assert.Contains(t, stdout, "requests in")
+}
+
+//A load that cannot seed its messages never ran, which is not the api failing under load
+func TestRun_Seed_Failure(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+	srv.Script(http.MethodPost, "/messages", messagestest.Fault(error_utils.NewUnauthorizedError("a bearer token is required")))
+
+	code, stdout, stderr := runLoad(srv, "-requests", "10", "-seed", "5")
+	assert.EqualValues(t, exitSetup, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "cannot seed the messages: a bearer token is required")
+}
+
+func TestRun_Usage_Errors(t *testing.T) {
+	t.Parallel()
+	srv := newAPI()
+	defer srv.Close()
+
+	code, _, stderr := runLoad(srv, "-mix", "get=1,patch=1")
+	assert.EqualValues(t, exitUsage, code)
+	assert.Contains(t, stderr, `unknown operation "patch"`)
+
+	code, _, _ = runLoad(srv, "-concurrency", "0")
+	assert.EqualValues(t, exitUsage, code)
+}
+
+func TestParseMix(t *testing.T) {
+	t.Parallel()
+	m, err := parseMix("get=3, POST=1,get=1")
+	assert.Nil(t, err)
+	assert.EqualValues(t, mix{"get": 4, "post": 1}, m)
+
+	_, err = parseMix("get")
+	assert.EqualValues(t, `"get" should be operation=weight`, err.Error())
+	_, err = parseMix("get=-1")
+	assert.EqualValues(t, "the weight of get should be a number not below 0", err.Error())
+	_, err = parseMix("get=0,put=0")
+	assert.EqualValues(t, "at least one operation should have a weight", err.Error())
+}
+
+func TestMix_Pick_Follows_Weights(t *testing.T) {
+	t.Parallel()
+	m := mix{"get": 3, "delete": 1}
+	rnd := rand.New(rand.NewSource(1))
+	picked := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		picked[m.pick(rnd)]++
+	}
+	assert.EqualValues(t, 2, len(picked))
+	assert.InDelta(t, 3000, picked["get"], 200)
+	assert.InDelta(t, 1000, picked["delete"], 200)
+}
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	assert.EqualValues(t, 50*time.Millisecond, percentile(sorted, 0.5))
+	assert.EqualValues(t, 99*time.Millisecond, percentile(sorted, 0.99))
+	assert.EqualValues(t, 100*time.Millisecond, percentile(sorted, 1))
+	assert.EqualValues(t, 0, percentile(nil, 0.5))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+//report gathers the latency of every request and the status of every failed one, by operation
+type report struct {
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	statuses  map[int]int
+	elapsed   time.Duration
+}
+
+func newReport() *report {
+	return &report{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+		statuses:  make(map[int]int),
+	}
+}
+
+func (r *report) add(op string, took time.Duration, err error_utils.MessageErr) {
+	r.latencies[op] = append(r.latencies[op], took)
+	if err != nil {
+		r.errors[op]++
+		r.statuses[err.Status()]++
+	}
+}
+
+func (r *report) merge(other *report) {
+	for op, latencies := range other.latencies {
+		r.latencies[op] = append(r.latencies[op], latencies...)
+	}
+	for op, n := range other.errors {
+		r.errors[op] += n
+	}
+	for status, n := range other.statuses {
+		r.statuses[status] += n
+	}
+}
+
+func (r *report) total() (requests, errors int) {
+	for op, latencies := range r.latencies {
+		requests += len(latencies)
+		errors += r.errors[op]
+	}
+	return requests, errors
+}
+
+func (r *report) errorRate() float64 {
+	requests, errors := r.total()
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+//percentile returns the latency under which the fraction p of the sorted latencies fall
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (r *report) print(w io.Writer) {
+	requests, errors := r.total()
+	rps := 0.0
+	if r.elapsed > 0 {
+		rps = float64(requests) / r.elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "%d requests in %s, %.1f/s, %d errors (%.2f%%)\n\n", requests, r.elapsed.Round(time.Millisecond), rps, errors, 100*r.errorRate())
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tREQUESTS\tERRORS\tP50\tP90\tP99\tMAX")
+	all := make([]time.Duration, 0, requests)
+	for _, op := range operations {
+		latencies := r.latencies[op]
+		if len(latencies) == 0 {
+			continue
+		}
+		all = append(all, latencies...)
+		printRow(tw, op, latencies, r.errors[op])
+	}
+	printRow(tw, "all", all, errors)
+	tw.Flush()
+
+	if len(r.statuses) > 0 {
+		fmt.Fprintln(w, "\nerrors by status:")
+		for _, status := range sortedStatuses(r.statuses) {
+			fmt.Fprintf(w, "  %d: %d\n", status, r.statuses[status])
+		}
+	}
+}
+
+//sortedStatuses returns the statuses of the map in increasing order
+func sortedStatuses(m map[int]int) []int {
+	statuses := make([]int, 0, len(m))
+	for status := range m {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	return statuses
+}
+
+func printRow(w io.Writer, name string, latencies []time.Duration, errors int) {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", name, len(sorted), errors,
+		round(percentile(sorted, 0.5)), round(percentile(sorted, 0.9)), round(percentile(sorted, 0.99)), round(percentile(sorted, 1)))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,721 @@
+//Package conformance checks that an implementation of domain.MessageRepository or services.MessageService
+//keeps the contract documented on the interface, so that it can stand in for the MySQL one.
+//Call Repository or Service from a test of the implementation:
+//
+//	func TestMyRepository(t *testing.T) {
+//		conformance.Repository(t, func(t *testing.T) domain.MessageRepository {
+//			return NewMyRepository()
+//		})
+//	}
+package conformance
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Repository runs the contract of domain.MessageRepository against the repositories returned by newRepo.
+//newRepo is called once per test and must return a repository without any message.
+func Repository(t *testing.T, newRepo func(t *testing.T) domain.MessageRepository) {
+	for _, tt := range repositoryTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+var repositoryTests = []struct {
+	name string
+	run  func(t *testing.T, repo domain.MessageRepository)
+}{
+	{"Create_Then_Get", repoCreateThenGet},
+	{"Create_Title_Taken", repoCreateTitleTaken},
+	{"Create_Too_Long", repoCreateTooLong},
+	{"Get_Not_Found", repoGetNotFound},
+	{"GetMany", repoGetMany},
+	{"GetAll", repoGetAll},
+	{"GetAll_Empty", repoGetAllEmpty},
+	{"List_Pages", repoListPages},
+	{"List_Title_Filter", repoListTitleFilter},
+	{"GetByTitle", repoGetByTitle},
+	{"Update", repoUpdate},
+	{"Update_Title_Taken", repoUpdateTitleTaken},
+	{"Update_Missing", repoUpdateMissing},
+	{"Delete", repoDelete},
+	{"Delete_Missing", repoDeleteMissing},
+	{"Stream_In_Order", repoStreamInOrder},
+	{"Stream_Stops_On_Error", repoStreamStopsOnError},
+	{"Cancelled_Context", repoCancelledContext},
+	{"Count", repoCount},
+	{"Tenant_Reads_Isolated", repoTenantReadsIsolated},
+	{"Tenant_Writes_Isolated", repoTenantWritesIsolated},
+	{"Tenant_Title_Per_Tenant", repoTenantTitlePerTenant},
+	{"Replies_Pages", repoRepliesPages},
+	{"Reply_Count", repoReplyCount},
+	{"Thread_Depth", repoThreadDepth},
+	{"Thread_Not_Found", repoThreadNotFound},
+	{"Delete_Cascades", repoDeleteCascades},
+	{"Detach", repoDetach},
+	{"Delete_Leaf", repoDeleteLeaf},
+	{"Tenant_Replies_Isolated", repoTenantRepliesIsolated},
+	{"Tags_Saved", repoTagsSaved},
+	{"List_Tag_Filter", repoListTagFilter},
+	{"Tags_Counted", repoTagsCounted},
+	{"Rename_Tag", repoRenameTag},
+	{"Merge_Tags", repoMergeTags},
+	{"Tenant_Tags_Isolated", repoTenantTagsIsolated},
+}
+
+//seed creates a message per title, with the body derived from it, and returns them in id order
+func seed(t *testing.T, repo domain.MessageRepository, titles ...string) []domain.Message {
+	msgs := make([]domain.Message, 0, len(titles))
+	for _, title := range titles {
+		msg, err := repo.Create(context.Background(), &domain.Message{Title: title, Body: title + " body", CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("Create(%q) error = %v", title, err)
+		}
+		msgs = append(msgs, *msg)
+	}
+	return msgs
+}
+
+//reply creates a reply to the message with the given id
+func reply(t *testing.T, repo domain.MessageRepository, parentId int64, title string) domain.Message {
+	msg, err := repo.Create(context.Background(), &domain.Message{ParentId: parentId, Title: title, Body: title + " body", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Create(%q) error = %v", title, err)
+	}
+	return *msg
+}
+
+//tag creates a message carrying the given tags, which are expected normalized
+func tag(t *testing.T, repo domain.MessageRepository, title string, tags ...string) domain.Message {
+	msg, err := repo.Create(context.Background(), &domain.Message{Title: title, Body: title + " body", CreatedAt: time.Now(), Tags: tags})
+	if err != nil {
+		t.Fatalf("Create(%q) error = %v", title, err)
+	}
+	return *msg
+}
+
+func ids(msgs []domain.Message) []int64 {
+	result := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		result = append(result, msg.Id)
+	}
+	return result
+}
+
+//assertErr checks the status and, when given, the code of err
+func assertErr(t *testing.T, err error_utils.MessageErr, status int, code string) {
+	t.Helper()
+	if !assert.NotNil(t, err) {
+		return
+	}
+	assert.EqualValues(t, status, err.Status())
+	if code != "" {
+		assert.EqualValues(t, code, err.Code())
+	}
+}
+
+func repoCreateThenGet(t *testing.T, repo domain.MessageRepository) {
+	createdAt := time.Now()
+	first, err := repo.Create(context.Background(), &domain.Message{Title: "first title", Body: "first body", CreatedAt: createdAt})
+	assert.Nil(t, err)
+	second, err := repo.Create(context.Background(), &domain.Message{Title: "second title", Body: "second body", CreatedAt: createdAt})
+	assert.Nil(t, err)
+	assert.True(t, first.Id > 0)
+	assert.NotEqual(t, first.Id, second.Id)
+
+	got, err := repo.Get(context.Background(), first.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, first.Id, got.Id)
+	assert.EqualValues(t, "first title", got.Title)
+	assert.EqualValues(t, "first body", got.Body)
+	//databases may round the time to the second
+	assert.WithinDuration(t, createdAt, got.CreatedAt, time.Second)
+}
+
+func repoCreateTitleTaken(t *testing.T, repo domain.MessageRepository) {
+	seed(t, repo, "the title")
+	msg, err := repo.Create(context.Background(), &domain.Message{Title: "the title", Body: "another body", CreatedAt: time.Now()})
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusConflict, error_utils.CodeTitleTaken)
+}
+
+func repoCreateTooLong(t *testing.T, repo domain.MessageRepository) {
+	msg, err := repo.Create(context.Background(), &domain.Message{Title: strings.Repeat("t", domain.TitleMaxLength+1), Body: "the body", CreatedAt: time.Now()})
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusUnprocessableEntity, error_utils.CodeValidationFailed)
+	if err != nil && assert.EqualValues(t, 1, len(err.Fields())) {
+		assert.EqualValues(t, error_utils.CodeTitleTooLong, err.Fields()[0].Code)
+	}
+}
+
+func repoGetNotFound(t *testing.T, repo domain.MessageRepository) {
+	msg, err := repo.Get(context.Background(), 1)
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func repoGetMany(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title", "third title")
+	msgs, err := repo.GetMany(context.Background(), []int64{seeded[2].Id, seeded[0].Id, seeded[2].Id + 100})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{seeded[0].Id, seeded[2].Id}, ids(msgs))
+	assert.EqualValues(t, "first title", msgs[0].Title)
+
+	msgs, err = repo.GetMany(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(msgs))
+
+	msgs, err = repo.GetMany(domain.WithTenant(context.Background(), "other"), []int64{seeded[0].Id})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(msgs))
+}
+
+func repoGetAll(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title", "third title")
+	msgs, err := repo.GetAll(context.Background())
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, ids(seeded), ids(msgs))
+}
+
+func repoGetAllEmpty(t *testing.T, repo domain.MessageRepository) {
+	msgs, err := repo.GetAll(context.Background())
+	assert.Nil(t, msgs)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func repoListPages(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title", "third title")
+
+	page, err := repo.List(context.Background(), domain.ListOptions{Limit: 2})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded[:2]), ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Limit: 2, AfterId: page[len(page)-1].Id})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded[2:]), ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Limit: 2, AfterId: seeded[2].Id})
+	assert.Nil(t, err)
+	assert.NotNil(t, page)
+	assert.EqualValues(t, 0, len(page))
+}
+
+//The filter matches part of the title, and the wildcards of SQL are taken literally
+func repoListTitleFilter(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title", "100% title", "title_one")
+
+	page, err := repo.List(context.Background(), domain.ListOptions{Title: "second"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded[1:2]), ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Title: "%"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded[2:3]), ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Title: "_"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded[3:]), ids(page))
+}
+
+func repoGetByTitle(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title")
+	msg, err := repo.GetByTitle(context.Background(), "second title")
+	assert.Nil(t, err)
+	if assert.NotNil(t, msg) {
+		assert.EqualValues(t, seeded[1].Id, msg.Id)
+		assert.EqualValues(t, "second title body", msg.Body)
+	}
+
+	msg, err = repo.GetByTitle(context.Background(), "third title")
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func repoUpdate(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "the title")
+	updated, err := repo.Update(context.Background(), &domain.Message{Id: seeded[0].Id, Title: "update title", Body: "update body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "update title", updated.Title)
+
+	got, err := repo.Get(context.Background(), seeded[0].Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "update title", got.Title)
+	assert.EqualValues(t, "update body", got.Body)
+	assert.WithinDuration(t, seeded[0].CreatedAt, got.CreatedAt, time.Second)
+
+	//keeping its own title is not a conflict
+	_, err = repo.Update(context.Background(), &domain.Message{Id: seeded[0].Id, Title: "update title", Body: "another body"})
+	assert.Nil(t, err)
+}
+
+func repoUpdateTitleTaken(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title")
+	_, err := repo.Update(context.Background(), &domain.Message{Id: seeded[1].Id, Title: "first title", Body: "the body"})
+	assertErr(t, err, http.StatusConflict, error_utils.CodeTitleTaken)
+
+	got, getErr := repo.Get(context.Background(), seeded[1].Id)
+	assert.Nil(t, getErr)
+	assert.EqualValues(t, "second title", got.Title)
+}
+
+func repoUpdateMissing(t *testing.T, repo domain.MessageRepository) {
+	_, err := repo.Update(context.Background(), &domain.Message{Id: 1, Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	_, getErr := repo.Get(context.Background(), 1)
+	assertErr(t, getErr, http.StatusNotFound, "")
+}
+
+func repoDelete(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title")
+	assert.Nil(t, repo.Delete(context.Background(), seeded[0].Id))
+
+	_, err := repo.Get(context.Background(), seeded[0].Id)
+	assertErr(t, err, http.StatusNotFound, "")
+	_, err = repo.Get(context.Background(), seeded[1].Id)
+	assert.Nil(t, err)
+	//the title is free again
+	seed(t, repo, "first title")
+}
+
+func repoDeleteMissing(t *testing.T, repo domain.MessageRepository) {
+	assert.Nil(t, repo.Delete(context.Background(), 1))
+}
+
+func repoStreamInOrder(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "first title", "second title", "third title")
+	streamed := make([]domain.Message, 0)
+	err := repo.Stream(context.Background(), func(msg domain.Message) error {
+		streamed = append(streamed, msg)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, ids(seeded), ids(streamed))
+}
+
+func repoStreamStopsOnError(t *testing.T, repo domain.MessageRepository) {
+	seed(t, repo, "first title", "second title")
+	calls := 0
+	err := repo.Stream(context.Background(), func(msg domain.Message) error {
+		calls++
+		return errors.New("client went away")
+	})
+	assertErr(t, err, http.StatusInternalServerError, "")
+	assert.EqualValues(t, 1, calls)
+}
+
+func repoCancelledContext(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "the title")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := repo.Get(ctx, seeded[0].Id)
+	assertErr(t, err, http.StatusServiceUnavailable, "")
+}
+
+func repoCount(t *testing.T, repo domain.MessageRepository) {
+	count, err := repo.Count(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, count)
+
+	seeded := seed(t, repo, "first title", "second title")
+	count, err = repo.Count(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, count)
+
+	assert.Nil(t, repo.Delete(context.Background(), seeded[0].Id))
+	count, err = repo.Count(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, count)
+}
+
+//A tenant reads nothing of the messages of another, by id, title, page or stream
+func repoTenantReadsIsolated(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "the title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	msg, err := repo.Get(other, seeded[0].Id)
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusNotFound, "")
+
+	msg, err = repo.GetByTitle(other, "the title")
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusNotFound, "")
+
+	msgs, err := repo.GetAll(other)
+	assert.Nil(t, msgs)
+	assertErr(t, err, http.StatusNotFound, "")
+
+	page, err := repo.List(other, domain.ListOptions{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+
+	streamed := 0
+	assert.Nil(t, repo.Stream(other, func(domain.Message) error {
+		streamed++
+		return nil
+	}))
+	assert.EqualValues(t, 0, streamed)
+
+	count, err := repo.Count(other)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, count)
+}
+
+//A tenant cannot change or delete the messages of another
+func repoTenantWritesIsolated(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "the title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	_, err := repo.Update(other, &domain.Message{Id: seeded[0].Id, Title: "update title", Body: "update body"})
+	assert.Nil(t, err)
+	assert.Nil(t, repo.Delete(other, seeded[0].Id))
+
+	got, err := repo.Get(context.Background(), seeded[0].Id)
+	assert.Nil(t, err)
+	if assert.NotNil(t, got) {
+		assert.EqualValues(t, "the title", got.Title)
+		assert.EqualValues(t, "the title body", got.Body)
+	}
+}
+
+//Titles are unique within a tenant only
+func repoTenantTitlePerTenant(t *testing.T, repo domain.MessageRepository) {
+	seeded := seed(t, repo, "the title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	msg, err := repo.Create(other, &domain.Message{Title: "the title", Body: "other body", CreatedAt: time.Now()})
+	assert.Nil(t, err)
+	if !assert.NotNil(t, msg) {
+		return
+	}
+	assert.NotEqual(t, seeded[0].Id, msg.Id)
+
+	_, err = repo.Create(other, &domain.Message{Title: "the title", Body: "another body", CreatedAt: time.Now()})
+	assertErr(t, err, http.StatusConflict, error_utils.CodeTitleTaken)
+
+	got, err := repo.GetByTitle(other, "the title")
+	assert.Nil(t, err)
+	if assert.NotNil(t, got) {
+		assert.EqualValues(t, msg.Id, got.Id)
+	}
+	got, err = repo.GetByTitle(context.Background(), "the title")
+	assert.Nil(t, err)
+	if assert.NotNil(t, got) {
+		assert.EqualValues(t, seeded[0].Id, got.Id)
+	}
+}
+
+func repoRepliesPages(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+	second := reply(t, repo, root.Id, "second reply")
+	third := reply(t, repo, root.Id, "third reply")
+	//replies of replies are not direct replies
+	reply(t, repo, first.Id, "nested reply")
+	seed(t, repo, "another root")
+
+	page, err := repo.Replies(context.Background(), root.Id, domain.ListOptions{Limit: 2})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{first.Id, second.Id}, ids(page))
+
+	page, err = repo.Replies(context.Background(), root.Id, domain.ListOptions{AfterId: second.Id, Limit: 2})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{third.Id}, ids(page))
+	if assert.EqualValues(t, 1, len(page)) {
+		assert.EqualValues(t, root.Id, page[0].ParentId)
+	}
+
+	page, err = repo.Replies(context.Background(), third.Id, domain.ListOptions{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+}
+
+//ReplyCount counts the direct replies, and is ignored on writes
+func repoReplyCount(t *testing.T, repo domain.MessageRepository) {
+	root, err := repo.Create(context.Background(), &domain.Message{Title: "root title", Body: "root body", CreatedAt: time.Now(), ReplyCount: 5})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, root.ReplyCount)
+	first := reply(t, repo, root.Id, "first reply")
+	reply(t, repo, root.Id, "second reply")
+	reply(t, repo, first.Id, "nested reply")
+
+	got, err := repo.Get(context.Background(), root.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, got.ReplyCount)
+
+	page, err := repo.List(context.Background(), domain.ListOptions{})
+	assert.Nil(t, err)
+	counts := make(map[int64]int64)
+	for _, msg := range page {
+		counts[msg.Id] = msg.ReplyCount
+	}
+	assert.EqualValues(t, 2, counts[root.Id])
+	assert.EqualValues(t, 1, counts[first.Id])
+}
+
+func repoThreadDepth(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+	nested := reply(t, repo, first.Id, "nested reply")
+	deepest := reply(t, repo, nested.Id, "deepest reply")
+	sibling := reply(t, repo, root.Id, "sibling reply")
+	seed(t, repo, "another root")
+
+	msgs, err := repo.Thread(context.Background(), root.Id, 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{root.Id, first.Id, sibling.Id}, ids(msgs))
+
+	msgs, err = repo.Thread(context.Background(), root.Id, domain.MaxThreadDepth)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{root.Id, first.Id, nested.Id, deepest.Id, sibling.Id}, ids(msgs))
+
+	//a thread can start at any reply
+	msgs, err = repo.Thread(context.Background(), nested.Id, domain.MaxThreadDepth)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{nested.Id, deepest.Id}, ids(msgs))
+}
+
+func repoThreadNotFound(t *testing.T, repo domain.MessageRepository) {
+	msgs, err := repo.Thread(context.Background(), 1, domain.DefaultThreadDepth)
+	assert.Nil(t, msgs)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func repoDeleteCascades(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+	nested := reply(t, repo, first.Id, "nested reply")
+	other := seed(t, repo, "another root")[0]
+	otherReply := reply(t, repo, other.Id, "another reply")
+
+	assert.Nil(t, repo.Delete(context.Background(), root.Id))
+	for _, id := range []int64{root.Id, first.Id, nested.Id} {
+		_, err := repo.Get(context.Background(), id)
+		assertErr(t, err, http.StatusNotFound, "")
+	}
+	count, err := repo.Count(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, count)
+
+	//deleting a reply takes it off the count of its parent
+	assert.Nil(t, repo.Delete(context.Background(), otherReply.Id))
+	got, err := repo.Get(context.Background(), other.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, got.ReplyCount)
+}
+
+func repoDetach(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+	nested := reply(t, repo, first.Id, "nested reply")
+
+	assert.Nil(t, repo.Detach(context.Background(), root.Id))
+	got, err := repo.Get(context.Background(), first.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, got.ParentId)
+	assert.EqualValues(t, 1, got.ReplyCount)
+	got, err = repo.Get(context.Background(), root.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, got.ReplyCount)
+
+	//the replies of replies stay where they were, and deleting the message leaves the detached replies alone
+	got, err = repo.Get(context.Background(), nested.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, first.Id, got.ParentId)
+	assert.Nil(t, repo.Delete(context.Background(), root.Id))
+	_, err = repo.Get(context.Background(), nested.Id)
+	assert.Nil(t, err)
+}
+
+//DeleteLeaf refuses a message with replies and deletes one without, taking it off the count of its parent
+func repoDeleteLeaf(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+
+	err := repo.DeleteLeaf(context.Background(), root.Id)
+	assertErr(t, err, http.StatusConflict, error_utils.CodeHasReplies)
+	assert.EqualValues(t, 1, err.Details()["replies"])
+	_, err = repo.Get(context.Background(), first.Id)
+	assert.Nil(t, err)
+
+	assert.Nil(t, repo.DeleteLeaf(context.Background(), first.Id))
+	got, err := repo.Get(context.Background(), root.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, got.ReplyCount)
+	assert.Nil(t, repo.DeleteLeaf(context.Background(), root.Id))
+	_, err = repo.Get(context.Background(), root.Id)
+	assertErr(t, err, http.StatusNotFound, "")
+	assert.Nil(t, repo.DeleteLeaf(context.Background(), root.Id))
+}
+
+//A tenant reads nothing of the threads of another, and cannot detach or delete their replies
+func repoTenantRepliesIsolated(t *testing.T, repo domain.MessageRepository) {
+	root := seed(t, repo, "root title")[0]
+	first := reply(t, repo, root.Id, "first reply")
+	other := domain.WithTenant(context.Background(), "other")
+
+	page, err := repo.Replies(other, root.Id, domain.ListOptions{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+
+	msgs, err := repo.Thread(other, root.Id, domain.DefaultThreadDepth)
+	assert.Nil(t, msgs)
+	assertErr(t, err, http.StatusNotFound, "")
+
+	assert.Nil(t, repo.Detach(other, root.Id))
+	assert.Nil(t, repo.Delete(other, root.Id))
+	got, err := repo.Get(context.Background(), first.Id)
+	assert.Nil(t, err)
+	if assert.NotNil(t, got) {
+		assert.EqualValues(t, root.Id, got.ParentId)
+	}
+}
+
+//Tags come back sorted, nil when there are none. Update keeps them when given nil and clears them when given none.
+func repoTagsSaved(t *testing.T, repo domain.MessageRepository) {
+	tagged := tag(t, repo, "tagged title", "go", "news")
+	untagged := seed(t, repo, "untagged title")[0]
+
+	got, err := repo.Get(context.Background(), tagged.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go", "news"}, got.Tags)
+	got, err = repo.Get(context.Background(), untagged.Id)
+	assert.Nil(t, err)
+	assert.Nil(t, got.Tags)
+
+	_, err = repo.Update(context.Background(), &domain.Message{Id: tagged.Id, Title: "tagged title", Body: "new body"})
+	assert.Nil(t, err)
+	got, err = repo.Get(context.Background(), tagged.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go", "news"}, got.Tags)
+
+	_, err = repo.Update(context.Background(), &domain.Message{Id: tagged.Id, Title: "tagged title", Body: "new body", Tags: []string{"release", "news"}})
+	assert.Nil(t, err)
+	got, err = repo.Get(context.Background(), tagged.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"news", "release"}, got.Tags)
+
+	_, err = repo.Update(context.Background(), &domain.Message{Id: tagged.Id, Title: "tagged title", Body: "new body", Tags: []string{}})
+	assert.Nil(t, err)
+	got, err = repo.Get(context.Background(), tagged.Id)
+	assert.Nil(t, err)
+	assert.Nil(t, got.Tags)
+}
+
+func repoListTagFilter(t *testing.T, repo domain.MessageRepository) {
+	both := tag(t, repo, "both title", "go", "news")
+	golang := tag(t, repo, "go title", "go")
+	news := tag(t, repo, "news title", "news")
+	tag(t, repo, "other title", "other")
+	seed(t, repo, "untagged title")
+
+	page, err := repo.List(context.Background(), domain.ListOptions{Tags: []string{"go", "news"}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{both.Id, golang.Id, news.Id}, ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Tags: []string{"go", "news"}, Match: domain.MatchAll})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{both.Id}, ids(page))
+
+	//the tags combine with the title and the page
+	page, err = repo.List(context.Background(), domain.ListOptions{Tags: []string{"go"}, Title: "go", AfterId: both.Id, Limit: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{golang.Id}, ids(page))
+
+	page, err = repo.List(context.Background(), domain.ListOptions{Tags: []string{"missing"}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+}
+
+//Tags are listed by name, and only while a message carries them
+func repoTagsCounted(t *testing.T, repo domain.MessageRepository) {
+	tags, err := repo.Tags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(tags))
+
+	tag(t, repo, "first title", "news", "go")
+	tag(t, repo, "second title", "go")
+	gone := tag(t, repo, "third title", "gone")
+	assert.Nil(t, repo.Delete(context.Background(), gone.Id))
+
+	tags, err = repo.Tags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 2}, {Name: "news", Count: 1}}, tags)
+}
+
+func repoRenameTag(t *testing.T, repo domain.MessageRepository) {
+	first := tag(t, repo, "first title", "golang", "news")
+	second := tag(t, repo, "second title", "golang")
+	dropped := tag(t, repo, "third title", "go")
+	//no message carries go any more, so it does not stand in the way
+	_, err := repo.Update(context.Background(), &domain.Message{Id: dropped.Id, Title: "third title", Body: "third body", Tags: []string{}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, repo.RenameTag(context.Background(), "golang", "go"))
+	got, err := repo.Get(context.Background(), first.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go", "news"}, got.Tags)
+	got, err = repo.Get(context.Background(), second.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go"}, got.Tags)
+
+	assertErr(t, repo.RenameTag(context.Background(), "golang", "gopher"), http.StatusNotFound, "")
+	assertErr(t, repo.RenameTag(context.Background(), "go", "news"), http.StatusConflict, error_utils.CodeTagTaken)
+	//renaming a tag to its own name changes nothing
+	assert.Nil(t, repo.RenameTag(context.Background(), "go", "go"))
+}
+
+func repoMergeTags(t *testing.T, repo domain.MessageRepository) {
+	first := tag(t, repo, "first title", "go-lang", "golang")
+	second := tag(t, repo, "second title", "go", "news")
+	third := tag(t, repo, "third title", "golang")
+
+	assert.Nil(t, repo.MergeTags(context.Background(), []string{"go-lang", "golang"}, "go"))
+	for _, msg := range []domain.Message{first, third} {
+		got, err := repo.Get(context.Background(), msg.Id)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []string{"go"}, got.Tags)
+	}
+	got, err := repo.Get(context.Background(), second.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go", "news"}, got.Tags)
+
+	tags, err := repo.Tags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 3}, {Name: "news", Count: 1}}, tags)
+
+	assertErr(t, repo.MergeTags(context.Background(), []string{"golang"}, "go"), http.StatusNotFound, "")
+}
+
+//A tenant neither sees the tags of another nor renames or merges them
+func repoTenantTagsIsolated(t *testing.T, repo domain.MessageRepository) {
+	tagged := tag(t, repo, "tagged title", "go")
+	other := domain.WithTenant(context.Background(), "other")
+	_, err := repo.Create(other, &domain.Message{Title: "tagged title", Body: "other body", CreatedAt: time.Now(), Tags: []string{"news"}})
+	assert.Nil(t, err)
+
+	tags, err := repo.Tags(other)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "news", Count: 1}}, tags)
+	page, err := repo.List(other, domain.ListOptions{Tags: []string{"go"}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+
+	assertErr(t, repo.RenameTag(other, "go", "golang"), http.StatusNotFound, "")
+	assertErr(t, repo.MergeTags(other, []string{"go"}, "golang"), http.StatusNotFound, "")
+	//each tenant has its own names
+	assert.Nil(t, repo.RenameTag(other, "news", "go"))
+	got, err := repo.Get(context.Background(), tagged.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go"}, got.Tags)
+}
@@ -0,0 +1,379 @@
+package conformance
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//Service runs the contract of services.MessageService against the services returned by newService.
+//newService is called once per test and must return a service without any message.
+func Service(t *testing.T, newService func(t *testing.T) services.MessageService) {
+	for _, tt := range serviceTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newService(t))
+		})
+	}
+}
+
+var serviceTests = []struct {
+	name string
+	run  func(t *testing.T, service services.MessageService)
+}{
+	{"Create_Then_Get", serviceCreateThenGet},
+	{"Create_Invalid", serviceCreateInvalid},
+	{"Create_Title_Taken", serviceCreateTitleTaken},
+	{"Update", serviceUpdate},
+	{"Update_Missing", serviceUpdateMissing},
+	{"Delete", serviceDelete},
+	{"Delete_Missing", serviceDeleteMissing},
+	{"List", serviceList},
+	{"Import_Then_Export", serviceImportThenExport},
+	{"Import_Invalid_Policy", serviceImportInvalidPolicy},
+	{"Tenant_Isolated", serviceTenantIsolated},
+	{"Tenant_Import_Isolated", serviceTenantImportIsolated},
+	{"Reply_Then_Thread", serviceReplyThenThread},
+	{"Reply_Parent_Not_Found", serviceReplyParentNotFound},
+	{"Replies_Missing", serviceRepliesMissing},
+	{"Thread_Depth_Clamped", serviceThreadDepthClamped},
+	{"Tags_Normalized", serviceTagsNormalized},
+	{"Update_Keeps_Tags", serviceUpdateKeepsTags},
+	{"Rename_Then_Merge_Tags", serviceRenameThenMergeTags},
+	{"Tag_Invalid", serviceTagInvalid},
+}
+
+func create(t *testing.T, service services.MessageService, title string) *domain.Message {
+	msg, err := service.CreateMessage(context.Background(), &domain.Message{Title: title, Body: title + " body"})
+	if err != nil {
+		t.Fatalf("CreateMessage(%q) error = %v", title, err)
+	}
+	return msg
+}
+
+func serviceCreateThenGet(t *testing.T, service services.MessageService) {
+	created := create(t, service, "the title")
+	assert.True(t, created.Id > 0)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "the title", got.Title)
+	assert.EqualValues(t, "the title body", got.Body)
+}
+
+func serviceCreateInvalid(t *testing.T, service services.MessageService) {
+	msg, err := service.CreateMessage(context.Background(), &domain.Message{Title: "", Body: strings.Repeat("b", domain.BodyMaxLength+1)})
+	assert.Nil(t, msg)
+	assertErr(t, err, http.StatusUnprocessableEntity, error_utils.CodeValidationFailed)
+	if err != nil && assert.EqualValues(t, 2, len(err.Fields())) {
+		assert.EqualValues(t, error_utils.CodeTitleRequired, err.Fields()[0].Code)
+		assert.EqualValues(t, error_utils.CodeBodyTooLong, err.Fields()[1].Code)
+	}
+}
+
+func serviceCreateTitleTaken(t *testing.T, service services.MessageService) {
+	create(t, service, "the title")
+	_, err := service.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "another body"})
+	assertErr(t, err, http.StatusConflict, error_utils.CodeTitleTaken)
+}
+
+func serviceUpdate(t *testing.T, service services.MessageService) {
+	created := create(t, service, "the title")
+	_, err := service.UpdateMessage(context.Background(), &domain.Message{Id: created.Id, Title: "update title", Body: "update body"})
+	assert.Nil(t, err)
+
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "update title", got.Title)
+	assert.EqualValues(t, "update body", got.Body)
+}
+
+func serviceUpdateMissing(t *testing.T, service services.MessageService) {
+	_, err := service.UpdateMessage(context.Background(), &domain.Message{Id: 1, Title: "the title", Body: "the body"})
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func serviceDelete(t *testing.T, service services.MessageService) {
+	created := create(t, service, "the title")
+	assert.Nil(t, service.DeleteMessage(context.Background(), created.Id))
+	_, err := service.GetMessage(context.Background(), created.Id)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func serviceDeleteMissing(t *testing.T, service services.MessageService) {
+	assertErr(t, service.DeleteMessage(context.Background(), 1), http.StatusNotFound, "")
+}
+
+func serviceList(t *testing.T, service services.MessageService) {
+	_, err := service.GetAllMessages(context.Background())
+	assertErr(t, err, http.StatusNotFound, "")
+
+	first := create(t, service, "first title")
+	second := create(t, service, "second title")
+	all, err := service.GetAllMessages(context.Background())
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []int64{first.Id, second.Id}, ids(all))
+
+	page, err := service.ListMessages(context.Background(), domain.ListOptions{Limit: 1, AfterId: first.Id})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{second.Id}, ids(page))
+}
+
+func importReader(t *testing.T, lines ...string) message_formats.Reader {
+	r, err := message_formats.NewReader(strings.NewReader(strings.Join(lines, "\n")), message_formats.JSONLines)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	return r
+}
+
+//Every line is counted once, and the messages come back out in the order they went in
+func serviceImportThenExport(t *testing.T, service services.MessageService) {
+	create(t, service, "first title")
+	report, err := service.ImportMessages(context.Background(), importReader(t,
+		`{"title": "first title", "body": "new body"}`,
+		`{"title": "second title", "body": "second body"}`,
+		`{"title": "", "body": "third body"}`,
+		`not json`,
+	), domain.OnDuplicateSkip)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, report.Created)
+	assert.EqualValues(t, 0, report.Updated)
+	assert.EqualValues(t, 1, report.Skipped)
+	assert.EqualValues(t, 2, report.Failed)
+	assert.EqualValues(t, 2, len(report.Errors))
+
+	titles := make([]string, 0)
+	err = service.ExportMessages(context.Background(), func(msg domain.Message) error {
+		titles = append(titles, msg.Title)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"first title", "second title"}, titles)
+}
+
+func serviceImportInvalidPolicy(t *testing.T, service services.MessageService) {
+	report, err := service.ImportMessages(context.Background(), importReader(t), "ignore")
+	assert.Nil(t, report)
+	assertErr(t, err, http.StatusBadRequest, "")
+}
+
+//No method reads or changes the messages of another tenant, whose ids are as good as missing
+func serviceTenantIsolated(t *testing.T, service services.MessageService) {
+	created := create(t, service, "the title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	_, err := service.GetMessage(other, created.Id)
+	assertErr(t, err, http.StatusNotFound, "")
+	_, err = service.UpdateMessage(other, &domain.Message{Id: created.Id, Title: "update title", Body: "update body"})
+	assertErr(t, err, http.StatusNotFound, "")
+	assertErr(t, service.DeleteMessage(other, created.Id), http.StatusNotFound, "")
+	_, err = service.GetAllMessages(other)
+	assertErr(t, err, http.StatusNotFound, "")
+
+	page, err := service.ListMessages(other, domain.ListOptions{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, len(page))
+
+	exported := 0
+	assert.Nil(t, service.ExportMessages(other, func(domain.Message) error {
+		exported++
+		return nil
+	}))
+	assert.EqualValues(t, 0, exported)
+
+	//the title is free in the other tenant
+	_, err = service.CreateMessage(other, &domain.Message{Title: "the title", Body: "other body"})
+	assert.Nil(t, err)
+
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "the title", got.Title)
+	assert.EqualValues(t, "the title body", got.Body)
+}
+
+//Overwriting on import only touches the titles of the tenant importing
+func serviceTenantImportIsolated(t *testing.T, service services.MessageService) {
+	created := create(t, service, "the title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	report, err := service.ImportMessages(other, importReader(t, `{"title": "the title", "body": "other body"}`), domain.OnDuplicateOverwrite)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, report.Created)
+	assert.EqualValues(t, 0, report.Updated)
+
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "the title body", got.Body)
+}
+
+func replyTo(t *testing.T, service services.MessageService, parentId int64, title string) *domain.Message {
+	msg, err := service.CreateMessage(context.Background(), &domain.Message{ParentId: parentId, Title: title, Body: title + " body"})
+	if err != nil {
+		t.Fatalf("CreateMessage(%q) error = %v", title, err)
+	}
+	return msg
+}
+
+func serviceReplyThenThread(t *testing.T, service services.MessageService) {
+	root := create(t, service, "root title")
+	first := replyTo(t, service, root.Id, "first reply")
+	nested := replyTo(t, service, first.Id, "nested reply")
+	second := replyTo(t, service, root.Id, "second reply")
+	assert.EqualValues(t, root.Id, first.ParentId)
+
+	replies, err := service.ListReplies(context.Background(), root.Id, domain.ListOptions{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{first.Id, second.Id}, ids(replies))
+
+	thread, err := service.GetThread(context.Background(), root.Id, domain.DefaultThreadDepth)
+	assert.Nil(t, err)
+	if !assert.NotNil(t, thread) {
+		return
+	}
+	assert.EqualValues(t, root.Id, thread.Id)
+	assert.EqualValues(t, 2, thread.ReplyCount)
+	if assert.EqualValues(t, 2, len(thread.Replies)) {
+		assert.EqualValues(t, first.Id, thread.Replies[0].Id)
+		assert.EqualValues(t, second.Id, thread.Replies[1].Id)
+		if assert.EqualValues(t, 1, len(thread.Replies[0].Replies)) {
+			assert.EqualValues(t, nested.Id, thread.Replies[0].Replies[0].Id)
+		}
+	}
+}
+
+//A reply needs a parent in the tenant of the context
+func serviceReplyParentNotFound(t *testing.T, service services.MessageService) {
+	root := create(t, service, "root title")
+	other := domain.WithTenant(context.Background(), "other")
+
+	msg, err := service.CreateMessage(context.Background(), &domain.Message{ParentId: root.Id + 100, Title: "the reply", Body: "the body"})
+	assert.Nil(t, msg)
+	assertParentNotFound(t, err)
+
+	msg, err = service.CreateMessage(other, &domain.Message{ParentId: root.Id, Title: "the reply", Body: "the body"})
+	assert.Nil(t, msg)
+	assertParentNotFound(t, err)
+}
+
+func assertParentNotFound(t *testing.T, err error_utils.MessageErr) {
+	t.Helper()
+	assertErr(t, err, http.StatusUnprocessableEntity, error_utils.CodeValidationFailed)
+	if err != nil && assert.EqualValues(t, 1, len(err.Fields())) {
+		assert.EqualValues(t, "parent_id", err.Fields()[0].Field)
+		assert.EqualValues(t, error_utils.CodeParentNotFound, err.Fields()[0].Code)
+	}
+}
+
+func serviceRepliesMissing(t *testing.T, service services.MessageService) {
+	_, err := service.ListReplies(context.Background(), 1, domain.ListOptions{})
+	assertErr(t, err, http.StatusNotFound, "")
+	_, err = service.GetThread(context.Background(), 1, domain.DefaultThreadDepth)
+	assertErr(t, err, http.StatusNotFound, "")
+}
+
+func serviceThreadDepthClamped(t *testing.T, service services.MessageService) {
+	parent := create(t, service, "reply 0")
+	root := parent
+	for i := 1; i <= domain.MaxThreadDepth+1; i++ {
+		parent = replyTo(t, service, parent.Id, fmt.Sprintf("reply %d", i))
+	}
+	depth := func(thread *domain.Thread) int {
+		d := 0
+		for len(thread.Replies) > 0 {
+			thread = &thread.Replies[0]
+			d++
+		}
+		return d
+	}
+
+	thread, err := service.GetThread(context.Background(), root.Id, 0)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, depth(thread))
+	thread, err = service.GetThread(context.Background(), root.Id, domain.MaxThreadDepth+5)
+	assert.Nil(t, err)
+	assert.EqualValues(t, domain.MaxThreadDepth, depth(thread))
+}
+
+//Tags are trimmed, lowercased, deduplicated and sorted before they are saved
+func serviceTagsNormalized(t *testing.T, service services.MessageService) {
+	created, err := service.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body", Tags: []string{" News", "go", "news"}})
+	assert.Nil(t, err)
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go", "news"}, got.Tags)
+
+	page, err := service.ListMessages(context.Background(), domain.ListOptions{Tags: []string{"news"}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{created.Id}, ids(page))
+	tags, err := service.ListTags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 1}, {Name: "news", Count: 1}}, tags)
+}
+
+func serviceUpdateKeepsTags(t *testing.T, service services.MessageService) {
+	created, err := service.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body", Tags: []string{"go"}})
+	assert.Nil(t, err)
+
+	updated, err := service.UpdateMessage(context.Background(), &domain.Message{Id: created.Id, Title: "the title", Body: "new body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go"}, updated.Tags)
+
+	_, err = service.UpdateMessage(context.Background(), &domain.Message{Id: created.Id, Title: "the title", Body: "new body", Tags: []string{}})
+	assert.Nil(t, err)
+	got, err := service.GetMessage(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.Nil(t, got.Tags)
+}
+
+func serviceRenameThenMergeTags(t *testing.T, service services.MessageService) {
+	first, err := service.CreateMessage(context.Background(), &domain.Message{Title: "first title", Body: "first body", Tags: []string{"golang"}})
+	assert.Nil(t, err)
+	second, err := service.CreateMessage(context.Background(), &domain.Message{Title: "second title", Body: "second body", Tags: []string{"go-lang", "news"}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, service.RenameTag(context.Background(), " GoLang", "gopher"))
+	assertErr(t, service.RenameTag(context.Background(), "gopher", "news"), http.StatusConflict, error_utils.CodeTagTaken)
+	assert.Nil(t, service.MergeTags(context.Background(), []string{"gopher", "Go-Lang"}, "go"))
+
+	for _, msg := range []*domain.Message{first, second} {
+		got, err := service.GetMessage(context.Background(), msg.Id)
+		assert.Nil(t, err)
+		assert.Contains(t, got.Tags, "go")
+	}
+	tags, err := service.ListTags(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 2}, {Name: "news", Count: 1}}, tags)
+	assertErr(t, service.RenameTag(context.Background(), "gopher", "go-pher"), http.StatusNotFound, "")
+}
+
+func serviceTagInvalid(t *testing.T, service services.MessageService) {
+	msg, err := service.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body", Tags: []string{"two words"}})
+	assert.Nil(t, msg)
+	assertTagInvalid(t, err, "tags")
+
+	create(t, service, "tagged title")
+	assertTagInvalid(t, service.RenameTag(context.Background(), "go", "two words"), "name")
+	assertTagInvalid(t, service.MergeTags(context.Background(), []string{"go", "two words"}, "go"), "from")
+	assertTagInvalid(t, service.MergeTags(context.Background(), nil, "go"), "from")
+	assertTagInvalid(t, service.MergeTags(context.Background(), []string{"go"}, ""), "into")
+	//a name no tag can have is not found
+	assertErr(t, service.RenameTag(context.Background(), "two words", "go"), http.StatusNotFound, "")
+}
+
+func assertTagInvalid(t *testing.T, err error_utils.MessageErr, field string) {
+	t.Helper()
+	assertErr(t, err, http.StatusUnprocessableEntity, error_utils.CodeValidationFailed)
+	if err != nil && assert.EqualValues(t, 1, len(err.Fields())) {
+		assert.EqualValues(t, field, err.Fields()[0].Field)
+		assert.EqualValues(t, error_utils.CodeTagInvalid, err.Fields()[0].Code)
+	}
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"efficient-api/domain"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+//HealthController tells whether the messages can be served
+type HealthController struct {
+	repo domain.MessageRepository
+}
+
+func NewHealthController(repo domain.MessageRepository) *HealthController {
+	return &HealthController{repo: repo}
+}
+
+//Ready tells load balancers whether to send traffic here. It is not ready while the database circuit breaker is open.
+func (hc *HealthController) Ready(c *gin.Context) {
+	state := domain.BreakerClosed
+	if reporter, ok := hc.repo.(domain.StateReporter); ok {
+		state = reporter.State()
+	}
+	if state == domain.BreakerOpen {
+		c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "database": state})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "ready", "database": state})
+}
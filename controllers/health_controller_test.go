@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"efficient-api/domain"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getReady(t *testing.T, repo domain.MessageRepository) (int, map[string]string) {
+	r := gin.Default()
+	r.GET("/ready", NewHealthController(repo).Ready)
+	req, _ := http.NewRequest(http.MethodGet, "/ready", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var body map[string]string
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Nil(t, err)
+	return rr.Code, body
+}
+
+func TestReady(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	breaker := domain.NewCircuitBreaker(domain.NewMessageRepository(db), domain.BreakerSettings{
+		FailureRatio:     1,
+		MinRequests:      1,
+		Window:           time.Minute,
+		OpenTimeout:      time.Minute,
+		HalfOpenRequests: 1,
+	})
+
+	code, body := getReady(t, breaker)
+	assert.EqualValues(t, http.StatusOK, code)
+	assert.EqualValues(t, map[string]string{"status": "ready", "database": domain.BreakerClosed}, body)
+
+	//one failure opens this breaker
+	mock.ExpectPrepare("SELECT (.+) FROM messages").WillReturnError(errors.New("database is down"))
+	breaker.Get(context.Background(), 1)
+
+	code, body = getReady(t, breaker)
+	assert.EqualValues(t, http.StatusServiceUnavailable, code)
+	assert.EqualValues(t, map[string]string{"status": "unavailable", "database": domain.BreakerOpen}, body)
+}
@@ -4,11 +4,29 @@ import (
 	"efficient-api/domain"
 	"efficient-api/services"
 	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
+//MessagesController serves the messages over REST
+type MessagesController struct {
+	service  services.MessageService
+	problems error_utils.Problems
+}
+
+//NewMessagesController serves the messages of service, rendering the errors as problems says
+func NewMessagesController(service services.MessageService, problems error_utils.Problems) *MessagesController {
+	return &MessagesController{service: service, problems: problems}
+}
+
 //Since we are going for the message id more than we, we extracted this functionality to a function so we can have a DRY code.
 func getMessageId(msgIdParam string) (int64, error_utils.MessageErr) {
 	msgId, msgErr := strconv.ParseInt(msgIdParam, 10, 64)
@@ -18,75 +36,269 @@ func getMessageId(msgIdParam string) (int64, error_utils.MessageErr) {
 	return msgId, nil
 }
 
-func GetMessage(c *gin.Context) {
+//bindMessage decodes the json body into the message. Unlike ShouldBindJSON, it refuses a body going on after the json value.
+func bindMessage(c *gin.Context, message *domain.Message) error {
+	return bindJSON(c, message)
+}
+
+//bindJSON decodes the json body into v, refusing a body going on after the json value
+func bindJSON(c *gin.Context, v interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("empty body")
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the json body")
+	}
+	return nil
+}
+
+func (mc *MessagesController) GetMessage(c *gin.Context) {
 	msgId, err := getMessageId(c.Param("message_id"))
 	if err != nil {
-		c.JSON(err.Status(), err)
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
-	message, getErr := services.MessagesService.GetMessage(msgId)
+	message, getErr := mc.service.GetMessage(c.Request.Context(), msgId)
 	if getErr != nil {
-		c.JSON(getErr.Status(), getErr)
+		mc.problems.Render(c.Writer, c.Request, getErr)
 		return
 	}
 	c.JSON(http.StatusOK, message)
 }
 
-func GetAllMessages(c *gin.Context) {
-	messages, getErr := services.MessagesService.GetAllMessages()
+//getListOptions reads the optional "limit", "after", "title", "tag" and "match" query parameters; "tag" may be repeated.
+//It reports false when none of them is given, in which case every message is returned.
+func getListOptions(c *gin.Context) (domain.ListOptions, bool, error_utils.MessageErr) {
+	var opts domain.ListOptions
+	limit, hasLimit := c.GetQuery("limit")
+	after, hasAfter := c.GetQuery("after")
+	title, hasTitle := c.GetQuery("title")
+	tags, hasTags := c.GetQueryArray("tag")
+	match, hasMatch := c.GetQuery("match")
+	if !hasLimit && !hasAfter && !hasTitle && !hasTags && !hasMatch {
+		return opts, false, nil
+	}
+	if hasLimit {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > domain.MaxListLimit {
+			return opts, true, error_utils.NewBadRequestError(fmt.Sprintf("limit should be a number between 1 and %d", domain.MaxListLimit))
+		}
+		opts.Limit = l
+	}
+	if hasAfter {
+		a, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return opts, true, error_utils.NewBadRequestError("after should be a message id")
+		}
+		opts.AfterId = a
+	}
+	if hasTags {
+		filtered, err := domain.FilterTags(tags)
+		if err != nil {
+			return opts, true, err
+		}
+		opts.Tags = filtered
+	}
+	if hasMatch && match != domain.MatchAny && match != domain.MatchAll {
+		return opts, true, error_utils.NewBadRequestError("match should be one of any or all")
+	}
+	opts.Match = match
+	opts.Title = title
+	return opts, true, nil
+}
+
+func (mc *MessagesController) GetAllMessages(c *gin.Context) {
+	opts, paged, err := getListOptions(c)
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	if paged {
+		messages, listErr := mc.service.ListMessages(c.Request.Context(), opts)
+		if listErr != nil {
+			mc.problems.Render(c.Writer, c.Request, listErr)
+			return
+		}
+		c.JSON(http.StatusOK, messages)
+		return
+	}
+	messages, getErr := mc.service.GetAllMessages(c.Request.Context())
 	if getErr != nil {
-		c.JSON(getErr.Status(), getErr)
+		mc.problems.Render(c.Writer, c.Request, getErr)
 		return
 	}
 	c.JSON(http.StatusOK, messages)
 }
 
-func CreateMessage(c *gin.Context) {
+//ListReplies returns one page of the direct replies of the message, with the "limit" and "after" query parameters of GetAllMessages
+func (mc *MessagesController) ListReplies(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	opts, _, err := getListOptions(c)
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	replies, listErr := mc.service.ListReplies(c.Request.Context(), msgId, opts)
+	if listErr != nil {
+		mc.problems.Render(c.Writer, c.Request, listErr)
+		return
+	}
+	c.JSON(http.StatusOK, replies)
+}
+
+//GetThread returns the message with its replies nested, down to the optional "depth" query parameter
+func (mc *MessagesController) GetThread(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	depth := domain.DefaultThreadDepth
+	if d, ok := c.GetQuery("depth"); ok {
+		parsed, parseErr := strconv.Atoi(d)
+		if parseErr != nil || parsed <= 0 || parsed > domain.MaxThreadDepth {
+			mc.problems.Render(c.Writer, c.Request, error_utils.NewBadRequestError(fmt.Sprintf("depth should be a number between 1 and %d", domain.MaxThreadDepth)))
+			return
+		}
+		depth = parsed
+	}
+	thread, getErr := mc.service.GetThread(c.Request.Context(), msgId, depth)
+	if getErr != nil {
+		mc.problems.Render(c.Writer, c.Request, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+func (mc *MessagesController) CreateMessage(c *gin.Context) {
 	var message domain.Message
-	if err := c.ShouldBindJSON(&message); err != nil {
+	if err := bindMessage(c, &message); err != nil {
 		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
-		c.JSON(theErr.Status(), theErr)
+		mc.problems.Render(c.Writer, c.Request, theErr)
 		return
 	}
-	msg, err := services.MessagesService.CreateMessage(&message)
+	msg, err := mc.service.CreateMessage(c.Request.Context(), &message)
 	if err != nil {
-		c.JSON(err.Status(), err)
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
 	c.JSON(http.StatusCreated, msg)
 }
 
-func UpdateMessage(c *gin.Context) {
+func (mc *MessagesController) UpdateMessage(c *gin.Context) {
 	msgId, err := getMessageId(c.Param("message_id"))
 	if err != nil {
-		c.JSON(err.Status(), err)
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
 	var message domain.Message
-	if err := c.ShouldBindJSON(&message); err != nil {
+	if err := bindMessage(c, &message); err != nil {
 		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
-		c.JSON(theErr.Status(), theErr)
+		mc.problems.Render(c.Writer, c.Request, theErr)
 		return
 	}
 	message.Id = msgId
-	msg, err := services.MessagesService.UpdateMessage(&message)
+	msg, err := mc.service.UpdateMessage(c.Request.Context(), &message)
 	if err != nil {
-		c.JSON(err.Status(), err)
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
 	c.JSON(http.StatusOK, msg)
 }
 
-func DeleteMessage(c *gin.Context) {
+func (mc *MessagesController) DeleteMessage(c *gin.Context) {
 	msgId, err := getMessageId(c.Param("message_id"))
 	if err != nil {
-		c.JSON(err.Status(), err)
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
-	if err := services.MessagesService.DeleteMessage(msgId); err != nil {
-		c.JSON(err.Status(), err)
+	if err := mc.service.DeleteMessage(c.Request.Context(), msgId); err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
 		return
 	}
 	c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+//getFormat reads the "format" query parameter, falling back to the request content type and then to json lines
+func getFormat(c *gin.Context) (string, error_utils.MessageErr) {
+	format := c.Query("format")
+	if format == "" {
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			return message_formats.CSV, nil
+		}
+		return message_formats.JSONLines, nil
+	}
+	if !message_formats.IsSupported(format) {
+		return "", error_utils.NewBadRequestError("format should be jsonl or csv")
+	}
+	return format, nil
+}
+
+//ExportMessages streams every message as it is read from the database
+func (mc *MessagesController) ExportMessages(c *gin.Context) {
+	format, err := getFormat(c)
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	c.Header("Content-Type", message_formats.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="messages.%s"`, format))
+	w, _ := message_formats.NewWriter(c.Writer, format)
+	written := 0
+	exportErr := mc.service.ExportMessages(c.Request.Context(), func(msg domain.Message) error {
+		if err := w.Write(msg); err != nil {
+			return err
+		}
+		written++
+		//flush regularly so the client gets the rows as they come
+		if written%100 == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if exportErr != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			mc.problems.Render(c.Writer, c.Request, exportErr)
+			return
+		}
+		//the status line is gone already, all we can do is cut the body short
+		log.Printf("error when exporting messages after %d rows: %s", written, exportErr.Message())
+		return
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("error when exporting messages: %s", err.Error())
+	}
+}
+
+//ImportMessages reads messages from the request body, one line at a time, and reports what became of them
+func (mc *MessagesController) ImportMessages(c *gin.Context) {
+	format, err := getFormat(c)
+	if err != nil {
+		mc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	onDuplicate := c.DefaultQuery("on_duplicate", domain.OnDuplicateFail)
+	r, _ := message_formats.NewReader(c.Request.Body, format)
+	report, importErr := mc.service.ImportMessages(c.Request.Context(), r, onDuplicate)
+	if importErr != nil {
+		//an input that broke partway was imported up to there, the client is told how far
+		if report != nil {
+			importErr = error_utils.WithDetails(importErr, map[string]interface{}{"report": report})
+		}
+		mc.problems.Render(c.Writer, c.Request, importErr)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
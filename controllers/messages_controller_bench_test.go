@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//benchRouter serves the controllers on top of the real service and an in-memory repository holding n messages,
+//so that the benchmarks measure the router, the controllers and the service rather than a database
+func benchRouter(b *testing.B, n int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := domain.NewMemoryRepository()
+	for i := 0; i < n; i++ {
+		if _, err := repo.Create(context.Background(), &domain.Message{Title: fmt.Sprintf("title %d", i), Body: "body"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	mc := NewMessagesController(services.NewMessagesService(repo), error_utils.Problems{})
+	r := gin.New()
+	r.GET("/messages/:message_id", mc.GetMessage)
+	r.GET("/messages", mc.GetAllMessages)
+	r.POST("/messages", mc.CreateMessage)
+	r.PUT("/messages/:message_id", mc.UpdateMessage)
+	r.DELETE("/messages/:message_id", mc.DeleteMessage)
+	r.GET("/messages/export", mc.ExportMessages)
+	r.POST("/messages/import", mc.ImportMessages)
+	return r
+}
+
+//serve fails the benchmark when the request is not answered with the expected status
+func serve(b *testing.B, r *gin.Engine, req *http.Request, status int) {
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != status {
+		b.Fatalf("%s %s answered %d, expected %d: %s", req.Method, req.URL, rr.Code, status, rr.Body.String())
+	}
+}
+
+func BenchmarkMessagesController_GetMessage(b *testing.B) {
+	r := benchRouter(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/messages/%d", i%100+1), nil)
+		serve(b, r, req, http.StatusOK)
+	}
+}
+
+func BenchmarkMessagesController_GetAllMessages(b *testing.B) {
+	r := benchRouter(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/messages?limit=20", nil)
+		serve(b, r, req, http.StatusOK)
+	}
+}
+
+func BenchmarkMessagesController_CreateMessage(b *testing.B) {
+	r := benchRouter(b, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodPost, "/messages", strings.NewReader(fmt.Sprintf(`{"title": "title %d", "body": "body"}`, i)))
+		serve(b, r, req, http.StatusCreated)
+	}
+}
+
+func BenchmarkMessagesController_UpdateMessage(b *testing.B) {
+	r := benchRouter(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodPut, "/messages/1", strings.NewReader(fmt.Sprintf(`{"title": "updated %d", "body": "body"}`, i)))
+		serve(b, r, req, http.StatusOK)
+	}
+}
+
+func BenchmarkMessagesController_DeleteMessage(b *testing.B) {
+	r := benchRouter(b, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("/messages/%d", i+1), nil)
+		serve(b, r, req, http.StatusOK)
+	}
+}
+
+func BenchmarkMessagesController_ExportMessages(b *testing.B) {
+	r := benchRouter(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/messages/export?format=jsonl", nil)
+		serve(b, r, req, http.StatusOK)
+	}
+}
+
+func BenchmarkMessagesController_ImportMessages(b *testing.B) {
+	r := benchRouter(b, 0)
+	var lines bytes.Buffer
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&lines, "{\"title\": \"title %d\", \"body\": \"body\"}\n", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		//after the first import, every line is a duplicate that is overwritten
+		req, _ := http.NewRequest(http.MethodPost, "/messages/import?format=jsonl&on_duplicate=overwrite", bytes.NewReader(lines.Bytes()))
+		serve(b, r, req, http.StatusOK)
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+//Run a target with e.g. go test -run xxx -fuzz FuzzGetMessageId ./controllers
+func FuzzGetMessageId(f *testing.F) {
+	for _, seed := range []string{"1", "0", "-1", "+7", "007", "unknwon", "", " 1", "1.5", "9223372036854775807", "9223372036854775808", "0x10"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, param string) {
+		msgId, err := getMessageId(param)
+		if err != nil {
+			if err.Status() != http.StatusBadRequest || err.Message() != "message id should be a number" {
+				t.Fatalf("getMessageId(%q) failed with %d %q", param, err.Status(), err.Message())
+			}
+			return
+		}
+		//an accepted id is the decimal number written in the param
+		if again, parseErr := strconv.ParseInt(strconv.FormatInt(msgId, 10), 10, 64); parseErr != nil || again != msgId {
+			t.Fatalf("getMessageId(%q) returned %d, which does not round trip", param, msgId)
+		}
+		if back, _ := getMessageId(strconv.FormatInt(msgId, 10)); back != msgId {
+			t.Fatalf("getMessageId(%q) returned %d, but %d once written back", param, msgId, back)
+		}
+	})
+}
+
+//fuzzRouter serves the controllers on top of the real service and an in-memory repository holding one message
+func fuzzRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	repo := domain.NewMemoryRepository()
+	if _, err := repo.Create(context.Background(), &domain.Message{Title: "the title", Body: "the body"}); err != nil {
+		t.Fatal(err)
+	}
+	mc := NewMessagesController(services.NewMessagesService(repo), error_utils.Problems{})
+	r := gin.New()
+	r.POST("/messages", mc.CreateMessage)
+	r.PUT("/messages/:message_id", mc.UpdateMessage)
+	return r
+}
+
+//checkSaved fails the test when the request did not end as a client error or a saved message matching what was sent
+func checkSaved(t *testing.T, body string, rr *httptest.ResponseRecorder, status int) {
+	switch {
+	case rr.Code == status:
+	case rr.Code >= http.StatusBadRequest && rr.Code < http.StatusInternalServerError:
+		return
+	default:
+		t.Fatalf("%q was answered with %d: %s", body, rr.Code, rr.Body.String())
+	}
+	if !json.Valid([]byte(body)) {
+		t.Fatalf("%q is not json, but was answered with %d", body, rr.Code)
+	}
+	var sent, saved domain.Message
+	json.Unmarshal([]byte(body), &sent)
+	if err := json.Unmarshal(rr.Body.Bytes(), &saved); err != nil {
+		t.Fatalf("cannot decode the response %s: %v", rr.Body.String(), err)
+	}
+	//what was saved is what was sent, once validated
+	if err := sent.Validate(domain.DefaultRules()); err != nil {
+		t.Fatalf("%q was saved although it is not valid: %s", body, err.Message())
+	}
+	if saved.Title != sent.Title || saved.Body != sent.Body {
+		t.Fatalf("%q was saved as %q, %q", body, saved.Title, saved.Body)
+	}
+}
+
+func addBodySeeds(f *testing.F) {
+	for _, seed := range []string{
+		`{"title":"the title", "body": "the body"}`,
+		`{"title":"", "body": "the body"}`,
+		`{"title": 12345, "body": "the body"}`,
+		`{"title": "the title", "body": 123453 }`,
+		`{"title": "  a title  ", "body": "café\nline"}`,
+		`{"id": 5, "title": "other", "body": "body", "created_at": "2020-01-01T00:00:00Z"}`,
+		`{"title": "\u0000", "body": "\ud800"}`,
+		`[]`,
+		`null`,
+		``,
+		`{`,
+	} {
+		f.Add(seed)
+	}
+}
+
+func FuzzCreateMessage(f *testing.F) {
+	addBodySeeds(f)
+	f.Fuzz(func(t *testing.T, body string) {
+		r := fuzzRouter(t)
+		req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		checkSaved(t, body, rr, http.StatusCreated)
+	})
+}
+
+func FuzzUpdateMessage(f *testing.F) {
+	addBodySeeds(f)
+	f.Fuzz(func(t *testing.T, body string) {
+		r := fuzzRouter(t)
+		req, _ := http.NewRequest(http.MethodPut, "/messages/1", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		checkSaved(t, body, rr, http.StatusOK)
+	})
+}
@@ -2,60 +2,38 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"efficient-api/domain"
-	"efficient-api/services"
+	"efficient-api/messagestest"
 	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
-var (
-	getMessageService func(msgId int64) (*domain.Message, error_utils.MessageErr)
-	createMessageService func(message *domain.Message) (*domain.Message, error_utils.MessageErr)
-	updateMessageService func(message *domain.Message) (*domain.Message, error_utils.MessageErr)
-	deleteMessageService func(msgId int64) error_utils.MessageErr
-	getAllMessageService func() ([]domain.Message, error_utils.MessageErr)
-)
-
-type serviceMock struct {}
-
-func (sm *serviceMock) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
-	return getMessageService(msgId)
-}
-func (sm *serviceMock) CreateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
-	return createMessageService(message)
-}
-func (sm *serviceMock) UpdateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
-	return updateMessageService(message)
-}
-func (sm *serviceMock) DeleteMessage(msgId int64) error_utils.MessageErr {
-	return deleteMessageService(msgId)
-}
-func (sm *serviceMock) GetAllMessages() ([]domain.Message, error_utils.MessageErr) {
-	return getAllMessageService()
-}
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of "GetMessage" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestGetMessage_Success(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "the title",
-			Body:      "the body",
+			Id:    1,
+			Title: "the title",
+			Body:  "the body",
 		}, nil
 	}
 	msgId := "1" //this has to be a string, because is passed through the url
 	r := gin.Default()
 	req, _ := http.NewRequest(http.MethodGet, "/messages/"+msgId, nil)
 	rr := httptest.NewRecorder()
-	r.GET("/messages/:message_id", GetMessage)
+	r.GET("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).GetMessage)
 	r.ServeHTTP(rr, req)
 
 	var message domain.Message
@@ -70,11 +48,12 @@ func TestGetMessage_Success(t *testing.T) {
 
 //When an invalid id id passed. No need to mock the service here because we will never call it
 func TestGetMessage_Invalid_Id(t *testing.T) {
+	t.Parallel()
 	msgId := "abc" //this has to be a string, because is passed through the url
 	r := gin.Default()
 	req, _ := http.NewRequest(http.MethodGet, "/messages/"+msgId, nil)
 	rr := httptest.NewRecorder()
-	r.GET("/messages/:message_id", GetMessage)
+	r.GET("/messages/:message_id", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).GetMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -87,15 +66,16 @@ func TestGetMessage_Invalid_Id(t *testing.T) {
 
 //We will call the service method here, so we need to mock it
 func TestGetMessage_Message_Not_Found(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewNotFoundError("message not found")
 	}
 	msgId := "1" //valid id
 	r := gin.Default()
 	req, _ := http.NewRequest(http.MethodGet, "/messages/"+msgId, nil)
 	rr := httptest.NewRecorder()
-	r.GET("/messages/:message_id", GetMessage)
+	r.GET("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).GetMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -106,18 +86,46 @@ func TestGetMessage_Message_Not_Found(t *testing.T) {
 	assert.EqualValues(t, "not_found", apiErr.Error())
 }
 
+//The client asks for an RFC 7807 problem document
+func TestGetMessage_Message_Not_Found_Problem(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("message not found")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set("Accept", error_utils.ProblemContentType)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).GetMessage)
+	r.ServeHTTP(rr, req)
+
+	var problem map[string]interface{}
+	err := json.Unmarshal(rr.Body.Bytes(), &problem)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+	assert.EqualValues(t, error_utils.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, "about:blank", problem["type"])
+	assert.EqualValues(t, "Not Found", problem["title"])
+	assert.EqualValues(t, 404, problem["status"])
+	assert.EqualValues(t, "message not found", problem["detail"])
+	assert.EqualValues(t, "/messages/1", problem["instance"])
+	assert.EqualValues(t, "not_found", problem["error"])
+}
+
 //We will call the service method here, so we need to mock it
 //If for any reason, we could not get the message
 func TestGetMessage_Message_Database_Error(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("database error")
 	}
 	msgId := "1" //valid id
 	r := gin.Default()
 	req, _ := http.NewRequest(http.MethodGet, "/messages/"+msgId, nil)
 	rr := httptest.NewRecorder()
-	r.GET("/messages/:message_id", GetMessage)
+	r.GET("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).GetMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -127,22 +135,22 @@ func TestGetMessage_Message_Database_Error(t *testing.T) {
 	assert.EqualValues(t, "database error", apiErr.Message())
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
+
 ///////////////////////////////////////////////////////////////
 // End of "GetMessage" test cases
 ///////////////////////////////////////////////////////////////
 
-
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of "CreateMessage" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestCreateMessage_Success(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	createMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "the title",
-			Body:      "the body",
+			Id:    1,
+			Title: "the title",
+			Body:  "the body",
 		}, nil
 	}
 	jsonBody := `{"title": "the title", "body": "the body"}`
@@ -152,7 +160,7 @@ func TestCreateMessage_Success(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.POST("/messages", CreateMessage)
+	r.POST("/messages", NewMessagesController(sm, error_utils.Problems{}).CreateMessage)
 	r.ServeHTTP(rr, req)
 
 	var message domain.Message
@@ -166,6 +174,7 @@ func TestCreateMessage_Success(t *testing.T) {
 }
 
 func TestCreateMessage_Invalid_Json(t *testing.T) {
+	t.Parallel()
 	inputJson := `{"title": 1234, "body": "the body"}`
 	r := gin.Default()
 	req, err := http.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(inputJson))
@@ -173,7 +182,7 @@ func TestCreateMessage_Invalid_Json(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.POST("/messages", CreateMessage)
+	r.POST("/messages", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).CreateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -185,10 +194,33 @@ func TestCreateMessage_Invalid_Json(t *testing.T) {
 	assert.EqualValues(t, "invalid_request", apiErr.Error())
 }
 
+//Found by FuzzCreateMessage: ShouldBindJSON used to ignore what comes after the json value
+func TestCreateMessage_Trailing_Data(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	inputJson := `{"title": "the title", "body": "the body"} and more`
+	r := gin.Default()
+	req, err := http.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(inputJson))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+	r.POST("/messages", NewMessagesController(sm, error_utils.Problems{}).CreateMessage)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, apiErr.Status())
+	assert.EqualValues(t, "invalid json body", apiErr.Message())
+	sm.AssertNotCalled(t, "CreateMessage")
+}
+
 //This test is not really necessary here, because it has been handled in the service test
 func TestCreateMessage_Empty_Body(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	createMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid body")
 	}
 	inputJson := `{"title": "the title", "body": ""}`
@@ -198,7 +230,7 @@ func TestCreateMessage_Empty_Body(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.POST("/messages", CreateMessage)
+	r.POST("/messages", NewMessagesController(sm, error_utils.Problems{}).CreateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -209,10 +241,12 @@ func TestCreateMessage_Empty_Body(t *testing.T) {
 	assert.EqualValues(t, "Please enter a valid body", apiErr.Message())
 	assert.EqualValues(t, "invalid_request", apiErr.Error())
 }
+
 //This test is not really necessary here, because it has been handled in the service test
 func TestCreateMessage_Empty_Title(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	createMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid title")
 	}
 	inputJson := `{"title": "", "body": "the body"}`
@@ -222,7 +256,7 @@ func TestCreateMessage_Empty_Title(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.POST("/messages", CreateMessage)
+	r.POST("/messages", NewMessagesController(sm, error_utils.Problems{}).CreateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -233,22 +267,47 @@ func TestCreateMessage_Empty_Title(t *testing.T) {
 	assert.EqualValues(t, "Please enter a valid title", apiErr.Message())
 	assert.EqualValues(t, "invalid_request", apiErr.Error())
 }
-///////////////////////////////////////////////////////////////
-// End of "CreateMessage" test cases
-///////////////////////////////////////////////////////////////
 
+//The field errors and code survive the trip through the json body
+func TestCreateMessage_Field_Errors(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewValidationError(
+			error_utils.FieldError{Field: "title", Code: error_utils.CodeTitleRequired, Message: "Please enter a valid title"},
+			error_utils.FieldError{Field: "body", Code: error_utils.CodeBodyRequired, Message: "Please enter a valid body"},
+		)
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(`{"title": "", "body": ""}`))
+	rr := httptest.NewRecorder()
+	r.POST("/messages", NewMessagesController(sm, error_utils.Problems{}).CreateMessage)
+	r.ServeHTTP(rr, req)
 
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, apiErr.Status())
+	assert.EqualValues(t, "Please enter a valid title; Please enter a valid body", apiErr.Message())
+	assert.EqualValues(t, error_utils.CodeValidationFailed, apiErr.Code())
+	assert.EqualValues(t, 2, len(apiErr.Fields()))
+	assert.EqualValues(t, error_utils.CodeBodyRequired, apiErr.Fields()[1].Code)
+}
 
 ///////////////////////////////////////////////////////////////
-// Start of "UpdateMessage" test cases
+// End of "CreateMessage" test cases
 ///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "UpdateMessage" test cases
+// /////////////////////////////////////////////////////////////
 func TestUpdateMessage_Success(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	updateMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "update title",
-			Body:      "update body",
+			Id:    1,
+			Title: "update title",
+			Body:  "update body",
 		}, nil
 	}
 	jsonBody := `{"title": "update title", "body": "update body"}`
@@ -259,7 +318,7 @@ func TestUpdateMessage_Success(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 
 	var message domain.Message
@@ -274,6 +333,7 @@ func TestUpdateMessage_Success(t *testing.T) {
 
 //We dont need to mock the service method here, because we wont call it
 func TestUpdateMessage_Invalid_Id(t *testing.T) {
+	t.Parallel()
 	jsonBody := `{"title": "update title", "body": "update body"}`
 	r := gin.Default()
 	id := "abc"
@@ -282,7 +342,7 @@ func TestUpdateMessage_Invalid_Id(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -295,6 +355,7 @@ func TestUpdateMessage_Invalid_Id(t *testing.T) {
 
 //When for instance an integer is provided instead of a string
 func TestUpdateMessage_Invalid_Json(t *testing.T) {
+	t.Parallel()
 	inputJson := `{"title": 1234, "body": "the body"}`
 	r := gin.Default()
 	id := "1"
@@ -303,7 +364,7 @@ func TestUpdateMessage_Invalid_Json(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -315,11 +376,11 @@ func TestUpdateMessage_Invalid_Json(t *testing.T) {
 	assert.EqualValues(t, "invalid_request", apiErr.Error())
 }
 
-
 //This test is not really necessary here, because it has been handled in the service test
 func TestUpdateMessage_Empty_Body(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	updateMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid body")
 	}
 	inputJson := `{"title": "the title", "body": ""}`
@@ -330,7 +391,7 @@ func TestUpdateMessage_Empty_Body(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
 	assert.Nil(t, err)
@@ -339,10 +400,12 @@ func TestUpdateMessage_Empty_Body(t *testing.T) {
 	assert.EqualValues(t, "Please enter a valid body", apiErr.Message())
 	assert.EqualValues(t, "invalid_request", apiErr.Error())
 }
+
 //This test is not really necessary here, because it has been handled in the service test
 func TestUpdateMessage_Empty_Title(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	updateMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid title")
 	}
 	inputJson := `{"title": "", "body": "the body"}`
@@ -353,7 +416,7 @@ func TestUpdateMessage_Empty_Title(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
 	assert.Nil(t, err)
@@ -365,8 +428,9 @@ func TestUpdateMessage_Empty_Title(t *testing.T) {
 
 //Other errors can happen when we try to update the message
 func TestUpdateMessage_Error_Updating(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	updateMessageService = func(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error when updating message")
 	}
 	jsonBody := `{"title": "update title", "body": "update body"}`
@@ -377,7 +441,7 @@ func TestUpdateMessage_Error_Updating(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.PUT("/messages/:message_id", UpdateMessage)
+	r.PUT("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).UpdateMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -388,18 +452,18 @@ func TestUpdateMessage_Error_Updating(t *testing.T) {
 	assert.EqualValues(t, "error when updating message", apiErr.Message())
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
+
 ///////////////////////////////////////////////////////////////
 // End of "UpdateMessage" test cases
 ///////////////////////////////////////////////////////////////
 
-
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of "DeleteMessage" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestDeleteMessage_Success(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	deleteMessageService = func(msg int64) error_utils.MessageErr {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.DeleteMessageFunc = func(ctx context.Context, msg int64) error_utils.MessageErr {
 		return nil
 	}
 	r := gin.Default()
@@ -409,7 +473,7 @@ func TestDeleteMessage_Success(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.DELETE("/messages/:message_id", DeleteMessage)
+	r.DELETE("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).DeleteMessage)
 	r.ServeHTTP(rr, req)
 
 	var response = make(map[string]string)
@@ -423,6 +487,7 @@ func TestDeleteMessage_Success(t *testing.T) {
 
 //We wont call the service Delete method here, so no need to mock it
 func TestDeleteMessage_Invalid_Id(t *testing.T) {
+	t.Parallel()
 
 	r := gin.Default()
 	id := "abc"
@@ -431,7 +496,7 @@ func TestDeleteMessage_Invalid_Id(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.DELETE("/messages/:message_id", DeleteMessage)
+	r.DELETE("/messages/:message_id", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).DeleteMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -448,8 +513,9 @@ func TestDeleteMessage_Invalid_Id(t *testing.T) {
 //If for any reason, our update didnt happen(e.g server error, etc), This is an error from the service, but the controller conditions where met.
 //Maybe the message does not exist, or the server timeout
 func TestDeleteMessage_Failure(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	deleteMessageService = func(msg int64) error_utils.MessageErr {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.DeleteMessageFunc = func(ctx context.Context, msg int64) error_utils.MessageErr {
 		return error_utils.NewInternalServerError("error deleting message")
 	}
 	r := gin.Default()
@@ -459,7 +525,7 @@ func TestDeleteMessage_Failure(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.DELETE("/messages/:message_id", DeleteMessage)
+	r.DELETE("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).DeleteMessage)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -472,27 +538,28 @@ func TestDeleteMessage_Failure(t *testing.T) {
 	assert.EqualValues(t, "error deleting message", apiErr.Message())
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
+
 ///////////////////////////////////////////////////////////////
 // End of "DeleteMessage" test cases
 ///////////////////////////////////////////////////////////////
 
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of "GetAllMessages" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestGetAllMessages_Success(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	getAllMessageService = func() ([]domain.Message, error_utils.MessageErr) {
-		 return []domain.Message{
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetAllMessagesFunc = func(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{
 			{
-				Id:        1,
-				Title:     "first title",
-				Body:      "first body",
+				Id:    1,
+				Title: "first title",
+				Body:  "first body",
 			},
 			{
-				Id:        2,
-				Title:     "second title",
-				Body:      "second body",
+				Id:    2,
+				Title: "second title",
+				Body:  "second body",
 			},
 		}, nil
 	}
@@ -502,7 +569,7 @@ func TestGetAllMessages_Success(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.GET("/messages", GetAllMessages)
+	r.GET("/messages", NewMessagesController(sm, error_utils.Problems{}).GetAllMessages)
 	r.ServeHTTP(rr, req)
 
 	var messages []domain.Message
@@ -522,8 +589,9 @@ func TestGetAllMessages_Success(t *testing.T) {
 
 //For any reason we could not get the messages
 func TestGetAllMessages_Failure(t *testing.T) {
-	services.MessagesService = &serviceMock{}
-	getAllMessageService = func() ([]domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetAllMessagesFunc = func(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error getting messages")
 	}
 	r := gin.Default()
@@ -532,7 +600,7 @@ func TestGetAllMessages_Failure(t *testing.T) {
 		t.Errorf("this is the error: %v\n", err)
 	}
 	rr := httptest.NewRecorder()
-	r.GET("/messages", GetAllMessages)
+	r.GET("/messages", NewMessagesController(sm, error_utils.Problems{}).GetAllMessages)
 	r.ServeHTTP(rr, req)
 
 	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
@@ -542,3 +610,329 @@ func TestGetAllMessages_Failure(t *testing.T) {
 	assert.EqualValues(t, "server_error", apiErr.Error())
 	assert.EqualValues(t, http.StatusInternalServerError, apiErr.Status())
 }
+
+//When paging parameters are given, a single page is returned and an empty page is not an error
+func TestGetAllMessages_Paged_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	var gotOpts domain.ListOptions
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		gotOpts = opts
+		return []domain.Message{}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?limit=10&after=5&title=first", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", NewMessagesController(sm, error_utils.Problems{}).GetAllMessages)
+	r.ServeHTTP(rr, req)
+
+	var messages []domain.Message
+	err := json.Unmarshal(rr.Body.Bytes(), &messages)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 0, len(messages))
+	assert.EqualValues(t, domain.ListOptions{Limit: 10, AfterId: 5, Title: "first"}, gotOpts)
+}
+
+func TestGetAllMessages_Tags_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Title: "the title", Tags: []string{"go", "news"}}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?tag=News&tag=go&tag=news&match=all", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", NewMessagesController(sm, error_utils.Problems{}).GetAllMessages)
+	r.ServeHTTP(rr, req)
+
+	var messages []domain.Message
+	err := json.Unmarshal(rr.Body.Bytes(), &messages)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	if assert.EqualValues(t, 1, len(messages)) {
+		assert.EqualValues(t, []string{"go", "news"}, messages[0].Tags)
+	}
+	//the tags alone make the request a paged one, and they are normalized
+	sm.AssertCalled(t, "ListMessages", domain.ListOptions{Tags: []string{"go", "news"}, Match: domain.MatchAll})
+}
+
+func TestGetAllMessages_Paged_Invalid_Params(t *testing.T) {
+	t.Parallel()
+	tooManyTags := "tag=" + strings.Join(strings.Split("a b c d e f g h i j k", " "), "&tag=")
+	for _, query := range []string{"limit=abc", "limit=0", "limit=101", "after=abc", "tag=two+words", "tag=", "match=some", tooManyTags} {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages?"+query, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).GetAllMessages)
+		r.ServeHTTP(rr, req)
+
+		apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+		assert.EqualValues(t, "bad_request", apiErr.Error())
+	}
+}
+
+///////////////////////////////////////////////////////////////
+// End of "GetAllMessages" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "ListReplies" test cases
+// /////////////////////////////////////////////////////////////
+func TestListReplies_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListRepliesFunc = func(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 2, ParentId: 1, Title: "the reply", Body: "the body"}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/replies?limit=10&after=1", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/replies", NewMessagesController(sm, error_utils.Problems{}).ListReplies)
+	r.ServeHTTP(rr, req)
+
+	var messages []domain.Message
+	err := json.Unmarshal(rr.Body.Bytes(), &messages)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 1, len(messages))
+	assert.EqualValues(t, 1, messages[0].ParentId)
+	sm.AssertCalled(t, "ListReplies", int64(1), domain.ListOptions{Limit: 10, AfterId: 1})
+}
+
+func TestListReplies_Invalid_Params(t *testing.T) {
+	t.Parallel()
+	for _, target := range []string{"/messages/abc/replies", "/messages/1/replies?limit=0", "/messages/1/replies?after=abc"} {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages/:message_id/replies", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).ListReplies)
+		r.ServeHTTP(rr, req)
+
+		apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	}
+}
+
+func TestListReplies_Message_Not_Found(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListRepliesFunc = func(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/replies", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/replies", NewMessagesController(sm, error_utils.Problems{}).ListReplies)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, apiErr.Status())
+}
+
+///////////////////////////////////////////////////////////////
+// End of "ListReplies" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "GetThread" test cases
+// /////////////////////////////////////////////////////////////
+func TestGetThread_Success(t *testing.T) {
+	t.Parallel()
+	for query, depth := range map[string]int{"": domain.DefaultThreadDepth, "?depth=2": 2} {
+		sm := &messagestest.Service{}
+		sm.GetThreadFunc = func(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr) {
+			return domain.NewThread(1, []domain.Message{{Id: 1, Title: "the title", ReplyCount: 1}, {Id: 2, ParentId: 1, Title: "the reply"}}), nil
+		}
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages/1/thread"+query, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages/:message_id/thread", NewMessagesController(sm, error_utils.Problems{}).GetThread)
+		r.ServeHTTP(rr, req)
+
+		var thread domain.Thread
+		err := json.Unmarshal(rr.Body.Bytes(), &thread)
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusOK, rr.Code)
+		assert.EqualValues(t, 1, thread.Id)
+		if assert.EqualValues(t, 1, len(thread.Replies)) {
+			assert.EqualValues(t, 2, thread.Replies[0].Id)
+			assert.EqualValues(t, 0, len(thread.Replies[0].Replies))
+		}
+		sm.AssertCalled(t, "GetThread", int64(1), depth)
+	}
+}
+
+func TestGetThread_Invalid_Depth(t *testing.T) {
+	t.Parallel()
+	for _, query := range []string{"depth=abc", "depth=0", "depth=21"} {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages/1/thread?"+query, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages/:message_id/thread", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).GetThread)
+		r.ServeHTTP(rr, req)
+
+		apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+		assert.EqualValues(t, "depth should be a number between 1 and 20", apiErr.Message())
+	}
+}
+
+///////////////////////////////////////////////////////////////
+// End of "GetThread" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "ExportMessages" test cases
+// /////////////////////////////////////////////////////////////
+func TestExportMessages_JSONLines(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		fn(domain.Message{Id: 1, Title: "first title", Body: "first body"})
+		fn(domain.Message{Id: 2, Title: "second title", Body: "second body"})
+		return nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/export", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/export", NewMessagesController(sm, error_utils.Problems{}).ExportMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.EqualValues(t, 2, len(lines))
+	var message domain.Message
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &message))
+	assert.EqualValues(t, "second title", message.Title)
+}
+
+func TestExportMessages_CSV(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		fn(domain.Message{Id: 1, Title: "first title", Body: "first body"})
+		return nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/export?format=csv", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/export", NewMessagesController(sm, error_utils.Problems{}).ExportMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "id,title,body,created_at,parent_id,tags\n1,first title,first body,"))
+}
+
+func TestExportMessages_Invalid_Format(t *testing.T) {
+	t.Parallel()
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/export?format=xml", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/export", NewMessagesController(&messagestest.Service{}, error_utils.Problems{}).ExportMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "format should be jsonl or csv", apiErr.Message())
+}
+
+//When the export fails before anything was written, the error is reported as usual
+func TestExportMessages_Failure(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		return error_utils.NewInternalServerError("error getting messages")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/export", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/export", NewMessagesController(sm, error_utils.Problems{}).ExportMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusInternalServerError, apiErr.Status())
+	assert.EqualValues(t, "error getting messages", apiErr.Message())
+}
+
+///////////////////////////////////////////////////////////////
+// End of "ExportMessages" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "ImportMessages" test cases
+// /////////////////////////////////////////////////////////////
+func TestImportMessages_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ImportMessagesFunc = func(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+		assert.EqualValues(t, domain.OnDuplicateSkip, onDuplicate)
+		msg, err := r.Read()
+		assert.Nil(t, err)
+		assert.EqualValues(t, "the title", msg.Title)
+		return &domain.ImportReport{Created: 1, Errors: []domain.ImportError{}}, nil
+	}
+	body := "id,title,body,created_at\n,the title,the body,\n"
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages/import?on_duplicate=skip", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	r.POST("/messages/import", NewMessagesController(sm, error_utils.Problems{}).ImportMessages)
+	r.ServeHTTP(rr, req)
+
+	var report domain.ImportReport
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 1, report.Created)
+}
+
+func TestImportMessages_Invalid_Duplicate_Policy(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ImportMessagesFunc = func(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+		return nil, error_utils.NewBadRequestError("on_duplicate should be one of skip, overwrite or fail")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages/import?on_duplicate=ignore", bytes.NewBufferString(""))
+	rr := httptest.NewRecorder()
+	r.POST("/messages/import", NewMessagesController(sm, error_utils.Problems{}).ImportMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+}
+
+//An input that breaks partway is answered with the error and the report of the lines saved before it
+func TestImportMessages_Unreadable_Input(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ImportMessagesFunc = func(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+		return &domain.ImportReport{Created: 2, Errors: []domain.ImportError{}}, error_utils.NewRequestTooLargeError("line too long")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages/import", bytes.NewBufferString(""))
+	rr := httptest.NewRecorder()
+	r.POST("/messages/import", NewMessagesController(sm, error_utils.Problems{}).ImportMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, apiErr.Status())
+	report := apiErr.Details()["report"].(map[string]interface{})
+	assert.EqualValues(t, 2, report["created"])
+}
+
+///////////////////////////////////////////////////////////////
+// End of "ImportMessages" test cases
+///////////////////////////////////////////////////////////////
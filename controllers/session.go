@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"efficient-api/domain"
+	"github.com/gin-gonic/gin"
+)
+
+//SessionHeader lets a client that sits behind a shared address, or moves between addresses, say who it is
+const SessionHeader = "X-Session-Id"
+
+//Session tags the request with the client it comes from, so that a client reads its own writes
+//even while the read replicas are behind. Clients that do not send SessionHeader are told apart by address.
+func Session(c *gin.Context) {
+	session := c.GetHeader(SessionHeader)
+	if session == "" {
+		session = c.ClientIP()
+	}
+	c.Request = c.Request.WithContext(domain.WithSession(c.Request.Context(), session))
+	c.Next()
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+//TagsController serves the tags of the messages over REST
+type TagsController struct {
+	service  services.MessageService
+	problems error_utils.Problems
+}
+
+//NewTagsController serves the tags of service, rendering the errors as problems says
+func NewTagsController(service services.MessageService, problems error_utils.Problems) *TagsController {
+	return &TagsController{service: service, problems: problems}
+}
+
+//ListTags returns the tags carried by the messages with the number of messages carrying each
+func (tc *TagsController) ListTags(c *gin.Context) {
+	tags, err := tc.service.ListTags(c.Request.Context())
+	if err != nil {
+		tc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+//RenameTag gives the tag of the path the name of the body, {"name": "new-name"}
+func (tc *TagsController) RenameTag(c *gin.Context) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		tc.problems.Render(c.Writer, c.Request, error_utils.NewUnprocessibleEntityError("invalid json body"))
+		return
+	}
+	if err := tc.service.RenameTag(c.Request.Context(), c.Param("tag"), body.Name); err != nil {
+		tc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "renamed"})
+}
+
+//MergeTags replaces the tags of the body with another, {"from": ["golang", "go-lang"], "into": "go"}
+func (tc *TagsController) MergeTags(c *gin.Context) {
+	var body struct {
+		From []string `json:"from"`
+		Into string   `json:"into"`
+	}
+	if err := bindJSON(c, &body); err != nil {
+		tc.problems.Render(c.Writer, c.Request, error_utils.NewUnprocessibleEntityError("invalid json body"))
+		return
+	}
+	if err := tc.service.MergeTags(c.Request.Context(), body.From, body.Into); err != nil {
+		tc.problems.Render(c.Writer, c.Request, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "merged"})
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/error_formats"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////
+// Start of "ListTags" test cases
+///////////////////////////////////////////////////////////////
+func TestListTags_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListTagsFunc = func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+		return []domain.TagCount{{Name: "go", Count: 2}, {Name: "news", Count: 1}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/tags", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/tags", NewTagsController(sm, error_utils.Problems{}).ListTags)
+	r.ServeHTTP(rr, req)
+
+	var tags []domain.TagCount
+	err := json.Unmarshal(rr.Body.Bytes(), &tags)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, []domain.TagCount{{Name: "go", Count: 2}, {Name: "news", Count: 1}}, tags)
+}
+
+func TestListTags_Failure(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListTagsFunc = func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error getting tags")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/tags", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/tags", NewTagsController(sm, error_utils.Problems{}).ListTags)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusInternalServerError, apiErr.Status())
+	assert.EqualValues(t, "error getting tags", apiErr.Message())
+}
+///////////////////////////////////////////////////////////////
+// End of "ListTags" test cases
+///////////////////////////////////////////////////////////////
+
+
+///////////////////////////////////////////////////////////////
+// Start of "RenameTag" test cases
+///////////////////////////////////////////////////////////////
+func TestRenameTag_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.RenameTagFunc = func(ctx context.Context, from, to string) error_utils.MessageErr {
+		return nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/tags/golang", strings.NewReader(`{"name": "go"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/tags/:tag", NewTagsController(sm, error_utils.Problems{}).RenameTag)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "renamed"}`, rr.Body.String())
+	sm.AssertCalled(t, "RenameTag", "golang", "go")
+}
+
+func TestRenameTag_Taken(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.RenameTagFunc = func(ctx context.Context, from, to string) error_utils.MessageErr {
+		return error_formats.TagTaken()
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/tags/golang", strings.NewReader(`{"name": "go"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/tags/:tag", NewTagsController(sm, error_utils.Problems{}).RenameTag)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusConflict, apiErr.Status())
+	assert.EqualValues(t, error_utils.CodeTagTaken, apiErr.Code())
+}
+
+func TestRenameTag_Invalid_Json_Body(t *testing.T) {
+	t.Parallel()
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/tags/golang", strings.NewReader(`{"name": 1}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/tags/:tag", NewTagsController(&messagestest.Service{}, error_utils.Problems{}).RenameTag)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, apiErr.Status())
+	assert.EqualValues(t, "invalid json body", apiErr.Message())
+}
+///////////////////////////////////////////////////////////////
+// End of "RenameTag" test cases
+///////////////////////////////////////////////////////////////
+
+
+///////////////////////////////////////////////////////////////
+// Start of "MergeTags" test cases
+///////////////////////////////////////////////////////////////
+func TestMergeTags_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.MergeTagsFunc = func(ctx context.Context, from []string, into string) error_utils.MessageErr {
+		return nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/tags/merge", strings.NewReader(`{"from": ["golang", "go-lang"], "into": "go"}`))
+	rr := httptest.NewRecorder()
+	r.POST("/tags/merge", NewTagsController(sm, error_utils.Problems{}).MergeTags)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "merged"}`, rr.Body.String())
+	sm.AssertCalled(t, "MergeTags", []string{"golang", "go-lang"}, "go")
+}
+
+func TestMergeTags_Not_Found(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.MergeTagsFunc = func(ctx context.Context, from []string, into string) error_utils.MessageErr {
+		return error_utils.NewNotFoundError("no tag matching given names")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/tags/merge", strings.NewReader(`{"from": ["golang"], "into": "go"}`))
+	rr := httptest.NewRecorder()
+	r.POST("/tags/merge", NewTagsController(sm, error_utils.Problems{}).MergeTags)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, apiErr.Status())
+	assert.EqualValues(t, "no tag matching given names", apiErr.Message())
+}
+
+func TestMergeTags_Invalid_Json_Body(t *testing.T) {
+	t.Parallel()
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/tags/merge", strings.NewReader(`{"from": "golang", "into": "go"}`))
+	rr := httptest.NewRecorder()
+	r.POST("/tags/merge", NewTagsController(&messagestest.Service{}, error_utils.Problems{}).MergeTags)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, apiErr.Status())
+	assert.EqualValues(t, "invalid json body", apiErr.Message())
+}
+///////////////////////////////////////////////////////////////
+// End of "MergeTags" test cases
+///////////////////////////////////////////////////////////////
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"efficient-api/domain"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+//TenantHeader names the tenant a request acts for. When tokens are required, it must match the tenant of the token;
+//without them, it is only trusted when the TenantAuth says so.
+const TenantHeader = "X-Tenant-Id"
+
+//Tenant scopes the request to the messages of its tenant, see auth_utils.ResolveTenant. With a secret, the tenant
+//comes from the bearer token, which every request must carry; without one, TenantHeader is trusted if auth.TrustHeader is set.
+//The requests refused are answered as problems says.
+func Tenant(auth auth_utils.TenantAuth, problems error_utils.Problems) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := auth_utils.ResolveTenant(c.GetHeader("Authorization"), c.GetHeader(TenantHeader), auth)
+		if err != nil {
+			if err.Status() == http.StatusUnauthorized {
+				c.Header("WWW-Authenticate", "Bearer")
+			}
+			problems.Render(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(domain.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//getMessageAs serves a GET of message 1 behind the Tenant middleware, and returns the tenant the service was called for
+func getMessageAs(auth auth_utils.TenantAuth, headers map[string]string) (*httptest.ResponseRecorder, string) {
+	tenant := ""
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		tenant = domain.TenantFrom(ctx)
+		return &domain.Message{Id: msgId}, nil
+	}
+	r := gin.Default()
+	r.Use(Tenant(auth, error_utils.Problems{}))
+	r.GET("/messages/:message_id", NewMessagesController(sm, error_utils.Problems{}).GetMessage)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr, tenant
+}
+
+func TestTenant_From_Header(t *testing.T) {
+	t.Parallel()
+	rr, tenant := getMessageAs(auth_utils.TenantAuth{TrustHeader: true}, map[string]string{TenantHeader: "acme"})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "acme", tenant)
+
+	rr, tenant = getMessageAs(auth_utils.TenantAuth{TrustHeader: true}, nil)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, domain.DefaultTenant, tenant)
+}
+
+func TestTenant_Untrusted_Header(t *testing.T) {
+	t.Parallel()
+	rr, tenant := getMessageAs(auth_utils.TenantAuth{}, map[string]string{TenantHeader: "acme"})
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+	assert.EqualValues(t, "", tenant)
+
+	rr, tenant = getMessageAs(auth_utils.TenantAuth{}, map[string]string{TenantHeader: domain.DefaultTenant})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, domain.DefaultTenant, tenant)
+
+	rr, tenant = getMessageAs(auth_utils.TenantAuth{}, nil)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, domain.DefaultTenant, tenant)
+}
+
+func TestTenant_Invalid_Header(t *testing.T) {
+	t.Parallel()
+	rr, tenant := getMessageAs(auth_utils.TenantAuth{TrustHeader: true}, map[string]string{TenantHeader: "not a tenant"})
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	//the request never reached the service
+	assert.EqualValues(t, "", tenant)
+}
+
+func TestTenant_From_Token(t *testing.T) {
+	t.Parallel()
+	secret := []byte("the secret")
+	token, _ := auth_utils.NewToken(auth_utils.Claims{Tenant: "acme"}, secret)
+
+	rr, tenant := getMessageAs(auth_utils.TenantAuth{Secret: secret}, map[string]string{"Authorization": "Bearer " + token})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "acme", tenant)
+
+	rr, tenant = getMessageAs(auth_utils.TenantAuth{Secret: secret}, map[string]string{"Authorization": "Bearer " + token, TenantHeader: "globex"})
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+	assert.EqualValues(t, "", tenant)
+
+	rr, tenant = getMessageAs(auth_utils.TenantAuth{Secret: secret}, map[string]string{TenantHeader: "acme"})
+	assert.EqualValues(t, http.StatusUnauthorized, rr.Code)
+	assert.EqualValues(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+	assert.EqualValues(t, "", tenant)
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, "unauthorized", apiErr.Error())
+}
@@ -0,0 +1,298 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+//roundTrip stands for the time a statement takes to reach MySQL and come back
+const roundTrip = 50 * time.Microsecond
+
+func messageRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+}
+
+//getPreparingPerCall is how Get used to work, preparing and closing the statement on every call
+func getPreparingPerCall(db *sql.DB, messageId int64) error {
+	stmt, err := db.Prepare(queryGetMessage)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var msg Message
+	return scanMessage(stmt.QueryRow(messageId, DefaultTenant), &msg)
+}
+
+func BenchmarkMessageRepo_Get_Prepare_Per_Call(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	for i := 0; i < b.N; i++ {
+		mock.ExpectPrepare("SELECT (.+) FROM messages").WillDelayFor(roundTrip).
+			ExpectQuery().WithArgs(1, DefaultTenant).WillDelayFor(roundTrip).WillReturnRows(messageRows())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := getPreparingPerCall(db, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_Get_Reused_Statement(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages").WillDelayFor(roundTrip)
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WithArgs(1, DefaultTenant).WillDelayFor(roundTrip).WillReturnRows(messageRows())
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Get(context.Background(), 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////
+// Benchmarks of every repository method, against MySQL faked by sqlmock
+// and against the memory repository. sqlmock answers at once, so these
+// measure the cost of the repository itself rather than of the database.
+///////////////////////////////////////////////////////////////
+
+func newBenchMock(b *testing.B) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	return db, mock
+}
+
+//seededMemoryRepository holds n messages, with the ids 1 to n
+func seededMemoryRepository(b *testing.B, n int) MessageRepository {
+	repo := NewMemoryRepository()
+	for i := 0; i < n; i++ {
+		if _, err := repo.Create(context.Background(), &Message{Title: fmt.Sprintf("title %d", i), Body: "body", CreatedAt: created_at}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func BenchmarkMessageRepo_Get(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(messageRows())
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Get(context.Background(), 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_GetAll(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WillReturnRows(messageRows().AddRow(2, nil, "title 2", "body", created_at, 0, nil))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetAll(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_List(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id > (.+)")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WithArgs(DefaultTenant, 0, "%%", DefaultListLimit).WillReturnRows(messageRows().AddRow(2, nil, "title 2", "body", created_at, 0, nil))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.List(context.Background(), ListOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_GetByTitle(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND title=?")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WithArgs(DefaultTenant, "title").WillReturnRows(messageRows())
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetByTitle(context.Background(), "title"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_Stream(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) ORDER BY id")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectQuery().WillReturnRows(messageRows().AddRow(2, nil, "title 2", "body", created_at, 0, nil))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Stream(context.Background(), func(Message) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_Create(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("INSERT INTO messages")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectExec().WithArgs(DefaultTenant, nil, "title", "body", created_at).WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Create(context.Background(), &Message{Title: "title", Body: "body", CreatedAt: created_at}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_Update(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("UPDATE messages")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectExec().WithArgs("title", "body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Update(context.Background(), &Message{Id: 1, Title: "title", Body: "body"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageRepo_Delete(b *testing.B) {
+	db, mock := newBenchMock(b)
+	defer db.Close()
+	prepared := mock.ExpectPrepare("DELETE FROM messages")
+	for i := 0; i < b.N; i++ {
+		prepared.ExpectExec().WithArgs(1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	s := NewMessageRepository(db)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Delete(context.Background(), 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_Get(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Get(context.Background(), int64(i%100+1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_GetAll(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetAll(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_List(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.List(context.Background(), ListOptions{Limit: 20, Title: "title"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_GetByTitle(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetByTitle(context.Background(), "title 50"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_Stream(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := repo.Stream(context.Background(), func(Message) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_Create(b *testing.B) {
+	repo := NewMemoryRepository()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Create(context.Background(), &Message{Title: fmt.Sprintf("title %d", i), Body: "body", CreatedAt: created_at}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_Update(b *testing.B) {
+	repo := seededMemoryRepository(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Update(context.Background(), &Message{Id: 1, Title: fmt.Sprintf("updated %d", i), Body: "body"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryRepository_Delete(b *testing.B) {
+	repo := seededMemoryRepository(b, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := repo.Delete(context.Background(), int64(i+1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+	"expvar"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//The states of a circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+//The state of the breaker and how often it opened and turned calls away, published on /debug/vars
+var (
+	breakerMetrics = expvar.NewMap("message_repo_breaker")
+	breakerState   = new(expvar.String)
+)
+
+func init() {
+	breakerState.Set(BreakerClosed)
+	breakerMetrics.Set("state", breakerState)
+}
+
+//StateReporter is implemented by repositories that know whether the database can be used at the moment
+type StateReporter interface {
+	State() string
+}
+
+//BreakerSettings says when a circuit breaker opens and when it tries the database again
+type BreakerSettings struct {
+	//The breaker opens when at least FailureRatio of the calls made in Window failed, once there were MinRequests of them
+	FailureRatio float64
+	MinRequests  int
+	Window       time.Duration
+	//How long the breaker stays open before it lets HalfOpenRequests calls through to probe the database
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+//DefaultBreakerSettings opens when half of the calls fail over 10 seconds, and probes again after 5
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		FailureRatio:     0.5,
+		MinRequests:      10,
+		Window:           10 * time.Second,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+//CircuitBreaker stops calling the database once too many calls fail, and fails fast with a 503 instead
+//of letting requests wait on connections that will not come. Only server errors count as failures:
+//a message that is not found or a title that is taken says nothing about the health of the database.
+type CircuitBreaker struct {
+	repo     MessageRepository
+	settings BreakerSettings
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       string
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	probes      int
+	//generation moves on with every change of state and every new window, so that a call is only counted where it was let in
+	generation uint64
+}
+
+//admission is what allow let a call in under, for done to count the call there
+type admission struct {
+	generation uint64
+	probe      bool
+}
+
+func NewCircuitBreaker(repo MessageRepository, settings BreakerSettings) *CircuitBreaker {
+	return &CircuitBreaker{
+		repo:     repo,
+		settings: settings,
+		now:      time.Now,
+		state:    BreakerClosed,
+	}
+}
+
+//State returns BreakerClosed, BreakerOpen or BreakerHalfOpen
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) setState(state string) {
+	if b.state != state {
+		log.Printf("database circuit breaker is now %s", state)
+		breakerState.Set(state)
+		b.generation++
+	}
+	b.state = state
+}
+
+//newWindow starts counting the calls of the closed breaker again
+func (b *CircuitBreaker) newWindow(now time.Time) {
+	b.generation++
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.setState(BreakerOpen)
+	b.openedAt = now
+	breakerMetrics.Add("opened", 1)
+}
+
+//allow decides whether a call may go through to the database, and returns what it was let in under
+func (b *CircuitBreaker) allow() (admission, error_utils.MessageErr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.settings.OpenTimeout - now.Sub(b.openedAt); wait > 0 {
+			return admission{}, b.reject(wait)
+		}
+		b.setState(BreakerHalfOpen)
+		b.probes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return admission{}, b.reject(0)
+		}
+		b.probes++
+		return admission{generation: b.generation, probe: true}, nil
+	default:
+		if now.Sub(b.windowStart) > b.settings.Window {
+			b.newWindow(now)
+		}
+	}
+	return admission{generation: b.generation}, nil
+}
+
+func (b *CircuitBreaker) reject(wait time.Duration) error_utils.MessageErr {
+	breakerMetrics.Add("rejected", 1)
+	err := error_utils.WithCode(error_utils.NewServiceUnavailableError("the database is unavailable, please try again later"), error_utils.CodeCircuitOpen)
+	return error_utils.WithDetails(err, map[string]interface{}{"retry_after": int(math.Ceil(wait.Seconds()))})
+}
+
+//done records how the call let in as call went. Calls the client gave up on are not held against the database,
+//nor are the calls let in before the state or the window changed: a slow call let in while closed says nothing of a probe.
+func (b *CircuitBreaker) done(ctx context.Context, call admission, err error_utils.MessageErr) {
+	failed := err != nil && err.Status() >= http.StatusInternalServerError && ctx.Err() == nil
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if call.generation != b.generation {
+		return
+	}
+	now := b.now()
+	switch b.state {
+	case BreakerHalfOpen:
+		if !call.probe {
+			return
+		}
+		b.probes--
+		if failed {
+			b.open(now)
+			return
+		}
+		b.setState(BreakerClosed)
+		b.newWindow(now)
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.settings.MinRequests && float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio {
+			b.open(now)
+		}
+	}
+}
+
+//Sticky passes on whether the repository underneath sends the reads of the client to the primary
+func (b *CircuitBreaker) Sticky(ctx context.Context) bool {
+	return isSticky(ctx, b.repo)
+}
+
+func (b *CircuitBreaker) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := b.repo.Get(ctx, messageId)
+	b.done(ctx, call, err)
+	return msg, err
+}
+
+func (b *CircuitBreaker) GetMany(ctx context.Context, messageIds []int64) ([]Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := b.repo.GetMany(ctx, messageIds)
+	b.done(ctx, call, err)
+	return msgs, err
+}
+
+func (b *CircuitBreaker) GetAll(ctx context.Context) ([]Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := b.repo.GetAll(ctx)
+	b.done(ctx, call, err)
+	return msgs, err
+}
+
+func (b *CircuitBreaker) List(ctx context.Context, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := b.repo.List(ctx, opts)
+	b.done(ctx, call, err)
+	return msgs, err
+}
+
+func (b *CircuitBreaker) GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := b.repo.GetByTitle(ctx, title)
+	b.done(ctx, call, err)
+	return msg, err
+}
+
+func (b *CircuitBreaker) Count(ctx context.Context) (int64, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return 0, err
+	}
+	count, err := b.repo.Count(ctx)
+	b.done(ctx, call, err)
+	return count, err
+}
+
+func (b *CircuitBreaker) Replies(ctx context.Context, parentId int64, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := b.repo.Replies(ctx, parentId, opts)
+	b.done(ctx, call, err)
+	return msgs, err
+}
+
+func (b *CircuitBreaker) Thread(ctx context.Context, messageId int64, depth int) ([]Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := b.repo.Thread(ctx, messageId, depth)
+	b.done(ctx, call, err)
+	return msgs, err
+}
+
+//Stream does not count the failures of fn, such as a client going away in the middle of an export
+func (b *CircuitBreaker) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	err = b.repo.Stream(ctx, func(msg Message) error {
+		fnErr = fn(msg)
+		return fnErr
+	})
+	if fnErr != nil {
+		b.done(ctx, call, nil)
+	} else {
+		b.done(ctx, call, err)
+	}
+	return err
+}
+
+func (b *CircuitBreaker) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	created, err := b.repo.Create(ctx, msg)
+	b.done(ctx, call, err)
+	return created, err
+}
+
+func (b *CircuitBreaker) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := b.repo.Update(ctx, msg)
+	b.done(ctx, call, err)
+	return updated, err
+}
+
+func (b *CircuitBreaker) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.repo.Delete(ctx, msgId)
+	b.done(ctx, call, err)
+	return err
+}
+
+func (b *CircuitBreaker) DeleteLeaf(ctx context.Context, msgId int64) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.repo.DeleteLeaf(ctx, msgId)
+	b.done(ctx, call, err)
+	return err
+}
+
+func (b *CircuitBreaker) Detach(ctx context.Context, msgId int64) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.repo.Detach(ctx, msgId)
+	b.done(ctx, call, err)
+	return err
+}
+
+func (b *CircuitBreaker) Tags(ctx context.Context) ([]TagCount, error_utils.MessageErr) {
+	call, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := b.repo.Tags(ctx)
+	b.done(ctx, call, err)
+	return tags, err
+}
+
+func (b *CircuitBreaker) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.repo.RenameTag(ctx, from, to)
+	b.done(ctx, call, err)
+	return err
+}
+
+func (b *CircuitBreaker) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	call, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = b.repo.MergeTags(ctx, from, into)
+	b.done(ctx, call, err)
+	return err
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testBreakerSettings = BreakerSettings{
+	FailureRatio:     0.5,
+	MinRequests:      4,
+	Window:           time.Minute,
+	OpenTimeout:      10 * time.Second,
+	HalfOpenRequests: 1,
+}
+
+//newTestBreaker returns a breaker around repo whose clock only moves when the test says so
+func newTestBreaker(repo MessageRepository) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(repo, testBreakerSettings)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreaker_Opens_And_Recovers(t *testing.T) {
+	serverErr := error_utils.NewInternalServerError("error when processing request")
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{nil, serverErr, nil, serverErr, serverErr}}
+	b, now := newTestBreaker(flaky)
+	ctx := context.Background()
+
+	//2 failures out of 4 calls reach the ratio
+	for i := 0; i < 4; i++ {
+		b.Get(ctx, 1)
+	}
+	assert.EqualValues(t, BreakerOpen, b.State())
+
+	//while open, the database is left alone
+	_, err := b.Get(ctx, 1)
+	assert.EqualValues(t, http.StatusServiceUnavailable, err.Status())
+	assert.EqualValues(t, error_utils.CodeCircuitOpen, err.Code())
+	assert.EqualValues(t, 10, err.Details()["retry_after"])
+	assert.EqualValues(t, 4, flaky.calls)
+
+	//the probe fails, so the breaker opens again
+	*now = now.Add(10 * time.Second)
+	assert.EqualValues(t, BreakerHalfOpen, b.State())
+	_, err = b.Get(ctx, 1)
+	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	assert.EqualValues(t, BreakerOpen, b.State())
+	assert.EqualValues(t, 5, flaky.calls)
+
+	//the next probe succeeds and closes it
+	*now = now.Add(10 * time.Second)
+	msg, err := b.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreaker_Ignores_Client_Errors(t *testing.T) {
+	notFound := error_utils.NewNotFoundError("no record matching given id")
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{notFound, notFound, notFound, notFound, notFound}}
+	b, _ := newTestBreaker(flaky)
+	for i := 0; i < 5; i++ {
+		b.Get(context.Background(), 1)
+	}
+	assert.EqualValues(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreaker_Forgets_Old_Failures(t *testing.T) {
+	serverErr := error_utils.NewInternalServerError("error when processing request")
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{serverErr, serverErr, serverErr}}
+	b, now := newTestBreaker(flaky)
+	for i := 0; i < 3; i++ {
+		b.Get(context.Background(), 1)
+	}
+	//the window is over before the 4th call, so the count starts again
+	*now = now.Add(2 * time.Minute)
+	b.Get(context.Background(), 1)
+	assert.EqualValues(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreaker_Half_Open_Lets_One_Probe_Through(t *testing.T) {
+	b, now := newTestBreaker(&flakyRepo{})
+	b.open(*now)
+	*now = now.Add(10 * time.Second)
+
+	probe, err := b.allow()
+	assert.Nil(t, err)
+	assert.True(t, probe.probe)
+	//a second call while the probe is running is turned away
+	_, err = b.allow()
+	assert.NotNil(t, err)
+	assert.EqualValues(t, error_utils.CodeCircuitOpen, err.Code())
+}
+
+//A slow call let in while closed that ends while half open is not taken for the probe
+func TestCircuitBreaker_Ignores_Calls_From_Before_The_Transition(t *testing.T) {
+	serverErr := error_utils.NewInternalServerError("error when processing request")
+	b, now := newTestBreaker(&flakyRepo{})
+	ctx := context.Background()
+
+	slow, err := b.allow()
+	assert.Nil(t, err)
+	b.open(*now)
+	*now = now.Add(10 * time.Second)
+	probe, err := b.allow()
+	assert.Nil(t, err)
+
+	//the slow call fails, which neither reopens the breaker nor frees the probe
+	b.done(ctx, slow, serverErr)
+	assert.EqualValues(t, BreakerHalfOpen, b.State())
+	_, err = b.allow()
+	assert.NotNil(t, err)
+
+	b.done(ctx, probe, nil)
+	assert.EqualValues(t, BreakerClosed, b.State())
+	//and it does not count in the window started when the breaker closed
+	b.done(ctx, slow, serverErr)
+	assert.EqualValues(t, 0, b.failures)
+}
+
+//A call let in during a window that is over counts in neither window
+func TestCircuitBreaker_Counts_Calls_In_Their_Window(t *testing.T) {
+	serverErr := error_utils.NewInternalServerError("error when processing request")
+	b, now := newTestBreaker(&flakyRepo{})
+	ctx := context.Background()
+
+	old, _ := b.allow()
+	*now = now.Add(2 * time.Minute)
+	current, _ := b.allow()
+	b.done(ctx, old, serverErr)
+	b.done(ctx, current, nil)
+	assert.EqualValues(t, 1, b.requests)
+	assert.EqualValues(t, 0, b.failures)
+}
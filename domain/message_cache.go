@@ -0,0 +1,377 @@
+package domain
+
+import (
+	"container/list"
+	"context"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Hits, misses, calls that waited on the same load and invalidations, published on /debug/vars
+var cacheMetrics = expvar.NewMap("message_repo_cache")
+
+//Cache stores encoded messages. The in-process LRUCache is the default, another store such as memcached
+//or redis can be used by implementing this interface, and shared by several instances. Entries may be evicted at any time.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+	//Incr atomically adds delta to the counter at key, a missing one starting at 0, and returns its new value, as redis INCRBY does.
+	//Counters must not be evicted nor expire: one that starts over at 0 would serve again the entries cached under its older values.
+	Incr(key string, delta int64) int64
+}
+
+//LRUCache is an in-process Cache holding at most size entries, each for at most ttl
+type LRUCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	order    *list.List
+	entries  map[string]*list.Element
+	counters map[string]int64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:     size,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		counters: make(map[string]int64),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = c.now().Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: c.now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+//Incr keeps the counters apart from the entries, they are neither evicted nor expired
+func (c *LRUCache) Incr(key string, delta int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key] += delta
+	return c.counters[key]
+}
+
+//Every key starts with the tenant, so that a tenant is never served what was cached for another
+func tenantKey(ctx context.Context, key string) string {
+	return "tenant:" + TenantFrom(ctx) + ":" + key
+}
+
+type cachingRepo struct {
+	repo  MessageRepository
+	cache Cache
+	group singleflight.Group
+}
+
+//NewCachingRepository caches the messages read with Get and GetMany, the lists read with GetAll, List, Replies and Thread, and the tags.
+//Concurrent misses for the same key are loaded once, through the repository as any read.
+//Every write invalidates all that was cached for the tenant, as a reply changes the ReplyCount of its parent and
+//a delete removes the replies too: it moves the generation of the tenant, a counter kept in the cache, so that
+//the entries cached before are no longer looked up and age out. As the generation is in the cache, several instances
+//sharing it see the writes of each other. A load that started before a write caches under the old generation,
+//so it cannot bring back what was replaced.
+//A client that wrote within the sticky window of the replicas skips the cache, so it reads its own writes from the primary
+//even when another client cached what a replica returned before it caught up.
+//GetByTitle, Stream and Count always go to the repository, as imports, exports and quotas need the data as it is now.
+//Entries are cached per tenant.
+func NewCachingRepository(repo MessageRepository, cache Cache) MessageRepository {
+	return &cachingRepo{repo: repo, cache: cache}
+}
+
+func (r *cachingRepo) messageKey(ctx context.Context, messageId int64) string {
+	return tenantKey(ctx, "messages:"+r.generation(ctx)+":message:"+strconv.FormatInt(messageId, 10))
+}
+
+func (r *cachingRepo) generation(ctx context.Context) string {
+	return strconv.FormatInt(r.cache.Incr(tenantKey(ctx, "generation"), 0), 10)
+}
+
+func (r *cachingRepo) invalidate(ctx context.Context) {
+	cacheMetrics.Add("invalidations", 1)
+	r.cache.Incr(tenantKey(ctx, "generation"), 1)
+}
+
+//load reads the key from the cache into v, or else calls fn once for all the callers waiting on the key and caches what it returns.
+//A client within its sticky window calls fn itself, without reading nor filling the cache.
+func (r *cachingRepo) load(ctx context.Context, key string, v interface{}, fn func(context.Context) (interface{}, error_utils.MessageErr)) error_utils.MessageErr {
+	if isSticky(ctx, r.repo) {
+		cacheMetrics.Add("sticky", 1)
+		value, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		return copyValue(value, v)
+	}
+	if b, ok := r.cache.Get(key); ok && json.Unmarshal(b, v) == nil {
+		cacheMetrics.Add("hits", 1)
+		return nil
+	}
+	cacheMetrics.Add("misses", 1)
+	b, err, shared := r.group.Do(key, func() (interface{}, error) {
+		value, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b, encodeErr := json.Marshal(value)
+		if encodeErr != nil {
+			return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to cache message: %s", encodeErr.Error()))
+		}
+		r.cache.Set(key, b)
+		return b, nil
+	})
+	if shared {
+		cacheMetrics.Add("shared", 1)
+	}
+	if err != nil {
+		return err.(error_utils.MessageErr)
+	}
+	//every caller decodes its own copy, so changing what it got cannot change the cache
+	if decodeErr := json.Unmarshal(b.([]byte), v); decodeErr != nil {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to read cached message: %s", decodeErr.Error()))
+	}
+	return nil
+}
+
+//copyValue copies what fn returned into v, the way it would have come back from the cache
+func copyValue(value interface{}, v interface{}) error_utils.MessageErr {
+	b, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to read message: %s", err.Error()))
+	}
+	return nil
+}
+
+//State passes on the state of the repository underneath, so that the cache does not hide an open circuit breaker
+func (r *cachingRepo) State() string {
+	if reporter, ok := r.repo.(StateReporter); ok {
+		return reporter.State()
+	}
+	return BreakerClosed
+}
+
+func (r *cachingRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	var msg Message
+	err := r.load(ctx, r.messageKey(ctx, messageId), &msg, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.Get(ctx, messageId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+//GetMany shares the entries of Get. The messages missing from the cache are read at once and cached one by one.
+//The ids matching no message are not cached.
+func (r *cachingRepo) GetMany(ctx context.Context, messageIds []int64) ([]Message, error_utils.MessageErr) {
+	if isSticky(ctx, r.repo) {
+		cacheMetrics.Add("sticky", 1)
+		return r.repo.GetMany(ctx, messageIds)
+	}
+	results := make([]Message, 0, len(messageIds))
+	keys := make(map[int64]string, len(messageIds))
+	var missing []int64
+	for _, id := range messageIds {
+		if _, ok := keys[id]; ok {
+			continue
+		}
+		keys[id] = r.messageKey(ctx, id)
+		var msg Message
+		if b, ok := r.cache.Get(keys[id]); ok && json.Unmarshal(b, &msg) == nil {
+			cacheMetrics.Add("hits", 1)
+			results = append(results, msg)
+			continue
+		}
+		cacheMetrics.Add("misses", 1)
+		missing = append(missing, id)
+	}
+	if len(missing) > 0 {
+		msgs, err := r.repo.GetMany(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if b, encodeErr := json.Marshal(msg); encodeErr == nil {
+				r.cache.Set(keys[msg.Id], b)
+			}
+		}
+		results = append(results, msgs...)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Id < results[j].Id })
+	return results, nil
+}
+
+func (r *cachingRepo) GetAll(ctx context.Context) ([]Message, error_utils.MessageErr) {
+	var msgs []Message
+	err := r.load(ctx, tenantKey(ctx, "messages:"+r.generation(ctx)+":all"), &msgs, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.GetAll(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *cachingRepo) List(ctx context.Context, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	var msgs []Message
+	key := tenantKey(ctx, fmt.Sprintf("messages:%s:list:%d:%d:%s:%s:%s", r.generation(ctx), opts.AfterId, opts.limit(), opts.Match, strings.Join(opts.Tags, ","), opts.Title))
+	err := r.load(ctx, key, &msgs, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.List(ctx, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *cachingRepo) GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr) {
+	return r.repo.GetByTitle(ctx, title)
+}
+
+func (r *cachingRepo) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	return r.repo.Stream(ctx, fn)
+}
+
+func (r *cachingRepo) Count(ctx context.Context) (int64, error_utils.MessageErr) {
+	return r.repo.Count(ctx)
+}
+
+func (r *cachingRepo) Replies(ctx context.Context, parentId int64, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	var msgs []Message
+	key := tenantKey(ctx, fmt.Sprintf("messages:%s:replies:%d:%d:%d", r.generation(ctx), parentId, opts.AfterId, opts.limit()))
+	err := r.load(ctx, key, &msgs, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.Replies(ctx, parentId, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *cachingRepo) Thread(ctx context.Context, messageId int64, depth int) ([]Message, error_utils.MessageErr) {
+	var msgs []Message
+	key := tenantKey(ctx, fmt.Sprintf("messages:%s:thread:%d:%d", r.generation(ctx), messageId, depth))
+	err := r.load(ctx, key, &msgs, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.Thread(ctx, messageId, depth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *cachingRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	created, err := r.repo.Create(ctx, msg)
+	if err == nil {
+		r.invalidate(ctx)
+	}
+	return created, err
+}
+
+//Update invalidates even when it fails, as the update may have been saved before the error
+func (r *cachingRepo) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	updated, err := r.repo.Update(ctx, msg)
+	r.invalidate(ctx)
+	return updated, err
+}
+
+func (r *cachingRepo) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	err := r.repo.Delete(ctx, msgId)
+	r.invalidate(ctx)
+	return err
+}
+
+func (r *cachingRepo) DeleteLeaf(ctx context.Context, msgId int64) error_utils.MessageErr {
+	err := r.repo.DeleteLeaf(ctx, msgId)
+	r.invalidate(ctx)
+	return err
+}
+
+func (r *cachingRepo) Detach(ctx context.Context, msgId int64) error_utils.MessageErr {
+	err := r.repo.Detach(ctx, msgId)
+	r.invalidate(ctx)
+	return err
+}
+
+func (r *cachingRepo) Tags(ctx context.Context) ([]TagCount, error_utils.MessageErr) {
+	var tags []TagCount
+	err := r.load(ctx, tenantKey(ctx, "messages:"+r.generation(ctx)+":tags"), &tags, func(ctx context.Context) (interface{}, error_utils.MessageErr) {
+		return r.repo.Tags(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *cachingRepo) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	err := r.repo.RenameTag(ctx, from, to)
+	r.invalidate(ctx)
+	return err
+}
+
+func (r *cachingRepo) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	err := r.repo.MergeTags(ctx, from, into)
+	r.invalidate(ctx)
+	return err
+}
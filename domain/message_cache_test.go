@@ -0,0 +1,308 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRUCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Get("a")
+	//"b" is the least recently used, so it makes room for "c"
+	c.Set("c", []byte("3"))
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.EqualValues(t, "1", string(v))
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+}
+
+//countingRepo counts the calls that reach it, and holds them until release is closed when it is set
+type countingRepo struct {
+	MessageRepository
+	calls   int32
+	release chan struct{}
+}
+
+func (r *countingRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	atomic.AddInt32(&r.calls, 1)
+	if r.release != nil {
+		<-r.release
+	}
+	if messageId == 404 {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return &Message{Id: messageId, Title: "title"}, nil
+}
+
+//GetMany finds every id but 404
+func (r *countingRepo) GetMany(ctx context.Context, messageIds []int64) ([]Message, error_utils.MessageErr) {
+	atomic.AddInt32(&r.calls, 1)
+	msgs := make([]Message, 0, len(messageIds))
+	for _, id := range messageIds {
+		if id != 404 {
+			msgs = append(msgs, Message{Id: id, Title: "title"})
+		}
+	}
+	return msgs, nil
+}
+
+func (r *countingRepo) List(ctx context.Context, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	atomic.AddInt32(&r.calls, 1)
+	return []Message{{Id: opts.AfterId + 1}}, nil
+}
+
+func (r *countingRepo) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	return msg, nil
+}
+
+func (r *countingRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	return msg, nil
+}
+
+func (r *countingRepo) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	return nil
+}
+
+func TestCachingRepo_Get(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	ctx := context.Background()
+
+	msg, err := cached.Get(ctx, 1)
+	assert.Nil(t, err)
+	//changing what we got does not change the cache
+	msg.Title = "changed"
+	msg, err = cached.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "title", msg.Title)
+	assert.EqualValues(t, 1, repo.calls)
+
+	//errors are not cached
+	cached.Get(ctx, 404)
+	_, err = cached.Get(ctx, 404)
+	assert.EqualValues(t, 404, err.Status())
+	assert.EqualValues(t, 3, repo.calls)
+
+	cached.Update(ctx, &Message{Id: 1, Title: "new title"})
+	cached.Get(ctx, 1)
+	assert.EqualValues(t, 4, repo.calls)
+}
+
+func TestCachingRepo_List_Invalidated_By_Writes(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	ctx := context.Background()
+
+	cached.List(ctx, ListOptions{AfterId: 1})
+	cached.List(ctx, ListOptions{AfterId: 1})
+	assert.EqualValues(t, 1, repo.calls)
+	cached.List(ctx, ListOptions{AfterId: 2})
+	assert.EqualValues(t, 2, repo.calls)
+
+	cached.Create(ctx, &Message{Title: "new"})
+	cached.List(ctx, ListOptions{AfterId: 1})
+	assert.EqualValues(t, 3, repo.calls)
+}
+
+func TestCachingRepo_List_Keyed_By_Tags_And_Invalidated_By_Tag_Writes(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	ctx := context.Background()
+
+	cached.List(ctx, ListOptions{AfterId: 1})
+	cached.List(ctx, ListOptions{AfterId: 1, Tags: []string{"go"}})
+	cached.List(ctx, ListOptions{AfterId: 1, Tags: []string{"go"}, Match: MatchAll})
+	assert.EqualValues(t, 3, repo.calls)
+	cached.List(ctx, ListOptions{AfterId: 1, Tags: []string{"go"}})
+	assert.EqualValues(t, 3, repo.calls)
+
+	cached.RenameTag(ctx, "go", "golang")
+	cached.List(ctx, ListOptions{AfterId: 1, Tags: []string{"go"}})
+	assert.EqualValues(t, 4, repo.calls)
+}
+
+//A reply changes the ReplyCount of its parent, so the cached messages go with the lists
+//mapCache is a Cache that keeps every entry until it is deleted
+type mapCache struct {
+	mu       sync.Mutex
+	entries  map[string][]byte
+	counters map[string]int64
+}
+
+func (c *mapCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[key]
+	return v, ok
+}
+
+func (c *mapCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = value
+}
+
+func (c *mapCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *mapCache) Incr(key string, delta int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key] += delta
+	return c.counters[key]
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{entries: make(map[string][]byte), counters: make(map[string]int64)}
+}
+
+//Whatever the cache evicts, the entries cached before a write are not served after it
+func TestCachingRepo_Invalidation_Outlives_Evictions(t *testing.T) {
+	repo := &countingRepo{}
+	cache := newMapCache()
+	cached := NewCachingRepository(repo, cache)
+	ctx := context.Background()
+
+	cached.Get(ctx, 1)
+	before := make(map[string]bool)
+	for key := range cache.entries {
+		before[key] = true
+	}
+	cached.Update(ctx, &Message{Id: 1, Title: "changed"})
+	//the cache evicts all but the entries cached before the write
+	for key := range cache.entries {
+		if !before[key] {
+			cache.Delete(key)
+		}
+	}
+	cached.Get(ctx, 1)
+	assert.EqualValues(t, 2, repo.calls)
+}
+
+//Instances sharing a cache read the entries of each other, and the writes of one invalidate them for all
+func TestCachingRepo_Shared_Between_Instances(t *testing.T) {
+	repo := &countingRepo{}
+	cache := newMapCache()
+	first, second := NewCachingRepository(repo, cache), NewCachingRepository(repo, cache)
+	ctx := context.Background()
+
+	first.Get(ctx, 1)
+	second.Get(ctx, 1)
+	assert.EqualValues(t, 1, repo.calls)
+
+	second.Update(ctx, &Message{Id: 1, Title: "changed"})
+	first.Get(ctx, 1)
+	assert.EqualValues(t, 2, repo.calls)
+}
+
+func TestLRUCache_Counters_Are_Not_Evicted(t *testing.T) {
+	c := NewLRUCache(1, time.Minute)
+	assert.EqualValues(t, 1, c.Incr("generation", 1))
+	c.Set("a", []byte("a"))
+	c.Set("b", []byte("b"))
+	assert.EqualValues(t, 1, c.Incr("generation", 0))
+}
+
+func TestCachingRepo_Get_Invalidated_By_Writes(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	ctx := context.Background()
+
+	cached.Get(ctx, 1)
+	cached.Create(ctx, &Message{ParentId: 1, Title: "reply"})
+	cached.Get(ctx, 1)
+	assert.EqualValues(t, 2, repo.calls)
+}
+
+//GetMany reads the messages Get did not cache, in one call, and caches them for Get
+func TestCachingRepo_GetMany_Shares_Get_Entries(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	ctx := context.Background()
+
+	cached.Get(ctx, 2)
+	msgs, err := cached.GetMany(ctx, []int64{3, 2, 1, 404, 3})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{1, 2, 3}, []int64{msgs[0].Id, msgs[1].Id, msgs[2].Id})
+	assert.EqualValues(t, 3, len(msgs))
+	assert.EqualValues(t, 2, repo.calls)
+
+	cached.Get(ctx, 1)
+	cached.Get(ctx, 3)
+	assert.EqualValues(t, 2, repo.calls)
+
+	cached.Update(ctx, &Message{Id: 1, Title: "changed"})
+	cached.GetMany(ctx, []int64{1, 2})
+	assert.EqualValues(t, 3, repo.calls)
+}
+
+func TestCachingRepo_Caches_Per_Tenant(t *testing.T) {
+	repo := &countingRepo{}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	cached.Get(acme, 1)
+	cached.Get(globex, 1)
+	assert.EqualValues(t, 2, repo.calls)
+
+	cached.List(acme, ListOptions{})
+	cached.List(globex, ListOptions{})
+	assert.EqualValues(t, 4, repo.calls)
+
+	//a write only invalidates the lists of its own tenant
+	cached.Create(acme, &Message{Title: "new"})
+	cached.List(acme, ListOptions{})
+	cached.List(globex, ListOptions{})
+	assert.EqualValues(t, 5, repo.calls)
+}
+
+func TestCachingRepo_Passes_On_The_Breaker_State(t *testing.T) {
+	b, now := newTestBreaker(&countingRepo{})
+	b.open(*now)
+	cached := NewCachingRepository(b, NewLRUCache(10, time.Minute))
+	assert.EqualValues(t, BreakerOpen, cached.(StateReporter).State())
+}
+
+func TestCachingRepo_Loads_Once_For_Concurrent_Misses(t *testing.T) {
+	repo := &countingRepo{release: make(chan struct{})}
+	cached := NewCachingRepository(repo, NewLRUCache(10, time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, err := cached.Get(context.Background(), 1)
+			assert.Nil(t, err)
+			assert.EqualValues(t, 1, msg.Id)
+		}()
+	}
+	//let the callers pile up on the first load before it returns
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	assert.EqualValues(t, 1, repo.calls)
+}
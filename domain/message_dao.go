@@ -1,39 +1,120 @@
 package domain
 
 import (
+	"context"
 	"database/sql"
 	"efficient-api/utils/error_formats"
 	"efficient-api/utils/error_utils"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	"golang.org/x/sync/singleflight"
 	"log"
+	"strings"
+	"sync"
+	"time"
 )
 
-var (
-	MessageRepo messageRepoInterface = &messageRepo{}
+//messageColumns are read by scanMessage. They are qualified, as the thread query joins messages with its ids.
+//The tags come as a single comma separated column, which tag names cannot contain.
+const messageColumns = "messages.id, messages.parent_id, messages.title, messages.body, messages.created_at, " +
+	"(SELECT COUNT(*) FROM messages AS reply WHERE reply.parent_id = messages.id AND reply.tenant_id = messages.tenant_id), " +
+	"(SELECT GROUP_CONCAT(tags.name ORDER BY tags.name) FROM message_tags JOIN tags ON tags.id = message_tags.tag_id WHERE message_tags.message_id = messages.id)"
+
+//taggedIds lists the ids of the messages carrying at least the given number of the tags given as a comma separated list
+const taggedIds = "SELECT message_tags.message_id FROM message_tags JOIN tags ON tags.id = message_tags.tag_id " +
+	"WHERE tags.tenant_id=? AND FIND_IN_SET(tags.name, ?) GROUP BY message_tags.message_id HAVING COUNT(*) >= ?"
+
+//threadIds lists the ids of a message and of its replies, at any depth. Every level is held to the tenant,
+//so that a parent_id naming the message of another tenant never reaches it.
+const threadIds = "WITH RECURSIVE thread (id) AS (SELECT id FROM messages WHERE id=? AND tenant_id=? " +
+	"UNION ALL SELECT child.id FROM messages AS child JOIN thread ON child.parent_id = thread.id AND child.tenant_id = ?) SELECT id FROM thread"
+
+const (
+	queryGetMessage        = "SELECT " + messageColumns + " FROM messages WHERE id=? AND tenant_id=?;"
+	queryGetManyMessages   = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? AND id IN (%s) ORDER BY id;"
+	queryInsertMessage     = "INSERT INTO messages(tenant_id, parent_id, title, body, created_at) VALUES(?, ?, ?, ?, ?);"
+	queryUpdateMessage     = "UPDATE messages SET title=?, body=? WHERE id=? AND tenant_id=?;"
+	queryDeleteMessage     = "DELETE FROM messages WHERE id IN (SELECT id FROM (" + threadIds + ") AS doomed);"
+	queryGetAllMessages    = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=?;"
+	queryGetMessageByTitle = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? AND title=?;"
+	queryStreamMessages    = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? ORDER BY id;"
+	queryListMessages      = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? AND id > ? AND title LIKE ? ORDER BY id LIMIT ?;"
+	queryCountMessages     = "SELECT COUNT(*) FROM messages WHERE tenant_id=?;"
+	queryListReplies       = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? AND parent_id=? AND id > ? ORDER BY id LIMIT ?;"
+	queryGetThread         = "WITH RECURSIVE thread (id, depth) AS (SELECT id, 0 FROM messages WHERE id=? AND tenant_id=? " +
+		"UNION ALL SELECT child.id, thread.depth + 1 FROM messages AS child JOIN thread ON child.parent_id = thread.id AND child.tenant_id = ? WHERE thread.depth < ?) " +
+		"SELECT " + messageColumns + " FROM messages JOIN thread ON messages.id = thread.id ORDER BY messages.id;"
+	queryDetachReplies = "UPDATE messages SET parent_id=NULL WHERE parent_id=? AND tenant_id=?;"
+	queryListTagged    = "SELECT " + messageColumns + " FROM messages WHERE tenant_id=? AND id > ? AND title LIKE ? AND id IN (" + taggedIds + ") ORDER BY id LIMIT ?;"
+	queryGetTags       = "SELECT tags.name, COUNT(*) FROM tags JOIN message_tags ON message_tags.tag_id = tags.id WHERE tags.tenant_id=? GROUP BY tags.id, tags.name ORDER BY tags.name;"
+)
+
+//The tag writes run in transactions, their statements are not kept prepared
+const (
+	queryClearTags  = "DELETE message_tags FROM message_tags JOIN messages ON messages.id = message_tags.message_id WHERE message_tags.message_id=? AND messages.tenant_id=?;"
+	queryUpsertTag  = "INSERT INTO tags(tenant_id, name) VALUES(?, ?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id);"
+	queryTagMessage = "INSERT INTO message_tags(message_id, tag_id) SELECT id, ? FROM messages WHERE id=? AND tenant_id=?;"
+	queryPurgeTags  = "DELETE FROM tags WHERE tenant_id=? AND NOT EXISTS (SELECT 1 FROM message_tags WHERE message_tags.tag_id = tags.id);"
+	queryCountTags  = "SELECT COUNT(*) FROM tags WHERE tenant_id=? AND FIND_IN_SET(name, ?);"
+	queryRenameTag  = "UPDATE tags SET name=? WHERE tenant_id=? AND name=?;"
+	queryMergeTags  = "INSERT IGNORE INTO message_tags(message_id, tag_id) SELECT message_tags.message_id, ? FROM message_tags " +
+		"JOIN tags ON tags.id = message_tags.tag_id WHERE tags.tenant_id=? AND FIND_IN_SET(tags.name, ?);"
+	queryDeleteTags = "DELETE FROM tags WHERE tenant_id=? AND FIND_IN_SET(name, ?) AND name <> ?;"
 )
 
+//DeleteLeaf locks the replies of the message, and the gap where new ones would go, before deleting it
 const (
-	queryGetMessage    = "SELECT id, title, body, created_at FROM messages WHERE id=?;"
-	queryInsertMessage = "INSERT INTO messages(title, body, created_at) VALUES(?, ?, ?);"
-	queryUpdateMessage = "UPDATE messages SET title=?, body=? WHERE id=?;"
-	queryDeleteMessage = "DELETE FROM messages WHERE id=?;"
-	queryGetAllMessages = "SELECT id, title, body, created_at FROM messages;"
+	queryLockReplies      = "SELECT COUNT(*) FROM messages WHERE parent_id=? AND tenant_id=? FOR UPDATE;"
+	queryDeleteOneMessage = "DELETE FROM messages WHERE id=? AND tenant_id=?;"
 )
 
-type messageRepoInterface interface {
-	Get(int64) (*Message, error_utils.MessageErr)
-	Create(*Message) (*Message, error_utils.MessageErr)
-	Update(*Message) (*Message, error_utils.MessageErr)
-	Delete(int64) error_utils.MessageErr
-	GetAll() ([]Message, error_utils.MessageErr)
-	Initialize(string, string, string, string, string, string) *sql.DB
+//scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
 }
+
+//scanMessage reads a row of messageColumns into msg
+func scanMessage(row scanner, msg *Message) error {
+	var parentId sql.NullInt64
+	var tags sql.NullString
+	if err := row.Scan(&msg.Id, &parentId, &msg.Title, &msg.Body, &msg.CreatedAt, &msg.ReplyCount, &tags); err != nil {
+		return err
+	}
+	msg.ParentId = parentId.Int64
+	msg.Tags = nil
+	if tags.String != "" {
+		msg.Tags = strings.Split(tags.String, ",")
+	}
+	return nil
+}
+
+//prepareTimeout is how long a statement may take to prepare, whoever is waiting for it
+const prepareTimeout = 5 * time.Second
+
 type messageRepo struct {
-	db *sql.DB
+	//db is the primary, every write goes to it. Reads go to the replicas when there are any.
+	db       *sql.DB
+	replicas *replicaSet
+
+	mu        sync.Mutex
+	stmts     map[stmtKey]*sql.Stmt
+	preparing singleflight.Group
+}
+
+//Statements belong to the database they were prepared on
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+//Connect opens the MySQL database and returns the repository writing to it, with its statements prepared.
+//Reads are spread over the replicas as settings say, see NewReplicatedRepository. The *sql.DB is returned for the callers that need it directly.
+func Connect(Dbdriver, DbUser, DbPassword, DbPort, DbHost, DbName string, settings ReplicaSettings, replicas ...*sql.DB) (MessageRepository, *sql.DB) {
+	mr := &messageRepo{replicas: newReplicaSet(replicas, settings)}
+	return mr, mr.Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, DbName)
 }
 
-func (mr *messageRepo) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, DbName string) *sql.DB  {
+func (mr *messageRepo) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, DbName string) *sql.DB {
 	var err error
 	DBURL := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName)
 
@@ -43,39 +124,138 @@ func (mr *messageRepo) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost,
 	}
 	fmt.Printf("We are connected to the %s database", Dbdriver)
 
+	mr.prepareAll()
 	return mr.db
 }
 
-func NewMessageRepository(db *sql.DB) messageRepoInterface {
-	return &messageRepo{db: db}
+//prepareAll prepares every query up front. A query that cannot be prepared yet is prepared when it is first used.
+func (mr *messageRepo) prepareAll() {
+	mr.mu.Lock()
+	for _, stmt := range mr.stmts {
+		stmt.Close()
+	}
+	mr.stmts = nil
+	mr.mu.Unlock()
+	for _, query := range []string{queryGetMessage, queryInsertMessage, queryUpdateMessage, queryDeleteMessage, queryGetAllMessages, queryGetMessageByTitle, queryStreamMessages, queryListMessages, queryCountMessages, queryListReplies, queryGetThread, queryDetachReplies, queryListTagged, queryGetTags} {
+		if _, err := mr.stmt(context.Background(), mr.db, query); err != nil {
+			log.Printf("could not prepare %q yet: %s", query, err.Error())
+		}
+	}
 }
 
-func (mr *messageRepo) Get(messageId int64) (*Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryGetMessage)
+//stmt returns the statement for the query, prepared the first time it is needed and reused after that.
+//Callers asking for a statement that is being prepared wait for that prepare and share its result.
+//A failed prepare is not remembered, so the next call tries again. database/sql prepares the statement
+//again by itself on every new connection it opens, such as after a reconnect.
+func (mr *messageRepo) stmt(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+	mr.mu.Lock()
+	stmt, ok := mr.stmts[key]
+	mr.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+	//the prepare is a round trip to the database, it runs outside the lock and once per query however many callers wait for it.
+	//It is shared, so it does not stop when the caller that started it goes away, only after prepareTimeout.
+	prepared := mr.preparing.DoChan(fmt.Sprintf("%p %s", db, query), func() (interface{}, error) {
+		prepareCtx, cancel := context.WithTimeout(context.Background(), prepareTimeout)
+		defer cancel()
+		stmt, err := db.PrepareContext(prepareCtx, query)
+		if err != nil {
+			return nil, err
+		}
+		mr.mu.Lock()
+		defer mr.mu.Unlock()
+		if mr.stmts == nil {
+			mr.stmts = make(map[stmtKey]*sql.Stmt)
+		}
+		mr.stmts[key] = stmt
+		return stmt, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-prepared:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*sql.Stmt), nil
+	}
+}
+
+//NewMessageRepository writes to db and spreads the reads over the replicas, if any, with the DefaultReplicaSettings.
+//Every query is scoped to the tenant of the context, see WithTenant.
+//Replies and threads are read with recursive queries, which need MySQL 8.
+func NewMessageRepository(db *sql.DB, replicas ...*sql.DB) MessageRepository {
+	return NewReplicatedRepository(db, replicas, DefaultReplicaSettings())
+}
+
+//NewReplicatedRepository writes to db. Get, GetAll, List and Stream are spread over the replicas in turn,
+//skipping the ones that do not answer. A client that wrote reads from db for the StickyWindow of settings afterwards,
+//so that it sees its own writes; clients are told apart with WithSession. GetByTitle and Count always read from db, as they
+//are used to check a write beforehand.
+func NewReplicatedRepository(db *sql.DB, replicas []*sql.DB, settings ReplicaSettings) MessageRepository {
+	return &messageRepo{db: db, replicas: newReplicaSet(replicas, settings)}
+}
+
+func (mr *messageRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryGetMessage)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to prepare message: %s", err.Error()))
+		return nil, parseError(err)
 	}
-	defer stmt.Close()
 
 	var msg Message
-	result := stmt.QueryRow(messageId)
-	if getError := result.Scan(&msg.Id, &msg.Title, &msg.Body, &msg.CreatedAt); getError != nil {
+	result := stmt.QueryRowContext(ctx, messageId, TenantFrom(ctx))
+	if getError := scanMessage(result, &msg); getError != nil {
 		fmt.Println("this is the error man: ", getError)
-		return nil,  error_formats.ParseError(getError)
+		return nil, parseError(getError)
 	}
 	return &msg, nil
 }
 
-func (mr *messageRepo) GetAll() ([]Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryGetAllMessages)
+//GetMany reads the messages in one query. The query has a placeholder per id, so it is not prepared.
+func (mr *messageRepo) GetMany(ctx context.Context, messageIds []int64) ([]Message, error_utils.MessageErr) {
+	results := make([]Message, 0, len(messageIds))
+	if len(messageIds) == 0 {
+		return results, nil
+	}
+	db, parseError := mr.reader(ctx)
+	args := make([]interface{}, 0, len(messageIds)+1)
+	args = append(args, TenantFrom(ctx))
+	for _, id := range messageIds {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIds)), ",")
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(queryGetManyMessages, placeholders), args...)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg Message
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return nil, parseError(getError)
+		}
+		results = append(results, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseError(err)
+	}
+	return results, nil
+}
+
+func (mr *messageRepo) GetAll(ctx context.Context) ([]Message, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryGetAllMessages)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to prepare all messages: %s", err.Error()))
+		return nil, parseError(err)
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(ctx, TenantFrom(ctx))
 	if err != nil {
-		return nil,  error_formats.ParseError(err)
+		return nil, parseError(err)
 	}
 	defer rows.Close()
 
@@ -83,8 +263,8 @@ func (mr *messageRepo) GetAll() ([]Message, error_utils.MessageErr) {
 
 	for rows.Next() {
 		var msg Message
-		if getError := rows.Scan(&msg.Id, &msg.Title, &msg.Body, &msg.CreatedAt); getError != nil {
-			return nil, error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to get message: %s", getError.Error()))
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return nil, parseError(getError)
 		}
 		results = append(results, msg)
 	}
@@ -94,52 +274,393 @@ func (mr *messageRepo) GetAll() ([]Message, error_utils.MessageErr) {
 	return results, nil
 }
 
-func (mr *messageRepo) Create(msg *Message) (*Message, error_utils.MessageErr) {
+//List returns one page of messages ordered by id. Unlike GetAll, an empty page is not an error.
+func (mr *messageRepo) List(ctx context.Context, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	query, args := queryListMessages, []interface{}{TenantFrom(ctx), opts.AfterId, "%" + escapeLike(opts.Title) + "%"}
+	if len(opts.Tags) > 0 {
+		//any tag matches when the message carries one of them, all when it carries as many as there are
+		matches := 1
+		if opts.Match == MatchAll {
+			matches = len(opts.Tags)
+		}
+		query, args = queryListTagged, append(args, TenantFrom(ctx), strings.Join(opts.Tags, ","), matches)
+	}
+	stmt, err := mr.stmt(ctx, db, query)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, append(args, opts.limit())...)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	defer rows.Close()
+
+	results := make([]Message, 0)
+
+	for rows.Next() {
+		var msg Message
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return nil, parseError(getError)
+		}
+		results = append(results, msg)
+	}
+	return results, nil
+}
+
+func (mr *messageRepo) GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr) {
+	stmt, err := mr.stmt(ctx, mr.db, queryGetMessageByTitle)
+	if err != nil {
+		return nil, error_formats.ParseError(err)
+	}
+
+	var msg Message
+	result := stmt.QueryRowContext(ctx, TenantFrom(ctx), title)
+	if getError := scanMessage(result, &msg); getError != nil {
+		return nil, error_formats.ParseError(getError)
+	}
+	return &msg, nil
+}
+
+//Stream calls fn with every message, in id order, without holding the whole table in memory.
+//It stops at the first error returned by fn.
+func (mr *messageRepo) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryStreamMessages)
+	if err != nil {
+		return parseError(err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, TenantFrom(ctx))
+	if err != nil {
+		return parseError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg Message
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return parseError(getError)
+		}
+		if err := fn(msg); err != nil {
+			return error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to stream message: %s", err.Error()))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return parseError(err)
+	}
+	return nil
+}
+
+//Count returns how many messages the tenant holds
+func (mr *messageRepo) Count(ctx context.Context) (int64, error_utils.MessageErr) {
+	stmt, err := mr.stmt(ctx, mr.db, queryCountMessages)
+	if err != nil {
+		return 0, error_formats.ParseError(err)
+	}
+
+	var count int64
+	if getError := stmt.QueryRowContext(ctx, TenantFrom(ctx)).Scan(&count); getError != nil {
+		return 0, error_formats.ParseError(getError)
+	}
+	return count, nil
+}
+
+//Replies returns one page of the direct replies of the message, ordered by id. opts.Title is not used.
+func (mr *messageRepo) Replies(ctx context.Context, parentId int64, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryListReplies)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, TenantFrom(ctx), parentId, opts.AfterId, opts.limit())
+	if err != nil {
+		return nil, parseError(err)
+	}
+	defer rows.Close()
+
+	results := make([]Message, 0)
+
+	for rows.Next() {
+		var msg Message
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return nil, parseError(getError)
+		}
+		results = append(results, msg)
+	}
+	return results, nil
+}
+
+//Thread returns the message and its replies down to depth levels below it, in id order
+func (mr *messageRepo) Thread(ctx context.Context, messageId int64, depth int) ([]Message, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryGetThread)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, messageId, TenantFrom(ctx), TenantFrom(ctx), depth)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	defer rows.Close()
+
+	results := make([]Message, 0)
+
+	for rows.Next() {
+		var msg Message
+		if getError := scanMessage(rows, &msg); getError != nil {
+			return nil, parseError(getError)
+		}
+		results = append(results, msg)
+	}
+	if len(results) == 0 {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return results, nil
+}
+
+func (mr *messageRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
 	fmt.Println("WE REACHED THE DOMAIN")
-	stmt, err := mr.db.Prepare(queryInsertMessage)
+	defer mr.replicas.wrote(ctx)
+	//a new message has no replies, whatever the caller says
+	msg.ReplyCount = 0
+	if len(msg.Tags) > 0 {
+		//the message and its tags are saved together or not at all
+		err := mr.inTx(ctx, func(tx *sql.Tx) error {
+			insertResult, err := tx.ExecContext(ctx, queryInsertMessage, TenantFrom(ctx), sql.NullInt64{Int64: msg.ParentId, Valid: msg.ParentId != 0}, msg.Title, msg.Body, msg.CreatedAt)
+			if err != nil {
+				return err
+			}
+			if msg.Id, err = insertResult.LastInsertId(); err != nil {
+				return err
+			}
+			return writeTags(ctx, tx, msg.Id, msg.Tags)
+		})
+		if err != nil {
+			msg.Id = 0
+			return nil, err
+		}
+		return msg, nil
+	}
+	stmt, err := mr.stmt(ctx, mr.db, queryInsertMessage)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to save: %s", err.Error()))
+		return nil, error_formats.ParseError(err)
 	}
 	fmt.Println("WE DIDNT REACH HERE")
 
-	defer stmt.Close()
-
-	insertResult, createErr := stmt.Exec(msg.Title, msg.Body, msg.CreatedAt)
+	insertResult, createErr := stmt.ExecContext(ctx, TenantFrom(ctx), sql.NullInt64{Int64: msg.ParentId, Valid: msg.ParentId != 0}, msg.Title, msg.Body, msg.CreatedAt)
 	if createErr != nil {
-		return nil,  error_formats.ParseError(createErr)
+		return nil, error_formats.ParseError(createErr)
 	}
 	msgId, err := insertResult.LastInsertId()
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error()))
+		return nil, error_formats.ParseError(err)
 	}
 	msg.Id = msgId
 
 	return msg, nil
 }
 
-func (mr *messageRepo) Update(msg *Message) (*Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryUpdateMessage)
+func (mr *messageRepo) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	defer mr.replicas.wrote(ctx)
+	if msg.Tags != nil {
+		err := mr.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, queryUpdateMessage, msg.Title, msg.Body, msg.Id, TenantFrom(ctx)); err != nil {
+				return err
+			}
+			return writeTags(ctx, tx, msg.Id, msg.Tags)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	stmt, err := mr.stmt(ctx, mr.db, queryUpdateMessage)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to update: %s", err.Error()))
+		return nil, error_formats.ParseError(err)
 	}
-	defer stmt.Close()
 
-	_, updateErr := stmt.Exec(msg.Title, msg.Body, msg.Id)
+	_, updateErr := stmt.ExecContext(ctx, msg.Title, msg.Body, msg.Id, TenantFrom(ctx))
 	if updateErr != nil {
-		return nil,  error_formats.ParseError(updateErr)
+		return nil, error_formats.ParseError(updateErr)
 	}
 	return msg, nil
 }
 
-func (mr *messageRepo) Delete(msgId int64) error_utils.MessageErr {
-	stmt, err := mr.db.Prepare(queryDeleteMessage)
+func (mr *messageRepo) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	defer mr.replicas.wrote(ctx)
+	stmt, err := mr.stmt(ctx, mr.db, queryDeleteMessage)
+	if err != nil {
+		return error_formats.ParseError(err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, msgId, TenantFrom(ctx), TenantFrom(ctx)); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
+
+func (mr *messageRepo) DeleteLeaf(ctx context.Context, msgId int64) error_utils.MessageErr {
+	defer mr.replicas.wrote(ctx)
+	return mr.inTx(ctx, func(tx *sql.Tx) error {
+		var replies int64
+		if err := tx.QueryRowContext(ctx, queryLockReplies, msgId, TenantFrom(ctx)).Scan(&replies); err != nil {
+			return err
+		}
+		if replies > 0 {
+			return error_formats.HasReplies(replies)
+		}
+		_, err := tx.ExecContext(ctx, queryDeleteOneMessage, msgId, TenantFrom(ctx))
+		return err
+	})
+}
+
+//Detach makes the direct replies of the message start threads of their own
+func (mr *messageRepo) Detach(ctx context.Context, msgId int64) error_utils.MessageErr {
+	defer mr.replicas.wrote(ctx)
+	stmt, err := mr.stmt(ctx, mr.db, queryDetachReplies)
 	if err != nil {
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message: %s", err.Error()))
+		return error_formats.ParseError(err)
 	}
-	defer stmt.Close()
 
-	if _, err := stmt.Exec(msgId); err != nil {
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message %s", err.Error()))
+	if _, err := stmt.ExecContext(ctx, msgId, TenantFrom(ctx)); err != nil {
+		return error_formats.ParseError(err)
 	}
 	return nil
 }
+
+//inTx runs fn in a transaction on the primary, which is committed when fn returns nil and rolled back otherwise.
+//fn returns either a database error or a MessageErr, which is passed on as it is.
+func (mr *messageRepo) inTx(ctx context.Context, fn func(*sql.Tx) error) error_utils.MessageErr {
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return error_formats.ParseError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		if msgErr, ok := err.(error_utils.MessageErr); ok {
+			return msgErr
+		}
+		return error_formats.ParseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
+
+//writeTags replaces the tags of the message with the given ones, creating the tags the tenant does not have yet
+func writeTags(ctx context.Context, tx *sql.Tx, msgId int64, tags []string) error {
+	if _, err := tx.ExecContext(ctx, queryClearTags, msgId, TenantFrom(ctx)); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		tagId, err := upsertTag(ctx, tx, tag)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, queryTagMessage, tagId, msgId, TenantFrom(ctx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//upsertTag returns the id of the tag of the tenant with the given name, creating it when needed
+func upsertTag(ctx context.Context, tx *sql.Tx, tag string) (int64, error) {
+	result, err := tx.ExecContext(ctx, queryUpsertTag, TenantFrom(ctx), tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+//countTags counts how many of the given tags the tenant has
+func countTags(ctx context.Context, tx *sql.Tx, tags ...string) (int64, error) {
+	var count int64
+	err := tx.QueryRowContext(ctx, queryCountTags, TenantFrom(ctx), strings.Join(tags, ",")).Scan(&count)
+	return count, err
+}
+
+//Tags returns the tags of the tenant that messages carry, by name, with the number of messages carrying each
+func (mr *messageRepo) Tags(ctx context.Context) ([]TagCount, error_utils.MessageErr) {
+	db, parseError := mr.reader(ctx)
+	stmt, err := mr.stmt(ctx, db, queryGetTags)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, TenantFrom(ctx))
+	if err != nil {
+		return nil, parseError(err)
+	}
+	defer rows.Close()
+
+	results := make([]TagCount, 0)
+
+	for rows.Next() {
+		var tag TagCount
+		if getError := rows.Scan(&tag.Name, &tag.Count); getError != nil {
+			return nil, parseError(getError)
+		}
+		results = append(results, tag)
+	}
+	return results, nil
+}
+
+//RenameTag gives the tag a new name on every message carrying it. The tags no message carries any more
+//are dropped first, so that they neither stand in the way of the new name nor can be renamed.
+func (mr *messageRepo) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	defer mr.replicas.wrote(ctx)
+	return mr.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, queryPurgeTags, TenantFrom(ctx)); err != nil {
+			return err
+		}
+		if count, err := countTags(ctx, tx, from); err != nil || count == 0 {
+			if err != nil {
+				return err
+			}
+			return error_utils.NewNotFoundError("no tag matching given name")
+		}
+		if from == to {
+			return nil
+		}
+		if count, err := countTags(ctx, tx, to); err != nil || count > 0 {
+			if err != nil {
+				return err
+			}
+			return error_formats.TagTaken()
+		}
+		_, err := tx.ExecContext(ctx, queryRenameTag, to, TenantFrom(ctx), from)
+		return err
+	})
+}
+
+//MergeTags moves the messages carrying any of the tags from to the tag into, created when needed, and drops the tags from.
+//Like RenameTag, it drops the tags no message carries any more first.
+func (mr *messageRepo) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	defer mr.replicas.wrote(ctx)
+	return mr.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, queryPurgeTags, TenantFrom(ctx)); err != nil {
+			return err
+		}
+		if count, err := countTags(ctx, tx, from...); err != nil || count == 0 {
+			if err != nil {
+				return err
+			}
+			return error_utils.NewNotFoundError("no tag matching given names")
+		}
+		tagId, err := upsertTag(ctx, tx, into)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, queryMergeTags, tagId, TenantFrom(ctx), strings.Join(from, ",")); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, queryDeleteTags, TenantFrom(ctx), strings.Join(from, ","), into)
+		return err
+	})
+}
@@ -2,25 +2,105 @@ package domain
 
 import (
 	"efficient-api/utils/error_utils"
+	"fmt"
 	"strings"
 	"time"
 )
 
 type Message struct {
-	Id        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
+	Id int64 `json:"id"`
+	//ParentId is the message this one replies to, 0 for a message starting a thread
+	ParentId int64  `json:"parent_id,omitempty"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	//Tags are sorted and lowercase once validated. Update keeps the tags of the message when they are nil,
+	//and removes them all when they are empty.
+	Tags      []string  `json:"tags,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	//ReplyCount is the number of direct replies, it is set by the repository and ignored on writes
+	ReplyCount int64 `json:"reply_count"`
 }
 
-func (m *Message) Validate() error_utils.MessageErr {
-	m.Title = strings.TrimSpace(m.Title)
-	m.Body = strings.TrimSpace(m.Body)
-	if m.Title == "" {
-		return error_utils.NewUnprocessibleEntityError("Please enter a valid title")
+//Validate normalizes the message and reports every problem with it against the rules at once, as field errors
+func (m *Message) Validate(r Rules) error_utils.MessageErr {
+	m.Title = r.normalize(m.Title)
+	m.Body = r.normalize(m.Body)
+	fields := make([]error_utils.FieldError, 0)
+	if f := r.check("title", m.Title, r.TitleMaxLength, false); f != nil {
+		fields = append(fields, *f)
 	}
-	if m.Body == "" {
-		return error_utils.NewUnprocessibleEntityError("Please enter a valid body")
+	if f := r.check("body", m.Body, r.BodyMaxLength, true); f != nil {
+		fields = append(fields, *f)
+	}
+	m.Tags = NormalizeTags(m.Tags)
+	if f := checkTags(m.Tags); f != nil {
+		fields = append(fields, *f)
+	}
+	if len(fields) > 0 {
+		return error_utils.NewValidationError(fields...)
 	}
 	return nil
 }
+
+//checkTags returns the first problem with the tags of a message
+func checkTags(tags []string) *error_utils.FieldError {
+	if len(tags) > MaxTags {
+		return &error_utils.FieldError{Field: "tags", Code: error_utils.CodeTooManyTags, Message: fmt.Sprintf("A message can have at most %d tags", MaxTags)}
+	}
+	for _, tag := range tags {
+		if !ValidTag(tag) {
+			return &error_utils.FieldError{Field: "tags", Code: error_utils.CodeTagInvalid, Message: fmt.Sprintf("The tag %q should be at most %d letters, digits, dashes or underscores", tag, TagMaxLength)}
+		}
+	}
+	return nil
+}
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+//ListOptions describes one page of messages: at most Limit messages with an id greater than AfterId,
+//optionally restricted to titles containing Title and to the messages carrying any of Tags, or all of them
+//when Match is MatchAll. Tags are expected normalized. Callers exposing Limit to clients should cap it at MaxListLimit.
+type ListOptions struct {
+	Limit   int
+	AfterId int64
+	Title   string
+	Tags    []string
+	Match   string
+}
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return o.Limit
+}
+
+//escapeLike escapes the LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+//What to do when an imported message has the title of an existing one
+const (
+	OnDuplicateSkip      = "skip"
+	OnDuplicateOverwrite = "overwrite"
+	OnDuplicateFail      = "fail"
+)
+
+type ImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+//ImportReport summarizes an import. Every line of the input is counted in exactly one of
+//Created, Updated, Skipped and Failed.
+type ImportReport struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
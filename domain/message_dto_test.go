@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSchema_Matches_Message_Schema_File(t *testing.T) {
+	file, err := ioutil.ReadFile("message_schema.sql")
+	assert.Nil(t, err)
+	//only the whitespace may differ
+	assert.EqualValues(t, strings.Fields(string(file)), strings.Fields(Schema()))
+}
+
+func TestTagSchema_Matches_Tag_Schema_File(t *testing.T) {
+	file, err := ioutil.ReadFile("tag_schema.sql")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(TagSchema()))
+	assert.EqualValues(t, strings.Fields(string(file)), strings.Fields(strings.Join(TagSchema(), "\n")))
+}
+
+func TestTenantMigration_Matches_Migration_File(t *testing.T) {
+	file, err := ioutil.ReadFile("migrations/0001_tenants.sql")
+	assert.Nil(t, err)
+	assert.EqualValues(t, strings.Fields(string(file)), strings.Fields(strings.Join(TenantMigration, "\n")))
+}
+
+func TestThreadMigration_Matches_Migration_File(t *testing.T) {
+	file, err := ioutil.ReadFile("migrations/0002_threads.sql")
+	assert.Nil(t, err)
+	assert.EqualValues(t, strings.Fields(string(file)), strings.Fields(strings.Join(ThreadMigration, "\n")))
+}
+
+func TestMessage_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   Message
+		codes []string
+	}{
+		{name: "OK", msg: Message{Title: "the title", Body: "the body\non two lines\tand a tab"}},
+		{name: "Empty", msg: Message{Title: " ", Body: ""}, codes: []string{error_utils.CodeTitleRequired, error_utils.CodeBodyRequired}},
+		{name: "Too Long", msg: Message{Title: strings.Repeat("é", TitleMaxLength+1), Body: strings.Repeat("b", BodyMaxLength+1)}, codes: []string{error_utils.CodeTitleTooLong, error_utils.CodeBodyTooLong}},
+		{name: "Longest", msg: Message{Title: strings.Repeat("é", TitleMaxLength), Body: strings.Repeat("b", BodyMaxLength)}},
+		{name: "Control Characters", msg: Message{Title: "the\ntitle", Body: "the\x00body"}, codes: []string{error_utils.CodeTitleInvalidCharacter, error_utils.CodeBodyInvalidCharacter}},
+		{name: "Tags", msg: Message{Title: "the title", Body: "the body", Tags: []string{"Go", " go", "release-notes", "v1_2"}}},
+		{name: "Invalid Tag", msg: Message{Title: "the title", Body: "the body", Tags: []string{"go", "two words"}}, codes: []string{error_utils.CodeTagInvalid}},
+		{name: "Empty Tag", msg: Message{Title: "the title", Body: "the body", Tags: []string{" "}}, codes: []string{error_utils.CodeTagInvalid}},
+		{name: "Too Many Tags", msg: Message{Title: "the title", Body: "the body", Tags: strings.Split("a b c d e f g h i j k", " ")}, codes: []string{error_utils.CodeTooManyTags}},
+		{name: "Beyond U+FFFF", msg: Message{Title: "the title \U0001F600", Body: "the body \U00010348"}, codes: []string{error_utils.CodeTitleInvalidCharacter, error_utils.CodeBodyInvalidCharacter}},
+		{name: "Up To U+FFFF", msg: Message{Title: "the title \uFFFD", Body: "日本語"}},
+		{name: "Not UTF-8", msg: Message{Title: "the \xfftitle", Body: "the body\xc3"}, codes: []string{error_utils.CodeTitleInvalidCharacter, error_utils.CodeBodyInvalidCharacter}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate(DefaultRules())
+			if tt.codes == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.NotNil(t, err)
+			codes := make([]string, 0)
+			for _, f := range err.Fields() {
+				codes = append(codes, f.Code)
+			}
+			assert.EqualValues(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestMessage_Validate_Normalizes(t *testing.T) {
+	//"e" followed by a combining acute accent becomes a single "é"
+	msg := Message{Title: "  café ", Body: "body"}
+	assert.Nil(t, msg.Validate(DefaultRules()))
+	assert.EqualValues(t, "caf\u00e9", msg.Title)
+}
+
+func TestMessage_Validate_Custom_Rules(t *testing.T) {
+	r := DefaultRules()
+	r.TitleMaxLength = 5
+	r.Checkers = []Checker{Blocklist("Darn")}
+	assert.Nil(t, r.Validate())
+
+	msg := Message{Title: "too long", Body: "darn it"}
+	err := msg.Validate(r)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, []error_utils.FieldError{
+		{Field: "title", Code: error_utils.CodeTitleTooLong, Message: "The title should be at most 5 characters"},
+		{Field: "body", Code: error_utils.CodeBodyBlocked, Message: "The body contains a word that is not allowed"},
+	}, err.Fields())
+}
+
+func TestRules_Validate_Rejects_Lengths_Beyond_The_Columns(t *testing.T) {
+	r := DefaultRules()
+	r.BodyMaxLength = BodyMaxLength + 1
+	assert.NotNil(t, r.Validate())
+	assert.NotNil(t, Rules{}.Validate())
+}
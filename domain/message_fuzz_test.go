@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+//The literal cases of the other tests are the seed corpus. Run a target with e.g.
+//go test -run xxx -fuzz FuzzMessage_Validate ./domain
+func addMessageSeeds(f *testing.F) {
+	f.Add("the title", "the body")
+	f.Add("", "")
+	f.Add("  the title  ", "line one\nline two\ttabbed")
+	f.Add("café", "é")
+	f.Add("title\x00", "body\x07")
+	f.Add(strings.Repeat("t", TitleMaxLength+1), strings.Repeat("b", BodyMaxLength+1))
+	f.Add(strings.Repeat("é", TitleMaxLength), strings.Repeat("日", BodyMaxLength))
+	f.Add("\xff\xfe", "\xc3")
+	f.Add("\U0001F600", "\U00010348")
+}
+
+//Whatever Validate accepts can be stored and sent back as it is
+func FuzzMessage_Validate(f *testing.F) {
+	addMessageSeeds(f)
+	f.Fuzz(func(t *testing.T, title, body string) {
+		msg := Message{Title: title, Body: body}
+		if err := msg.Validate(DefaultRules()); err != nil {
+			if err.Status() != 422 || len(err.Fields()) == 0 {
+				t.Fatalf("Validate(%q, %q) should fail with field errors, got %d %q", title, body, err.Status(), err.Message())
+			}
+			return
+		}
+		for field, value := range map[string]string{"title": msg.Title, "body": msg.Body} {
+			max := TitleMaxLength
+			if field == "body" {
+				max = BodyMaxLength
+			}
+			if value == "" || utf8.RuneCountInString(value) > max {
+				t.Fatalf("the %s %q was accepted with %d characters", field, value, utf8.RuneCountInString(value))
+			}
+			if !utf8.ValidString(value) {
+				t.Fatalf("the %s %q was accepted although it is not valid utf-8", field, value)
+			}
+			if value != strings.TrimSpace(value) {
+				t.Fatalf("the %s %q was accepted with spaces around it", field, value)
+			}
+			if hasUnstorableCharacter(value) {
+				t.Fatalf("the %s %q was accepted with a character the columns cannot store", field, value)
+			}
+			if hasControlCharacter(value, field == "body") {
+				t.Fatalf("the %s %q was accepted with a control character", field, value)
+			}
+		}
+		//a valid message stays as it is when validated again
+		again := msg
+		if err := again.Validate(DefaultRules()); err != nil || !reflect.DeepEqual(again, msg) {
+			t.Fatalf("validating %q, %q again changed it to %q, %q (%v)", msg.Title, msg.Body, again.Title, again.Body, err)
+		}
+	})
+}
+
+//Any message accepted by Validate comes back unchanged from the memory repository, and through json
+func FuzzMemoryRepository_Round_Trip(f *testing.F) {
+	addMessageSeeds(f)
+	f.Fuzz(func(t *testing.T, title, body string) {
+		msg := Message{Title: title, Body: body, CreatedAt: created_at}
+		if msg.Validate(DefaultRules()) != nil {
+			return
+		}
+		want := msg
+
+		repo := NewMemoryRepository()
+		created, err := repo.Create(context.Background(), &msg)
+		if err != nil {
+			t.Fatalf("cannot create the valid message %q, %q: %s", want.Title, want.Body, err.Message())
+		}
+		want.Id = created.Id
+		got, err := repo.Get(context.Background(), created.Id)
+		if err != nil {
+			t.Fatalf("cannot get the message %d: %s", created.Id, err.Message())
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Fatalf("the message %q, %q came back as %q, %q", want.Title, want.Body, got.Title, got.Body)
+		}
+
+		b, encodeErr := json.Marshal(got)
+		if encodeErr != nil {
+			t.Fatalf("cannot encode the message: %v", encodeErr)
+		}
+		var decoded Message
+		if decodeErr := json.Unmarshal(b, &decoded); decodeErr != nil {
+			t.Fatalf("cannot decode %s: %v", b, decodeErr)
+		}
+		if decoded.Title != want.Title || decoded.Body != want.Body {
+			t.Fatalf("the message %q, %q came back from json as %q, %q", want.Title, want.Body, decoded.Title, decoded.Body)
+		}
+	})
+}
@@ -0,0 +1,428 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_formats"
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type memoryRepo struct {
+	mu       sync.RWMutex
+	messages map[int64]Message
+	//tenants holds the tenant of every message, by id
+	tenants map[int64]string
+	//replies counts the direct replies of every message, by id
+	replies map[int64]int64
+	lastId  int64
+}
+
+//NewMemoryRepository keeps the messages in memory, for tests and demos. Like the MySQL table,
+//it compares titles without regard to case and refuses values longer than TitleMaxLength and BodyMaxLength.
+//Ids are unique over every tenant, as they are in the table.
+func NewMemoryRepository() MessageRepository {
+	return &memoryRepo{messages: make(map[int64]Message), tenants: make(map[int64]string), replies: make(map[int64]int64)}
+}
+
+//contextErr fails the call when the caller gave up on it, as a database call would
+func contextErr(ctx context.Context) error_utils.MessageErr {
+	if err := ctx.Err(); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
+
+//fits checks the message against the size of the columns
+func fits(msg *Message) error_utils.MessageErr {
+	if utf8.RuneCountInString(msg.Title) > TitleMaxLength {
+		return error_formats.TooLong("title")
+	}
+	if utf8.RuneCountInString(msg.Body) > BodyMaxLength {
+		return error_formats.TooLong("body")
+	}
+	return nil
+}
+
+//titleTaken must be called with r.mu held
+func (r *memoryRepo) titleTaken(tenant string, title string, except int64) bool {
+	for id, msg := range r.messages {
+		if id != except && r.tenants[id] == tenant && strings.EqualFold(msg.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
+//withReplies sets the reply count of msg, it must be called with r.mu held
+func (r *memoryRepo) withReplies(msg Message) Message {
+	msg.ReplyCount = r.replies[msg.Id]
+	return msg
+}
+
+//copyTags keeps the stored tags apart from the caller's, sorted like the table returns them, and stores no tags as nil.
+//The stored slices are replaced, never changed, so the messages handed out can share them.
+func copyTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return NormalizeTags(tags)
+}
+
+//sorted returns the messages of the tenant in id order, it must be called with r.mu held
+func (r *memoryRepo) sorted(tenant string) []Message {
+	results := make([]Message, 0, len(r.messages))
+	for id, msg := range r.messages {
+		if r.tenants[id] == tenant {
+			results = append(results, r.withReplies(msg))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Id < results[j].Id })
+	return results
+}
+
+func (r *memoryRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	msg, ok := r.messages[messageId]
+	if !ok || r.tenants[messageId] != TenantFrom(ctx) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	msg = r.withReplies(msg)
+	return &msg, nil
+}
+
+func (r *memoryRepo) GetMany(ctx context.Context, messageIds []int64) ([]Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	results := make([]Message, 0, len(messageIds))
+	for _, msg := range r.sorted(TenantFrom(ctx)) {
+		for _, id := range messageIds {
+			if msg.Id == id {
+				results = append(results, msg)
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+func (r *memoryRepo) GetAll(ctx context.Context) ([]Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	messages := r.sorted(TenantFrom(ctx))
+	if len(messages) == 0 {
+		return nil, error_utils.NewNotFoundError("no records found")
+	}
+	return messages, nil
+}
+
+func (r *memoryRepo) List(ctx context.Context, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	results := make([]Message, 0)
+	for _, msg := range r.sorted(TenantFrom(ctx)) {
+		if len(results) == opts.limit() {
+			break
+		}
+		if msg.Id > opts.AfterId && strings.Contains(strings.ToLower(msg.Title), strings.ToLower(opts.Title)) && opts.hasTags(msg.Tags) {
+			results = append(results, msg)
+		}
+	}
+	return results, nil
+}
+
+func (r *memoryRepo) GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for id, msg := range r.messages {
+		if r.tenants[id] == TenantFrom(ctx) && strings.EqualFold(msg.Title, title) {
+			msg = r.withReplies(msg)
+			return &msg, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+
+//Stream works on a copy of the messages, so that fn may call the repository
+func (r *memoryRepo) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	messages := r.sorted(TenantFrom(ctx))
+	r.mu.RUnlock()
+	for _, msg := range messages {
+		if err := fn(msg); err != nil {
+			return error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to stream message: %s", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	if err := fits(msg); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.titleTaken(TenantFrom(ctx), msg.Title, 0) {
+		return nil, error_formats.TitleTaken()
+	}
+	r.lastId++
+	msg.Id = r.lastId
+	msg.ReplyCount = 0
+	msg.Tags = copyTags(msg.Tags)
+	r.messages[msg.Id] = *msg
+	r.tenants[msg.Id] = TenantFrom(ctx)
+	if msg.ParentId != 0 {
+		r.replies[msg.ParentId]++
+	}
+	return msg, nil
+}
+
+func (r *memoryRepo) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	if err := fits(msg); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.messages[msg.Id]
+	if !ok || r.tenants[msg.Id] != TenantFrom(ctx) {
+		return msg, nil
+	}
+	if r.titleTaken(TenantFrom(ctx), msg.Title, msg.Id) {
+		return nil, error_formats.TitleTaken()
+	}
+	current.Title = msg.Title
+	current.Body = msg.Body
+	if msg.Tags != nil {
+		current.Tags = copyTags(msg.Tags)
+	}
+	r.messages[msg.Id] = current
+	return msg, nil
+}
+
+func (r *memoryRepo) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.messages[msgId]; ok && r.tenants[msgId] == TenantFrom(ctx) {
+		r.remove(msgId)
+	}
+	return nil
+}
+
+func (r *memoryRepo) DeleteLeaf(ctx context.Context, msgId int64) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.messages[msgId]; !ok || r.tenants[msgId] != TenantFrom(ctx) {
+		return nil
+	}
+	if replies := r.replies[msgId]; replies > 0 {
+		return error_formats.HasReplies(replies)
+	}
+	r.remove(msgId)
+	return nil
+}
+
+//remove deletes the message and its replies, it must be called with r.mu held
+func (r *memoryRepo) remove(msgId int64) {
+	for id, msg := range r.messages {
+		if msg.ParentId == msgId {
+			r.remove(id)
+		}
+	}
+	if parentId := r.messages[msgId].ParentId; parentId != 0 {
+		r.replies[parentId]--
+	}
+	delete(r.messages, msgId)
+	delete(r.tenants, msgId)
+	delete(r.replies, msgId)
+}
+
+func (r *memoryRepo) Count(ctx context.Context) (int64, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return 0, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.sorted(TenantFrom(ctx)))), nil
+}
+
+func (r *memoryRepo) Replies(ctx context.Context, parentId int64, opts ListOptions) ([]Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	results := make([]Message, 0)
+	for _, msg := range r.sorted(TenantFrom(ctx)) {
+		if len(results) == opts.limit() {
+			break
+		}
+		if msg.ParentId == parentId && msg.Id > opts.AfterId {
+			results = append(results, msg)
+		}
+	}
+	return results, nil
+}
+
+func (r *memoryRepo) Thread(ctx context.Context, messageId int64, depth int) ([]Message, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.messages[messageId]; !ok || r.tenants[messageId] != TenantFrom(ctx) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	//messages are sorted by id and replies are created after their parent, so a parent is always seen before its replies
+	depths := map[int64]int{messageId: 0}
+	results := make([]Message, 0)
+	for _, msg := range r.sorted(TenantFrom(ctx)) {
+		if msg.Id == messageId {
+			results = append(results, msg)
+			continue
+		}
+		if parentDepth, ok := depths[msg.ParentId]; ok && parentDepth < depth {
+			depths[msg.Id] = parentDepth + 1
+			results = append(results, msg)
+		}
+	}
+	return results, nil
+}
+
+func (r *memoryRepo) Detach(ctx context.Context, msgId int64) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, msg := range r.messages {
+		if msg.ParentId == msgId && r.tenants[id] == TenantFrom(ctx) {
+			msg.ParentId = 0
+			r.messages[id] = msg
+		}
+	}
+	if r.tenants[msgId] == TenantFrom(ctx) {
+		delete(r.replies, msgId)
+	}
+	return nil
+}
+
+//tagged returns the ids of the messages of the tenant carrying any of the tags, it must be called with r.mu held
+func (r *memoryRepo) tagged(tenant string, tags ...string) []int64 {
+	opts := ListOptions{Tags: tags}
+	ids := make([]int64, 0)
+	for id, msg := range r.messages {
+		if r.tenants[id] == tenant && opts.hasTags(msg.Tags) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (r *memoryRepo) Tags(ctx context.Context) ([]TagCount, error_utils.MessageErr) {
+	if err := contextErr(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[string]int64)
+	for id, msg := range r.messages {
+		if r.tenants[id] == TenantFrom(ctx) {
+			for _, tag := range msg.Tags {
+				counts[tag]++
+			}
+		}
+	}
+	results := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		results = append(results, TagCount{Name: name, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+func (r *memoryRepo) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := r.tagged(TenantFrom(ctx), from)
+	if len(ids) == 0 {
+		return error_utils.NewNotFoundError("no tag matching given name")
+	}
+	if from == to {
+		return nil
+	}
+	if len(r.tagged(TenantFrom(ctx), to)) > 0 {
+		return error_formats.TagTaken()
+	}
+	r.retag(ids, []string{from}, to)
+	return nil
+}
+
+func (r *memoryRepo) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	if err := contextErr(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := r.tagged(TenantFrom(ctx), from...)
+	if len(ids) == 0 {
+		return error_utils.NewNotFoundError("no tag matching given names")
+	}
+	r.retag(ids, from, into)
+	return nil
+}
+
+//retag replaces the tags from with the tag to on the given messages, it must be called with r.mu held
+func (r *memoryRepo) retag(ids []int64, from []string, to string) {
+	replaced := make(map[string]bool, len(from))
+	for _, tag := range from {
+		replaced[tag] = true
+	}
+	for _, id := range ids {
+		msg := r.messages[id]
+		tags := []string{to}
+		for _, tag := range msg.Tags {
+			if !replaced[tag] {
+				tags = append(tags, tag)
+			}
+		}
+		msg.Tags = copyTags(tags)
+		r.messages[id] = msg
+	}
+}
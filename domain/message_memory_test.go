@@ -0,0 +1,24 @@
+package domain_test
+
+import (
+	"efficient-api/conformance"
+	"efficient-api/domain"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	conformance.Repository(t, func(t *testing.T) domain.MessageRepository {
+		return domain.NewMemoryRepository()
+	})
+}
+
+//The decorators must not change what the repository underneath does
+func TestDecorators_Conformance(t *testing.T) {
+	conformance.Repository(t, func(t *testing.T) domain.MessageRepository {
+		repo := domain.NewMemoryRepository()
+		repo = domain.NewRetryingRepository(repo, domain.DefaultRetryPolicy())
+		repo = domain.NewCircuitBreaker(repo, domain.DefaultBreakerSettings())
+		return domain.NewCachingRepository(repo, domain.NewLRUCache(100, time.Minute))
+	})
+}
@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"efficient-api/utils/error_formats"
+	"efficient-api/utils/error_utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ReplicaSettings say how the reads are spread over the replicas
+type ReplicaSettings struct {
+	//CheckInterval is how often a replica is pinged
+	CheckInterval time.Duration
+	//StickyWindow is how long a client keeps reading from the primary after it wrote something, for the replicas to catch up
+	StickyWindow time.Duration
+}
+
+func DefaultReplicaSettings() ReplicaSettings {
+	return ReplicaSettings{
+		CheckInterval: 5 * time.Second,
+		StickyWindow:  2 * time.Second,
+	}
+}
+
+type sessionKey struct{}
+
+//WithSession tags the context with the client it comes from, so that the client reads its own writes
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFrom(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+//StickyReporter is implemented by repositories sending the reads of a client to the primary for a while after it wrote,
+//so that the layers above them can tell the client is waiting for the replicas to catch up
+type StickyReporter interface {
+	Sticky(ctx context.Context) bool
+}
+
+//isSticky tells whether repo sends the reads made with ctx to the primary for now
+func isSticky(ctx context.Context, repo MessageRepository) bool {
+	if reporter, ok := repo.(StickyReporter); ok {
+		return reporter.Sticky(ctx)
+	}
+	return false
+}
+
+//replica is a read only copy of the database, used while it answers pings
+type replica struct {
+	db            *sql.DB
+	checkInterval time.Duration
+	healthy       int32
+	checking      int32
+	checkedAt     int64
+}
+
+func newReplica(db *sql.DB, checkInterval time.Duration) *replica {
+	return &replica{db: db, checkInterval: checkInterval, healthy: 1, checkedAt: time.Now().UnixNano()}
+}
+
+//isHealthy returns what the last ping said, and pings again in the background when that is too old
+func (r *replica) isHealthy() bool {
+	if time.Since(time.Unix(0, atomic.LoadInt64(&r.checkedAt))) > r.checkInterval && atomic.CompareAndSwapInt32(&r.checking, 0, 1) {
+		go r.check()
+	}
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) check() {
+	defer atomic.StoreInt32(&r.checking, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.db.PingContext(ctx); err != nil {
+		r.markUnhealthy()
+		return
+	}
+	atomic.StoreInt32(&r.healthy, 1)
+	atomic.StoreInt64(&r.checkedAt, time.Now().UnixNano())
+}
+
+func (r *replica) markUnhealthy() {
+	atomic.StoreInt32(&r.healthy, 0)
+	atomic.StoreInt64(&r.checkedAt, time.Now().UnixNano())
+}
+
+//replicaSet picks the replica of each read in turn, skipping the ones that are down,
+//and remembers which clients wrote recently so that they read from the primary
+type replicaSet struct {
+	replicas     []*replica
+	next         uint32
+	stickyWindow time.Duration
+
+	mu     sync.Mutex
+	sticky map[string]time.Time
+}
+
+func newReplicaSet(dbs []*sql.DB, settings ReplicaSettings) *replicaSet {
+	set := &replicaSet{stickyWindow: settings.StickyWindow, sticky: make(map[string]time.Time)}
+	for _, db := range dbs {
+		set.replicas = append(set.replicas, newReplica(db, settings.CheckInterval))
+	}
+	return set
+}
+
+//pick returns the replica to read from, or nil when the read should go to the primary
+func (s *replicaSet) pick(ctx context.Context) *replica {
+	if s == nil || len(s.replicas) == 0 {
+		return nil
+	}
+	if s.inWindow(ctx) {
+		return nil
+	}
+	start := atomic.AddUint32(&s.next, 1)
+	for i := 0; i < len(s.replicas); i++ {
+		r := s.replicas[(int(start)+i)%len(s.replicas)]
+		if r.isHealthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+//inWindow tells whether the client wrote within the last sticky window
+func (s *replicaSet) inWindow(ctx context.Context) bool {
+	session := sessionFrom(ctx)
+	if s == nil || len(s.replicas) == 0 || session == "" {
+		return false
+	}
+	s.mu.Lock()
+	until, ok := s.sticky[session]
+	s.mu.Unlock()
+	return ok && time.Now().Before(until)
+}
+
+//wrote sends the reads of the client to the primary for the next sticky window, until the replicas caught up
+func (s *replicaSet) wrote(ctx context.Context) {
+	session := sessionFrom(ctx)
+	if s == nil || len(s.replicas) == 0 || session == "" {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sticky[session] = now.Add(s.stickyWindow)
+	//forget the clients whose window is over, now and then
+	if len(s.sticky) > 1000 {
+		for k, until := range s.sticky {
+			if now.After(until) {
+				delete(s.sticky, k)
+			}
+		}
+	}
+}
+
+//Sticky tells whether the reads of the client go to the primary for now, as it wrote within the sticky window
+func (mr *messageRepo) Sticky(ctx context.Context) bool {
+	return mr.replicas.inWindow(ctx)
+}
+
+//reader returns the database the read should go to, and the function turning the errors of the read into a MessageErr.
+//A replica that cannot be reached is left alone until it answers a ping again, so that the retry of the read goes elsewhere.
+func (mr *messageRepo) reader(ctx context.Context) (*sql.DB, func(error) error_utils.MessageErr) {
+	r := mr.replicas.pick(ctx)
+	if r == nil {
+		return mr.db, error_formats.ParseError
+	}
+	return r.db, func(err error) error_utils.MessageErr {
+		msgErr := error_formats.ParseError(err)
+		if msgErr.Code() == error_utils.CodeUnavailable {
+			r.markUnhealthy()
+		}
+		return msgErr
+	}
+}
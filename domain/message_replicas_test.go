@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"efficient-api/utils/error_utils"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+	"time"
+)
+
+type mockDB struct {
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func newMockDBs(t *testing.T, n int) []mockDB {
+	dbs := make([]mockDB, n)
+	for i := range dbs {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		dbs[i] = mockDB{db: db, mock: mock}
+	}
+	return dbs
+}
+
+func expectGet(m mockDB, times int) {
+	prepared := m.mock.ExpectPrepare("SELECT (.+) FROM messages WHERE id")
+	for i := 0; i < times; i++ {
+		rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+		prepared.ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
+	}
+}
+
+func checkExpectations(t *testing.T, dbs []mockDB) {
+	for i, m := range dbs {
+		if err := m.mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("database %d: there were unfulfilled expectations: %s", i, err)
+		}
+		m.db.Close()
+	}
+}
+
+func TestMessageRepo_Reads_Go_To_Replicas_In_Turn(t *testing.T) {
+	dbs := newMockDBs(t, 3)
+	s := NewMessageRepository(dbs[0].db, dbs[1].db, dbs[2].db)
+
+	//the primary only gets the write, the replicas share the reads
+	expectGet(dbs[1], 2)
+	expectGet(dbs[2], 2)
+	dbs[0].mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(1, DefaultTenant, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.Get(context.Background(), 1); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+	if err := s.Delete(context.Background(), 1); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	checkExpectations(t, dbs)
+}
+
+func TestMessageRepo_Reads_Own_Writes(t *testing.T) {
+	dbs := newMockDBs(t, 2)
+	s := NewMessageRepository(dbs[0].db, dbs[1].db)
+	writer := WithSession(context.Background(), "writer")
+	reader := WithSession(context.Background(), "reader")
+
+	dbs[0].mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("title", "body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	//the client that wrote reads from the primary, the others still read from the replica
+	expectGet(dbs[0], 1)
+	expectGet(dbs[1], 1)
+
+	if _, err := s.Update(writer, &Message{Id: 1, Title: "title", Body: "body"}); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if _, err := s.Get(writer, 1); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if _, err := s.Get(reader, 1); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	checkExpectations(t, dbs)
+}
+
+//Without a sticky window, the client that wrote reads from the replica right away
+func TestMessageRepo_Sticky_Window_From_Settings(t *testing.T) {
+	dbs := newMockDBs(t, 2)
+	s := NewReplicatedRepository(dbs[0].db, []*sql.DB{dbs[1].db}, ReplicaSettings{CheckInterval: time.Minute})
+	writer := WithSession(context.Background(), "writer")
+
+	dbs[0].mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("title", "body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectGet(dbs[1], 1)
+
+	if _, err := s.Update(writer, &Message{Id: 1, Title: "title", Body: "body"}); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if _, err := s.Get(writer, 1); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	checkExpectations(t, dbs)
+}
+
+//The cache loads its misses from the replicas, but a client that just wrote skips it and reads its writes from the primary
+func TestCachingRepo_Sticky_Client_Skips_The_Cache(t *testing.T) {
+	dbs := newMockDBs(t, 2)
+	s := NewCachingRepository(NewMessageRepository(dbs[0].db, dbs[1].db), NewLRUCache(10, time.Minute))
+	reader := WithSession(context.Background(), "reader")
+	writer := WithSession(context.Background(), "writer")
+
+	//the reader loads from the replica once before the write and once after it, the writer reads the primary each time
+	expectGet(dbs[1], 2)
+	dbs[0].mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("title", "body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectGet(dbs[0], 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(reader, 1); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+	if _, err := s.Update(writer, &Message{Id: 1, Title: "title", Body: "body"}); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if _, err := s.Get(reader, 1); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(writer, 1); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+	checkExpectations(t, dbs)
+}
+
+func TestMessageRepo_Skips_Unreachable_Replica(t *testing.T) {
+	dbs := newMockDBs(t, 2)
+	s := NewMessageRepository(dbs[0].db, dbs[1].db)
+
+	dbs[1].mock.ExpectPrepare("SELECT (.+) FROM messages WHERE id").ExpectQuery().WithArgs(1, DefaultTenant).WillReturnError(mysql.ErrInvalidConn)
+	//once the replica failed, reads go to the primary until it answers a ping again
+	expectGet(dbs[0], 2)
+
+	_, err := s.Get(context.Background(), 1)
+	if err == nil || err.Code() != error_utils.CodeUnavailable {
+		t.Fatalf("Get() error = %v, want %s", err, error_utils.CodeUnavailable)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(context.Background(), 1); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+	checkExpectations(t, dbs)
+}
+
+func TestMessageRepo_Reads_From_Primary_Without_Replicas(t *testing.T) {
+	dbs := newMockDBs(t, 1)
+	s := NewMessageRepository(dbs[0].db)
+
+	expectGet(dbs[0], 1)
+	if _, err := s.Get(WithSession(context.Background(), "reader"), 1); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	checkExpectations(t, dbs)
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+)
+
+//MessageRepository stores the messages. Connect and NewMessageRepository return the MySQL one and NewMemoryRepository
+//one that lives in memory. The decorators in this package add retries, a circuit breaker and a cache on top of any of them.
+//
+//The errors are the same whatever the storage, so that the layers above can rely on their status and code:
+//   - 404 not_found when a message asked for does not exist
+//   - 409 conflict, code title_taken, when a write would give two messages the same title
+//   - 422 invalid_request, code validation_failed, when a title or a body is longer than TitleMaxLength or BodyMaxLength
+//   - 503 service_unavailable when the storage cannot be reached or the context is done; these may succeed when tried again
+//   - 500 server_error for anything else
+//
+//Messages read from a repository have their ReplyCount set, and the ReplyCount given to a write is ignored.
+//Their Tags are sorted, and nil when they have none. Tags are expected normalized and valid, see NormalizeTags.
+//
+//Every method only sees the messages of the tenant of its context, see WithTenant: another tenant's message is a 404
+//to Get, and is left alone by Update and Delete. Titles are unique within a tenant.
+//
+//Validating messages is the job of the service, not of the repository. The conformance package checks an implementation against this contract.
+type MessageRepository interface {
+	//Get returns the message with the given id, or a 404
+	Get(context.Context, int64) (*Message, error_utils.MessageErr)
+	//GetMany returns the messages with the given ids, in id order. The ids matching no message are left out, which is not an error.
+	GetMany(context.Context, []int64) ([]Message, error_utils.MessageErr)
+	//Create saves the message, sets its Id and returns it. It returns a 409 when the title is taken.
+	Create(context.Context, *Message) (*Message, error_utils.MessageErr)
+	//Update saves the title and the body of the message with the same Id, and its tags unless they are nil, and returns it.
+	//It returns a 409 when the title is taken by another message. Updating a message that does not exist is not an error, the service checks that beforehand.
+	Update(context.Context, *Message) (*Message, error_utils.MessageErr)
+	//Delete removes the message with the given id and its replies, at any depth. Deleting a message that does not exist is not an error.
+	Delete(context.Context, int64) error_utils.MessageErr
+	//DeleteLeaf removes the message with the given id unless it has replies, checking and deleting at once so that no reply
+	//can be added in between. It returns a 409, code has_replies, with the number of replies in the details when it has some.
+	//Deleting a message that does not exist is not an error.
+	DeleteLeaf(context.Context, int64) error_utils.MessageErr
+	//GetAll returns every message, or a 404 when there are none
+	GetAll(context.Context) ([]Message, error_utils.MessageErr)
+	//List returns one page of messages ordered by id, see ListOptions for the title and the tags they are filtered by. An empty page is not an error.
+	List(context.Context, ListOptions) ([]Message, error_utils.MessageErr)
+	//GetByTitle returns the message with the given title, or a 404
+	GetByTitle(context.Context, string) (*Message, error_utils.MessageErr)
+	//Stream calls fn with every message, in id order, and stops with a 500 at the first error returned by fn
+	Stream(context.Context, func(Message) error) error_utils.MessageErr
+	//Count returns how many messages there are
+	Count(context.Context) (int64, error_utils.MessageErr)
+	//Replies returns one page of the direct replies of the message with the given id, ordered by id. ListOptions.Title is not used.
+	//An empty page is not an error, whether the message exists or not.
+	Replies(context.Context, int64, ListOptions) ([]Message, error_utils.MessageErr)
+	//Thread returns the message with the given id and its replies down to the given depth below it, in id order, or a 404
+	Thread(context.Context, int64, int) ([]Message, error_utils.MessageErr)
+	//Detach makes the direct replies of the message with the given id start threads of their own, setting their ParentId to 0
+	Detach(context.Context, int64) error_utils.MessageErr
+	//Tags returns the tags carried by at least one message, by name, with the number of messages carrying each
+	Tags(context.Context) ([]TagCount, error_utils.MessageErr)
+	//RenameTag renames a tag on every message carrying it. It returns a 404 when no message carries it,
+	//and a 409, code tag_taken, when a message carries the new name already.
+	RenameTag(ctx context.Context, from, to string) error_utils.MessageErr
+	//MergeTags replaces the tags from with the tag into on every message carrying them. It returns a 404 when no message carries any of them.
+	MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr
+}
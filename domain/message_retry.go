@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+	"expvar"
+	"log"
+	"math/rand"
+	"time"
+)
+
+//Retry counts, by repository method, published on /debug/vars
+var (
+	retryAttempts  = expvar.NewMap("message_repo_retries")
+	retryExhausted = expvar.NewMap("message_repo_retries_exhausted")
+)
+
+//RetryPolicy says how often and how long to wait before a failed call to the database is tried again
+type RetryPolicy struct {
+	//MaxAttempts counts the first call, 1 disables retries
+	MaxAttempts int
+	//The wait doubles after every attempt, from BaseDelay up to MaxDelay, and a random part of it is taken off
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+//DefaultRetryPolicy rides out a MySQL failover of about a second
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+}
+
+//delay returns the wait before the given retry, starting at 1, with "equal jitter":
+//half of the backoff is kept and the other half is random, so clients that failed together do not retry together.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay << uint(retry-1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+type retryingRepo struct {
+	repo   MessageRepository
+	policy RetryPolicy
+}
+
+//NewRetryingRepository wraps repo so that transient failures are tried again, following the policy.
+//Reads, updates, deletes and detaches are retried on any retryable error since running them twice does no harm.
+//Creates, leaf deletes, tag renames and tag merges are only retried when the database rolled the statement back, after a deadlock
+//or a lock wait timeout, as a lost connection leaves us not knowing whether the write was saved.
+func NewRetryingRepository(repo MessageRepository, policy RetryPolicy) MessageRepository {
+	return &retryingRepo{repo: repo, policy: policy}
+}
+
+//rolledBack reports whether the database undid the statement, which makes it safe to run again whatever it was
+func rolledBack(err error_utils.MessageErr) bool {
+	return err.Code() == error_utils.CodeDeadlock || err.Code() == error_utils.CodeLockTimeout
+}
+
+//retry calls fn until it succeeds, fails for good, the attempts run out or the context would expire while waiting
+func (r *retryingRepo) retry(ctx context.Context, op string, retryable func(error_utils.MessageErr) bool, fn func() error_utils.MessageErr) error_utils.MessageErr {
+	err := fn()
+	for attempt := 1; err != nil && retryable(err) && attempt < r.policy.MaxAttempts; attempt++ {
+		if ctx.Err() != nil {
+			break
+		}
+		wait := r.policy.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			break
+		}
+		log.Printf("retrying %s in %s after attempt %d failed: %s", op, wait, attempt, err.Message())
+		retryAttempts.Add(op, 1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	if err != nil && retryable(err) {
+		retryExhausted.Add(op, 1)
+	}
+	return err
+}
+
+//Sticky passes on whether the repository underneath sends the reads of the client to the primary
+func (r *retryingRepo) Sticky(ctx context.Context) bool {
+	return isSticky(ctx, r.repo)
+}
+
+func (r *retryingRepo) Get(ctx context.Context, messageId int64) (msg *Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Get", error_utils.Retryable, func() error_utils.MessageErr {
+		msg, err = r.repo.Get(ctx, messageId)
+		return err
+	})
+	return msg, err
+}
+
+func (r *retryingRepo) GetMany(ctx context.Context, messageIds []int64) (msgs []Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "GetMany", error_utils.Retryable, func() error_utils.MessageErr {
+		msgs, err = r.repo.GetMany(ctx, messageIds)
+		return err
+	})
+	return msgs, err
+}
+
+func (r *retryingRepo) GetAll(ctx context.Context) (msgs []Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "GetAll", error_utils.Retryable, func() error_utils.MessageErr {
+		msgs, err = r.repo.GetAll(ctx)
+		return err
+	})
+	return msgs, err
+}
+
+func (r *retryingRepo) List(ctx context.Context, opts ListOptions) (msgs []Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "List", error_utils.Retryable, func() error_utils.MessageErr {
+		msgs, err = r.repo.List(ctx, opts)
+		return err
+	})
+	return msgs, err
+}
+
+func (r *retryingRepo) GetByTitle(ctx context.Context, title string) (msg *Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "GetByTitle", error_utils.Retryable, func() error_utils.MessageErr {
+		msg, err = r.repo.GetByTitle(ctx, title)
+		return err
+	})
+	return msg, err
+}
+
+func (r *retryingRepo) Count(ctx context.Context) (count int64, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Count", error_utils.Retryable, func() error_utils.MessageErr {
+		count, err = r.repo.Count(ctx)
+		return err
+	})
+	return count, err
+}
+
+func (r *retryingRepo) Replies(ctx context.Context, parentId int64, opts ListOptions) (msgs []Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Replies", error_utils.Retryable, func() error_utils.MessageErr {
+		msgs, err = r.repo.Replies(ctx, parentId, opts)
+		return err
+	})
+	return msgs, err
+}
+
+func (r *retryingRepo) Thread(ctx context.Context, messageId int64, depth int) (msgs []Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Thread", error_utils.Retryable, func() error_utils.MessageErr {
+		msgs, err = r.repo.Thread(ctx, messageId, depth)
+		return err
+	})
+	return msgs, err
+}
+
+//Stream is only retried while nothing was handed to fn, or fn would see the same messages twice
+func (r *retryingRepo) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	streamed := false
+	return r.retry(ctx, "Stream", func(err error_utils.MessageErr) bool {
+		return !streamed && error_utils.Retryable(err)
+	}, func() error_utils.MessageErr {
+		return r.repo.Stream(ctx, func(msg Message) error {
+			streamed = true
+			return fn(msg)
+		})
+	})
+}
+
+func (r *retryingRepo) Create(ctx context.Context, msg *Message) (created *Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Create", rolledBack, func() error_utils.MessageErr {
+		created, err = r.repo.Create(ctx, msg)
+		return err
+	})
+	return created, err
+}
+
+func (r *retryingRepo) Update(ctx context.Context, msg *Message) (updated *Message, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Update", error_utils.Retryable, func() error_utils.MessageErr {
+		updated, err = r.repo.Update(ctx, msg)
+		return err
+	})
+	return updated, err
+}
+
+func (r *retryingRepo) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	return r.retry(ctx, "Delete", error_utils.Retryable, func() error_utils.MessageErr {
+		return r.repo.Delete(ctx, msgId)
+	})
+}
+
+//DeleteLeaf is not run again after a lost connection: a delete that was saved is no error, but one refused with a 409 is lost
+func (r *retryingRepo) DeleteLeaf(ctx context.Context, msgId int64) error_utils.MessageErr {
+	return r.retry(ctx, "DeleteLeaf", rolledBack, func() error_utils.MessageErr {
+		return r.repo.DeleteLeaf(ctx, msgId)
+	})
+}
+
+func (r *retryingRepo) Detach(ctx context.Context, msgId int64) error_utils.MessageErr {
+	return r.retry(ctx, "Detach", error_utils.Retryable, func() error_utils.MessageErr {
+		return r.repo.Detach(ctx, msgId)
+	})
+}
+
+func (r *retryingRepo) Tags(ctx context.Context) (tags []TagCount, err error_utils.MessageErr) {
+	err = r.retry(ctx, "Tags", error_utils.Retryable, func() error_utils.MessageErr {
+		tags, err = r.repo.Tags(ctx)
+		return err
+	})
+	return tags, err
+}
+
+//RenameTag is not run again after a lost connection: a rename that was saved would then fail with a 404
+func (r *retryingRepo) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	return r.retry(ctx, "RenameTag", rolledBack, func() error_utils.MessageErr {
+		return r.repo.RenameTag(ctx, from, to)
+	})
+}
+
+func (r *retryingRepo) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	return r.retry(ctx, "MergeTags", rolledBack, func() error_utils.MessageErr {
+		return r.repo.MergeTags(ctx, from, into)
+	})
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"context"
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//flakyRepo fails with the queued errors, one per call, before it succeeds
+type flakyRepo struct {
+	MessageRepository
+	errs  []error_utils.MessageErr
+	calls int
+}
+
+func (f *flakyRepo) next() error_utils.MessageErr {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &Message{Id: messageId}, nil
+}
+
+func (f *flakyRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (f *flakyRepo) Stream(ctx context.Context, fn func(Message) error) error_utils.MessageErr {
+	if f.calls == 0 {
+		fn(Message{Id: 1})
+	}
+	return f.next()
+}
+
+var (
+	unavailable = error_utils.WithCode(error_utils.NewServiceUnavailableError("the database is unavailable"), error_utils.CodeUnavailable)
+	deadlock    = error_utils.WithCode(error_utils.NewServiceUnavailableError("deadlock"), error_utils.CodeDeadlock)
+	testPolicy  = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+)
+
+func TestRetryingRepo_Get(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error_utils.MessageErr
+		wantCalls int
+		wantErr   int
+	}{
+		{name: "OK", wantCalls: 1},
+		{name: "Recovers", errs: []error_utils.MessageErr{unavailable, deadlock}, wantCalls: 3},
+		{name: "Gives Up", errs: []error_utils.MessageErr{unavailable, unavailable, unavailable, unavailable}, wantCalls: 3, wantErr: http.StatusServiceUnavailable},
+		{name: "Not Retryable", errs: []error_utils.MessageErr{error_utils.NewNotFoundError("no record matching given id")}, wantCalls: 1, wantErr: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyRepo{errs: tt.errs}
+			msg, err := NewRetryingRepository(flaky, testPolicy).Get(context.Background(), 1)
+			assert.EqualValues(t, tt.wantCalls, flaky.calls)
+			if tt.wantErr != 0 {
+				assert.Nil(t, msg)
+				assert.EqualValues(t, tt.wantErr, err.Status())
+				return
+			}
+			assert.Nil(t, err)
+			assert.EqualValues(t, 1, msg.Id)
+		})
+	}
+}
+
+func TestRetryingRepo_Create_Only_Retries_Rolled_Back_Statements(t *testing.T) {
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{deadlock}}
+	_, err := NewRetryingRepository(flaky, testPolicy).Create(context.Background(), &Message{Title: "title"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, flaky.calls)
+
+	//the connection was lost, the message may have been saved
+	flaky = &flakyRepo{errs: []error_utils.MessageErr{unavailable}}
+	_, err = NewRetryingRepository(flaky, testPolicy).Create(context.Background(), &Message{Title: "title"})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, flaky.calls)
+}
+
+func TestRetryingRepo_Stream_Not_Retried_Once_Started(t *testing.T) {
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{unavailable}}
+	got := 0
+	err := NewRetryingRepository(flaky, testPolicy).Stream(context.Background(), func(Message) error {
+		got++
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, flaky.calls)
+	assert.EqualValues(t, 1, got)
+}
+
+func TestRetryingRepo_Stays_Within_The_Deadline(t *testing.T) {
+	flaky := &flakyRepo{errs: []error_utils.MessageErr{unavailable, unavailable}}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewRetryingRepository(flaky, policy).Get(ctx, 1)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, flaky.calls)
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for i := 0; i < 20; i++ {
+		d := p.delay(2)
+		assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, d.String())
+		d = p.delay(10)
+		assert.True(t, d >= 150*time.Millisecond && d <= 300*time.Millisecond, d.String())
+	}
+}
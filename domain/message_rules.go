@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//The sizes of the title and body columns. Schema and the default rules are both built from these,
+//so the validation can never let through a value the database would refuse.
+const (
+	TitleMaxLength = 100
+	BodyMaxLength  = 200
+)
+
+//The connection and the columns use the utf8 character set of MySQL, which holds at most 3 bytes per character:
+//the characters beyond this one, such as most emoji, cannot be stored
+const maxColumnRune = '\uFFFF'
+
+//Checker is a hook run on the title and body of every valid message, e.g. a profanity filter.
+//It returns nil when the value is acceptable.
+type Checker func(field string, value string) *error_utils.FieldError
+
+//Rules are the constraints a message must meet before it is saved
+type Rules struct {
+	//Maximum lengths, counted in characters. They cannot exceed the sizes of the columns.
+	TitleMaxLength int
+	BodyMaxLength  int
+	//Control characters are refused, except line breaks and tabs in the body
+	AllowControlCharacters bool
+	//The title and body are stored in Unicode normalization form C, so that equal looking titles are equal
+	Normalize bool
+	Checkers  []Checker
+}
+
+//DefaultRules allows titles and bodies as long as their columns, without control characters, normalized
+func DefaultRules() Rules {
+	return Rules{
+		TitleMaxLength: TitleMaxLength,
+		BodyMaxLength:  BodyMaxLength,
+		Normalize:      true,
+	}
+}
+
+//Validate returns an error unless the maximum lengths are between 1 and the sizes of the columns
+func (r Rules) Validate() error {
+	if r.TitleMaxLength <= 0 || r.TitleMaxLength > TitleMaxLength {
+		return fmt.Errorf("the title max length should be between 1 and %d", TitleMaxLength)
+	}
+	if r.BodyMaxLength <= 0 || r.BodyMaxLength > BodyMaxLength {
+		return fmt.Errorf("the body max length should be between 1 and %d", BodyMaxLength)
+	}
+	return nil
+}
+
+//Blocklist returns a checker refusing values containing any of the words, whatever their case
+func Blocklist(words ...string) Checker {
+	lowered := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			lowered = append(lowered, w)
+		}
+	}
+	return func(field string, value string) *error_utils.FieldError {
+		value = strings.ToLower(value)
+		for _, w := range lowered {
+			if strings.Contains(value, w) {
+				return &error_utils.FieldError{Field: field, Code: fieldCodes[field].blocked, Message: fmt.Sprintf("The %s contains a word that is not allowed", field)}
+			}
+		}
+		return nil
+	}
+}
+
+//The codes reported for each field of a message
+var fieldCodes = map[string]struct{ required, tooLong, invalid, blocked string }{
+	"title": {error_utils.CodeTitleRequired, error_utils.CodeTitleTooLong, error_utils.CodeTitleInvalidCharacter, error_utils.CodeTitleBlocked},
+	"body":  {error_utils.CodeBodyRequired, error_utils.CodeBodyTooLong, error_utils.CodeBodyInvalidCharacter, error_utils.CodeBodyBlocked},
+}
+
+//check returns the first problem with the value of the field, the checkers only run on otherwise valid values
+func (r Rules) check(field string, value string, max int, multiline bool) *error_utils.FieldError {
+	codes := fieldCodes[field]
+	switch {
+	case value == "":
+		return &error_utils.FieldError{Field: field, Code: codes.required, Message: fmt.Sprintf("Please enter a valid %s", field)}
+	case utf8.RuneCountInString(value) > max:
+		return &error_utils.FieldError{Field: field, Code: codes.tooLong, Message: fmt.Sprintf("The %s should be at most %d characters", field, max)}
+	//bytes that are not utf-8 cannot be stored in the utf8 columns, nor sent back in json, and neither can 4 byte characters be stored
+	case !utf8.ValidString(value), hasUnstorableCharacter(value), !r.AllowControlCharacters && hasControlCharacter(value, multiline):
+		return &error_utils.FieldError{Field: field, Code: codes.invalid, Message: fmt.Sprintf("The %s contains characters that are not allowed", field)}
+	}
+	for _, checker := range r.Checkers {
+		if f := checker(field, value); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+func (r Rules) normalize(value string) string {
+	value = strings.TrimSpace(value)
+	if r.Normalize {
+		value = norm.NFC.String(value)
+	}
+	return value
+}
+
+//hasUnstorableCharacter reports whether value holds a character the columns cannot store, see maxColumnRune
+func hasUnstorableCharacter(value string) bool {
+	for _, c := range value {
+		if c > maxColumnRune {
+			return true
+		}
+	}
+	return false
+}
+
+//hasControlCharacter reports whether value holds a control character, allowing line breaks and tabs when multiline is set
+func hasControlCharacter(value string, multiline bool) bool {
+	for _, c := range value {
+		if multiline && (c == '\n' || c == '\r' || c == '\t') {
+			continue
+		}
+		if unicode.IsControl(c) {
+			return true
+		}
+	}
+	return false
+}
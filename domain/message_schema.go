@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const schemaTemplate = "CREATE TABLE `messages` (\n" +
+	"  `id` INT NOT NULL AUTO_INCREMENT,\n" +
+	"  `tenant_id` VARCHAR(%d) NOT NULL DEFAULT '%s',\n" +
+	"  `parent_id` INT NULL,\n" +
+	"  `title` VARCHAR(%d) NULL,\n" +
+	"  `body` VARCHAR(%d) NULL,\n" +
+	"  `created_at` TIMESTAMP NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE INDEX `title_UNIQUE` (`tenant_id` ASC, `title` ASC),\n" +
+	"  INDEX `parent_id_INDEX` (`parent_id` ASC));\n"
+
+//Schema returns the statement creating the messages table, with columns sized from TenantMaxLength, TitleMaxLength and BodyMaxLength.
+//Titles are unique within a tenant, and the rows written before tenants existed belong to DefaultTenant.
+//parent_id has no foreign key: the repository deletes the replies of a message itself, as MySQL cascades
+//no deeper than 15 levels.
+//message_schema.sql holds the same statement for the migrations, and a test keeps the two in step.
+func Schema() string {
+	return fmt.Sprintf(schemaTemplate, TenantMaxLength, DefaultTenant, TitleMaxLength, BodyMaxLength)
+}
+
+//TenantMigration are the statements adding tenants to a messages table created before them.
+//The existing messages go to DefaultTenant. migrations/0001_tenants.sql holds the same statements, a test keeps the two in step.
+var TenantMigration = []string{
+	fmt.Sprintf("ALTER TABLE `messages` ADD COLUMN `tenant_id` VARCHAR(%d) NOT NULL DEFAULT '%s' AFTER `id`;", TenantMaxLength, DefaultTenant),
+	"ALTER TABLE `messages` DROP INDEX `title_UNIQUE`, ADD UNIQUE INDEX `title_UNIQUE` (`tenant_id` ASC, `title` ASC);",
+}
+
+//ThreadMigration is the statement adding replies to a messages table created before them, to run after TenantMigration.
+//migrations/0002_threads.sql holds the same statement, a test keeps the two in step.
+var ThreadMigration = []string{
+	"ALTER TABLE `messages` ADD COLUMN `parent_id` INT NULL AFTER `tenant_id`, ADD INDEX `parent_id_INDEX` (`parent_id` ASC);",
+}
+
+const tagSchemaTemplate = "CREATE TABLE `tags` (\n" +
+	"  `id` INT NOT NULL AUTO_INCREMENT,\n" +
+	"  `tenant_id` VARCHAR(%d) NOT NULL DEFAULT '%s',\n" +
+	"  `name` VARCHAR(%d) NOT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE INDEX `name_UNIQUE` (`tenant_id` ASC, `name` ASC));\n" +
+	"CREATE TABLE `message_tags` (\n" +
+	"  `message_id` INT NOT NULL,\n" +
+	"  `tag_id` INT NOT NULL,\n" +
+	"  PRIMARY KEY (`message_id`, `tag_id`),\n" +
+	"  INDEX `tag_id_INDEX` (`tag_id` ASC),\n" +
+	"  FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE,\n" +
+	"  FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`) ON DELETE CASCADE);\n"
+
+//TagSchema returns the statements creating the tables of the tags, to run after Schema. A tag belongs to a tenant,
+//and message_tags links it to the messages carrying it; deleting a message or a tag deletes its links.
+//A tag no message carries any more is left in the table until a rename or a merge clears it, it is not listed meanwhile.
+//tag_schema.sql holds the same statements for the migrations, and a test keeps the two in step.
+func TagSchema() []string {
+	statements := strings.SplitAfter(fmt.Sprintf(tagSchemaTemplate, TenantMaxLength, DefaultTenant, TagMaxLength), ";\n")
+	return statements[:len(statements)-1]
+}
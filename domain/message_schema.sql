@@ -1,7 +1,10 @@
   CREATE TABLE `messages` (
   `id` INT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+  `parent_id` INT NULL,
   `title` VARCHAR(100) NULL,
   `body` VARCHAR(200) NULL,
   `created_at` TIMESTAMP NULL,
   PRIMARY KEY (`id`),
-  UNIQUE INDEX `title_UNIQUE` (`title` ASC));
\ No newline at end of file
+  UNIQUE INDEX `title_UNIQUE` (`tenant_id` ASC, `title` ASC),
+  INDEX `parent_id_INDEX` (`parent_id` ASC));
\ No newline at end of file
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//TagMaxLength is the size of the name column of the tags table, and MaxTags the number of tags a message can carry
+const (
+	TagMaxLength = 32
+	MaxTags      = 10
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+//ValidTag reports whether the tag can be used: 1 to TagMaxLength lowercase letters, digits, dashes or underscores,
+//starting with a letter or a digit. Tags are compared once normalized with NormalizeTag.
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+//NormalizeTag trims and lowercases the tag, so that "Go" and " go" are the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+//NormalizeTags normalizes the tags, drops the duplicates and sorts them. nil stays nil.
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+//FilterTags normalizes the tags a list is filtered by. It refuses more than MaxTags of them and any invalid tag,
+//which could not match a message and would break the FIND_IN_SET match of the tagged queries.
+func FilterTags(tags []string) ([]string, error_utils.MessageErr) {
+	tags = NormalizeTags(tags)
+	if len(tags) > MaxTags {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("at most %d tags can be given", MaxTags))
+	}
+	for _, tag := range tags {
+		if !ValidTag(tag) {
+			return nil, error_utils.NewBadRequestError(fmt.Sprintf("tag %q is not a valid tag", tag))
+		}
+	}
+	return tags, nil
+}
+
+//How ListOptions.Tags are matched against the tags of a message
+const (
+	//MatchAny keeps the messages carrying at least one of the tags, it is the default
+	MatchAny = "any"
+	//MatchAll keeps the messages carrying every one of the tags
+	MatchAll = "all"
+)
+
+//TagCount is a tag with the number of messages carrying it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+//hasTags reports whether the tags of a message match the tags of opts, it is true when opts has no tags
+func (o ListOptions) hasTags(tags []string) bool {
+	if len(o.Tags) == 0 {
+		return true
+	}
+	matched := 0
+	for _, want := range o.Tags {
+		for _, tag := range tags {
+			if tag == want {
+				matched++
+				break
+			}
+		}
+	}
+	if o.Match == MatchAll {
+		return matched == len(o.Tags)
+	}
+	return matched > 0
+}
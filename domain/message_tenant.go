@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"regexp"
+)
+
+//DefaultTenant owns the messages of a context without a tenant, such as those of a deployment with a single customer
+const DefaultTenant = "default"
+
+//TenantMaxLength is the size of the tenant_id column
+const TenantMaxLength = 64
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+//ValidTenant reports whether the tenant id can be used: 1 to TenantMaxLength letters, digits, dashes or underscores
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+type tenantContextKey struct{}
+
+//WithTenant scopes every repository call made with the context to the messages of the tenant.
+//A tenant cannot read, change or delete the messages of another, and titles only need to be unique within a tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+//TenantFrom returns the tenant the context is scoped to, or DefaultTenant
+func TenantFrom(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantContextKey{}).(string); tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+//Quotas caps the number of messages each tenant can hold. Default applies to the tenants not listed in Tenants.
+//A quota of 0 is no cap.
+type Quotas struct {
+	Default int
+	Tenants map[string]int
+}
+
+//Limit returns the number of messages the tenant can hold, 0 when there is no cap
+func (q Quotas) Limit(tenant string) int {
+	if limit, ok := q.Tenants[tenant]; ok {
+		return limit
+	}
+	return q.Default
+}
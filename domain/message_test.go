@@ -1,10 +1,13 @@
 package domain
 
 import (
+	"context"
+	"efficient-api/utils/error_utils"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -21,7 +24,7 @@ func TestMessageRepo_Get(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		s       messageRepoInterface
+		s       MessageRepository
 		msgId   int64
 		mock    func()
 		want    *Message
@@ -34,8 +37,8 @@ func TestMessageRepo_Get(t *testing.T) {
 			msgId: 1,
 			mock: func() {
 				//We added one row
-				rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}).AddRow(1, "title", "body", created_at)
-				mock.ExpectPrepare("SELECT (.+) FROM messages").ExpectQuery().WithArgs(1).WillReturnRows(rows)
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+				mock.ExpectPrepare("SELECT (.+) FROM messages").ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
 			},
 			want: &Message{
 				Id:        1,
@@ -50,8 +53,8 @@ func TestMessageRepo_Get(t *testing.T) {
 			s:     s,
 			msgId: 1,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}) //observe that we didnt add any role here
-				mock.ExpectPrepare("SELECT (.+) FROM messages").ExpectQuery().WithArgs(1).WillReturnRows(rows)
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}) //observe that we didnt add any role here
+				mock.ExpectPrepare("SELECT (.+) FROM messages").ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
 			},
 			wantErr: true,
 		},
@@ -61,16 +64,18 @@ func TestMessageRepo_Get(t *testing.T) {
 			s:     s,
 			msgId: 1,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}).AddRow(1, "title", "body", created_at)
-				mock.ExpectPrepare("SELECT (.+) FROM wrong_table").ExpectQuery().WithArgs(1).WillReturnRows(rows)
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+				mock.ExpectPrepare("SELECT (.+) FROM wrong_table").ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
 			tt.mock()
-			got, err := tt.s.Get(tt.msgId)
+			got, err := tt.s.Get(context.Background(), tt.msgId)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Get() error new = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -82,6 +87,106 @@ func TestMessageRepo_Get(t *testing.T) {
 	}
 }
 
+func TestMessageRepo_Reuses_Statements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	//a statement that could not be prepared is prepared again on the next call
+	mock.ExpectPrepare("SELECT (.+) FROM messages").WillReturnError(errors.New("database is down"))
+	if _, err := s.Get(context.Background(), 1); err == nil {
+		t.Errorf("Get() error = nil, want the prepare error")
+	}
+	//after that, it is prepared once for all the calls
+	prepared := mock.ExpectPrepare("SELECT (.+) FROM messages")
+	for i := 0; i < 3; i++ {
+		rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+		prepared.ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.Get(context.Background(), 1); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageRepo_Prepares_Outside_The_Lock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+	mr := &messageRepo{}
+
+	mock.ExpectPrepare("SELECT COUNT")
+	if _, err := mr.stmt(context.Background(), db, queryCountMessages); err != nil {
+		t.Fatalf("stmt() error = %v", err)
+	}
+	//a slow prepare is made once for all the callers waiting for it, and does not hold up the statements already prepared
+	mock.ExpectPrepare("SELECT (.+) FROM messages").WillDelayFor(200 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := mr.stmt(context.Background(), db, queryGetMessage); err != nil {
+				t.Errorf("stmt() error = %v", err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	if _, err := mr.stmt(context.Background(), db, queryCountMessages); err != nil {
+		t.Errorf("stmt() error = %v", err)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("stmt() waited %v for another prepare", waited)
+	}
+	wg.Wait()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+//The caller that started a prepare giving up neither fails it for the other callers nor keeps it from being reused
+func TestMessageRepo_Prepare_Outlives_Its_Caller(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mr := &messageRepo{}
+
+	mock.ExpectPrepare("SELECT (.+) FROM messages").WillDelayFor(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := mr.stmt(context.Background(), db, queryGetMessage)
+		done <- err
+	}()
+	if _, err := mr.stmt(ctx, db, queryGetMessage); err != context.DeadlineExceeded {
+		t.Errorf("stmt() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("stmt() error = %v for the caller still waiting", err)
+	}
+	//the statement is kept, so it is not prepared again
+	if _, err := mr.stmt(context.Background(), db, queryGetMessage); err != nil {
+		t.Errorf("stmt() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestMessageRepo_Create(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -93,7 +198,7 @@ func TestMessageRepo_Create(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		s       messageRepoInterface
+		s       MessageRepository
 		request *Message
 		mock    func()
 		want    *Message
@@ -108,7 +213,7 @@ func TestMessageRepo_Create(t *testing.T) {
 				CreatedAt: tm,
 			},
 			mock: func() {
-				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs("title", "body", tm).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs(DefaultTenant, nil, "title", "body", tm).WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			want: &Message{
 				Id:        1,
@@ -119,49 +224,51 @@ func TestMessageRepo_Create(t *testing.T) {
 		},
 		{
 			name: "Empty title",
-			s: s,
+			s:    s,
 			request: &Message{
 				Title:     "",
 				Body:      "body",
 				CreatedAt: tm,
 			},
-			mock: func(){
-				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs("title", "body", tm).WillReturnError(errors.New("empty title"))
+			mock: func() {
+				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs(DefaultTenant, nil, "title", "body", tm).WillReturnError(errors.New("empty title"))
 			},
 			wantErr: true,
 		},
 		{
 			name: "Empty body",
-			s: s,
+			s:    s,
 			request: &Message{
 				Title:     "title",
 				Body:      "",
 				CreatedAt: tm,
 			},
-			mock: func(){
-				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs("title", "body", tm).WillReturnError(errors.New("empty body"))
+			mock: func() {
+				mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs(DefaultTenant, nil, "title", "body", tm).WillReturnError(errors.New("empty body"))
 			},
 			wantErr: true,
 		},
 		{
 			name: "Invalid SQL query",
-			s: s,
+			s:    s,
 			request: &Message{
 				Title:     "title",
 				Body:      "body",
 				CreatedAt: tm,
 			},
-			mock: func(){
+			mock: func() {
 				//Instead of using "INSERT", we used "INSETER"
-				mock.ExpectPrepare("INSERT INTO wrong_table").ExpectExec().WithArgs("title", "body", tm).WillReturnError( errors.New("invalid sql query"))
+				mock.ExpectPrepare("INSERT INTO wrong_table").ExpectExec().WithArgs(DefaultTenant, nil, "title", "body", tm).WillReturnError(errors.New("invalid sql query"))
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
 			tt.mock()
-			got, err := tt.s.Create(tt.request)
+			got, err := tt.s.Create(context.Background(), tt.request)
 			if (err != nil) != tt.wantErr {
 				fmt.Println("this is the error message: ", err.Message())
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
@@ -184,7 +291,7 @@ func TestMessageRepo_Update(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		s       messageRepoInterface
+		s       MessageRepository
 		request *Message
 		mock    func()
 		want    *Message
@@ -194,29 +301,29 @@ func TestMessageRepo_Update(t *testing.T) {
 			name: "OK",
 			s:    s,
 			request: &Message{
-				Id: 1,
-				Title:     "update title",
-				Body:      "update body",
+				Id:    1,
+				Title: "update title",
+				Body:  "update body",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			want: &Message{
-				Id:        1,
-				Title:     "update title",
-				Body:      "update body",
+				Id:    1,
+				Title: "update title",
+				Body:  "update body",
 			},
 		},
 		{
 			name: "Invalid SQL Query",
 			s:    s,
 			request: &Message{
-				Id: 1,
-				Title:     "update title",
-				Body:      "update body",
+				Id:    1,
+				Title: "update title",
+				Body:  "update body",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATER messages").ExpectExec().WithArgs("update title", "update body", 1).WillReturnError(errors.New("error in sql query statement"))
+				mock.ExpectPrepare("UPDATER messages").ExpectExec().WithArgs("update title", "update body", 1, DefaultTenant).WillReturnError(errors.New("error in sql query statement"))
 			},
 			wantErr: true,
 		},
@@ -224,12 +331,12 @@ func TestMessageRepo_Update(t *testing.T) {
 			name: "Invalid Query Id",
 			s:    s,
 			request: &Message{
-				Id: 0,
-				Title:     "update title",
-				Body:      "update body",
+				Id:    0,
+				Title: "update title",
+				Body:  "update body",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 0).WillReturnError(errors.New("invalid update id"))
+				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 0, DefaultTenant).WillReturnError(errors.New("invalid update id"))
 			},
 			wantErr: true,
 		},
@@ -237,12 +344,12 @@ func TestMessageRepo_Update(t *testing.T) {
 			name: "Empty Title",
 			s:    s,
 			request: &Message{
-				Id: 1,
-				Title:     "",
-				Body:      "update body",
+				Id:    1,
+				Title: "",
+				Body:  "update body",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("", "update body", 1).WillReturnError(errors.New("Please enter a valid title"))
+				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("", "update body", 1, DefaultTenant).WillReturnError(errors.New("Please enter a valid title"))
 			},
 			wantErr: true,
 		},
@@ -250,12 +357,12 @@ func TestMessageRepo_Update(t *testing.T) {
 			name: "Empty Body",
 			s:    s,
 			request: &Message{
-				Id: 1,
-				Title:     "update title",
-				Body:      "",
+				Id:    1,
+				Title: "update title",
+				Body:  "",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "", 1).WillReturnError(errors.New("Please enter a valid body"))
+				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "", 1, DefaultTenant).WillReturnError(errors.New("Please enter a valid body"))
 			},
 			wantErr: true,
 		},
@@ -263,20 +370,22 @@ func TestMessageRepo_Update(t *testing.T) {
 			name: "Update failed",
 			s:    s,
 			request: &Message{
-				Id: 1,
-				Title:     "update title",
-				Body:      "update body",
+				Id:    1,
+				Title: "update title",
+				Body:  "update body",
 			},
 			mock: func() {
-				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 1).WillReturnResult(sqlmock.NewErrorResult(errors.New("Update failed")))
+				mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("update title", "update body", 1, DefaultTenant).WillReturnResult(sqlmock.NewErrorResult(errors.New("Update failed")))
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
 			tt.mock()
-			got, err := tt.s.Update(tt.request)
+			got, err := tt.s.Update(context.Background(), tt.request)
 			if (err != nil) != tt.wantErr {
 				fmt.Println("this is the error message: ", err.Message())
 				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
@@ -289,6 +398,38 @@ func TestMessageRepo_Update(t *testing.T) {
 	}
 }
 
+func TestMessageRepo_GetMany(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "first title", "first body", created_at, 0, nil).AddRow(3, nil, "third title", "third body", created_at, 0, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM messages WHERE tenant_id=\? AND id IN \(\?,\?,\?\) ORDER BY id`).WithArgs(DefaultTenant, 3, 1, 2).WillReturnRows(rows)
+	got, getErr := s.GetMany(context.Background(), []int64{3, 1, 2})
+	if getErr != nil {
+		t.Errorf("GetMany() error = %v", getErr)
+	}
+	want := []Message{
+		{Id: 1, Title: "first title", Body: "first body", CreatedAt: created_at},
+		{Id: 3, Title: "third title", Body: "third body", CreatedAt: created_at},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMany() = %v, want %v", got, want)
+	}
+
+	//no ids, no query
+	got, getErr = s.GetMany(context.Background(), nil)
+	if getErr != nil || len(got) != 0 {
+		t.Errorf("GetMany() = %v, %v, want no messages", got, getErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestMessageRepo_GetAll(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -299,7 +440,7 @@ func TestMessageRepo_GetAll(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		s       messageRepoInterface
+		s       MessageRepository
 		msgId   int64
 		mock    func()
 		want    []Message
@@ -307,11 +448,11 @@ func TestMessageRepo_GetAll(t *testing.T) {
 	}{
 		{
 			//When everything works as expected
-			name:  "OK",
-			s:     s,
+			name: "OK",
+			s:    s,
 			mock: func() {
 				//We added two rows
-				rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}).AddRow(1, "first title", "first body", created_at).AddRow(2, "second title", "second body", created_at)
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "first title", "first body", created_at, 0, nil).AddRow(2, nil, "second title", "second body", created_at, 0, nil)
 				mock.ExpectPrepare("SELECT (.+) FROM messages").ExpectQuery().WillReturnRows(rows)
 			},
 			want: []Message{
@@ -330,11 +471,11 @@ func TestMessageRepo_GetAll(t *testing.T) {
 			},
 		},
 		{
-			name:  "Invalid SQL Syntax",
-			s:     s,
+			name: "Invalid SQL Syntax",
+			s:    s,
 			mock: func() {
 				//We added two rows
-				_ = sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}).AddRow(1, "first title", "first body", created_at).AddRow(2, "second title", "second body", created_at)
+				_ = sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "first title", "first body", created_at, 0, nil).AddRow(2, nil, "second title", "second body", created_at, 0, nil)
 				//"SELECTS" is used instead of "SELECT"
 				mock.ExpectPrepare("SELECTS (.+) FROM messages").ExpectQuery().WillReturnError(errors.New("Error when trying to prepare all messages"))
 			},
@@ -343,8 +484,10 @@ func TestMessageRepo_GetAll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
 			tt.mock()
-			got, err := tt.s.GetAll()
+			got, err := tt.s.GetAll(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetAll() error new = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -356,6 +499,521 @@ func TestMessageRepo_GetAll(t *testing.T) {
 	}
 }
 
+func TestMessageRepo_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	tests := []struct {
+		name    string
+		s       MessageRepository
+		opts    ListOptions
+		mock    func()
+		want    []Message
+		wantErr bool
+	}{
+		{
+			name: "OK",
+			s:    s,
+			opts: ListOptions{Limit: 2, AfterId: 1, Title: "title"},
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(2, nil, "second title", "second body", created_at, 0, nil)
+				mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id > (.+) ORDER BY id LIMIT").ExpectQuery().WithArgs(DefaultTenant, 1, "%title%", 2).WillReturnRows(rows)
+			},
+			want: []Message{
+				{
+					Id:        2,
+					Title:     "second title",
+					Body:      "second body",
+					CreatedAt: created_at,
+				},
+			},
+		},
+		{
+			//An empty page is not an error, and the default limit is used when none is given
+			name: "Empty Page",
+			s:    s,
+			opts: ListOptions{AfterId: 10},
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"})
+				mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id > (.+) ORDER BY id LIMIT").ExpectQuery().WithArgs(DefaultTenant, 10, "%%", DefaultListLimit).WillReturnRows(rows)
+			},
+			want: []Message{},
+		},
+		{
+			//The LIKE wildcards in the filter are matched literally
+			name: "Escaped Filter",
+			s:    s,
+			opts: ListOptions{Limit: 1, Title: "100%_done"},
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"})
+				mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id > (.+) ORDER BY id LIMIT").ExpectQuery().WithArgs(DefaultTenant, 0, `%100\%\_done%`, 1).WillReturnRows(rows)
+			},
+			want: []Message{},
+		},
+		{
+			name: "Invalid SQL Syntax",
+			s:    s,
+			opts: ListOptions{Limit: 2},
+			mock: func() {
+				mock.ExpectPrepare("SELECTS (.+) FROM messages").ExpectQuery().WillReturnError(errors.New("Error when trying to prepare list messages"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
+			tt.mock()
+			got, err := tt.s.List(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("List() error new = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageRepo_GetByTitle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, nil)
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND title").ExpectQuery().WithArgs(DefaultTenant, "title").WillReturnRows(rows)
+	got, getErr := s.GetByTitle(context.Background(), "title")
+	if getErr != nil {
+		t.Fatalf("GetByTitle() error = %v", getErr)
+	}
+	if !reflect.DeepEqual(got, &Message{Id: 1, Title: "title", Body: "body", CreatedAt: created_at}) {
+		t.Errorf("GetByTitle() = %v", got)
+	}
+
+	//When no message has the title
+	//the statement is reused, it is not prepared again
+	mock.ExpectQuery("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND title").WithArgs(DefaultTenant, "other").WillReturnRows(sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}))
+	if _, getErr := s.GetByTitle(context.Background(), "other"); getErr == nil || getErr.Status() != 404 {
+		t.Errorf("GetByTitle() error = %v, want a not found error", getErr)
+	}
+}
+
+func TestMessageRepo_Stream(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "first title", "first body", created_at, 0, nil).AddRow(2, nil, "second title", "second body", created_at, 0, nil)
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) ORDER BY id").ExpectQuery().WillReturnRows(rows)
+	got := make([]int64, 0)
+	streamErr := s.Stream(context.Background(), func(msg Message) error {
+		got = append(got, msg.Id)
+		return nil
+	})
+	if streamErr != nil || !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("Stream() = %v, error = %v", got, streamErr)
+	}
+
+	//When the callback fails, streaming stops
+	rows = sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "first title", "first body", created_at, 0, nil).AddRow(2, nil, "second title", "second body", created_at, 0, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages WHERE tenant_id=(.+) ORDER BY id").WillReturnRows(rows)
+	calls := 0
+	streamErr = s.Stream(context.Background(), func(msg Message) error {
+		calls++
+		return errors.New("client went away")
+	})
+	if streamErr == nil || calls != 1 {
+		t.Errorf("Stream() calls = %d, error = %v", calls, streamErr)
+	}
+}
+
+func TestMessageRepo_Count(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectPrepare("SELECT COUNT(.+) FROM messages WHERE tenant_id").ExpectQuery().WithArgs(DefaultTenant).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	count, countErr := s.Count(context.Background())
+	if countErr != nil || count != 3 {
+		t.Errorf("Count() = %d, error = %v, want 3", count, countErr)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM messages WHERE tenant_id").WithArgs(DefaultTenant).WillReturnError(errors.New("database is down"))
+	if _, countErr := s.Count(context.Background()); countErr == nil || countErr.Status() != 500 {
+		t.Errorf("Count() error = %v, want a server error", countErr)
+	}
+}
+
+func TestMessageRepo_Replies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(2, 1, "second title", "second body", created_at, 1, nil)
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND parent_id").ExpectQuery().WithArgs(DefaultTenant, 1, 0, DefaultListLimit).WillReturnRows(rows)
+	replies, repliesErr := s.Replies(context.Background(), 1, ListOptions{})
+	want := []Message{{Id: 2, ParentId: 1, Title: "second title", Body: "second body", CreatedAt: created_at, ReplyCount: 1}}
+	if repliesErr != nil || !reflect.DeepEqual(replies, want) {
+		t.Errorf("Replies() = %v, error = %v, want %v", replies, repliesErr, want)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND parent_id").WithArgs(DefaultTenant, 1, 2, 10).WillReturnError(errors.New("database is down"))
+	if _, repliesErr := s.Replies(context.Background(), 1, ListOptions{AfterId: 2, Limit: 10}); repliesErr == nil || repliesErr.Status() != 500 {
+		t.Errorf("Replies() error = %v, want a server error", repliesErr)
+	}
+}
+
+func TestMessageRepo_Thread(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).
+		AddRow(1, nil, "first title", "first body", created_at, 1, nil).
+		AddRow(2, 1, "second title", "second body", created_at, 0, nil)
+	mock.ExpectPrepare("WITH RECURSIVE thread (.+) SELECT (.+) FROM messages JOIN thread").ExpectQuery().WithArgs(1, DefaultTenant, DefaultTenant, 5).WillReturnRows(rows)
+	msgs, threadErr := s.Thread(context.Background(), 1, 5)
+	if threadErr != nil || len(msgs) != 2 || msgs[0].ParentId != 0 || msgs[1].ParentId != 1 {
+		t.Errorf("Thread() = %v, error = %v, want the message and its reply", msgs, threadErr)
+	}
+
+	//the message itself is always part of its thread, so no rows means there is no such message
+	mock.ExpectQuery("WITH RECURSIVE thread (.+) SELECT (.+) FROM messages JOIN thread").WithArgs(100, DefaultTenant, DefaultTenant, 5).WillReturnRows(sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}))
+	if _, threadErr := s.Thread(context.Background(), 100, 5); threadErr == nil || threadErr.Status() != 404 {
+		t.Errorf("Thread() error = %v, want a not found error", threadErr)
+	}
+}
+
+func TestMessageRepo_Create_Reply(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs(DefaultTenant, 1, "title", "body", created_at).WillReturnResult(sqlmock.NewResult(2, 1))
+	msg, createErr := s.Create(context.Background(), &Message{ParentId: 1, Title: "title", Body: "body", CreatedAt: created_at, ReplyCount: 5})
+	if createErr != nil || msg.Id != 2 || msg.ParentId != 1 || msg.ReplyCount != 0 {
+		t.Errorf("Create() = %v, error = %v, want the reply with id 2 and no replies", msg, createErr)
+	}
+}
+
+func TestMessageRepo_Detach(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectPrepare("UPDATE messages SET parent_id=NULL").ExpectExec().WithArgs(1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 2))
+	if detachErr := s.Detach(context.Background(), 1); detachErr != nil {
+		t.Errorf("Detach() error = %v", detachErr)
+	}
+
+	mock.ExpectExec("UPDATE messages SET parent_id=NULL").WithArgs(1, DefaultTenant).WillReturnError(errors.New("database is down"))
+	if detachErr := s.Detach(context.Background(), 1); detachErr == nil || detachErr.Status() != 500 {
+		t.Errorf("Detach() error = %v, want a server error", detachErr)
+	}
+}
+
+func TestMessageRepo_DeleteLeaf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FOR UPDATE").WithArgs(1, DefaultTenant).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("DELETE FROM messages WHERE id=\\? AND tenant_id=\\?").WithArgs(1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if deleteErr := s.DeleteLeaf(context.Background(), 1); deleteErr != nil {
+		t.Errorf("DeleteLeaf() error = %v", deleteErr)
+	}
+
+	//the replies are counted and locked in the transaction of the delete, so none can be added before it
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FOR UPDATE").WithArgs(1, DefaultTenant).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+	deleteErr := s.DeleteLeaf(context.Background(), 1)
+	if deleteErr == nil || deleteErr.Code() != error_utils.CodeHasReplies || !reflect.DeepEqual(deleteErr.Details(), map[string]interface{}{"replies": int64(2)}) {
+		t.Errorf("DeleteLeaf() error = %v, want a has_replies conflict", deleteErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageRepo_Get_Tags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, "go,news")
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE id").ExpectQuery().WithArgs(1, DefaultTenant).WillReturnRows(rows)
+	msg, getErr := s.Get(context.Background(), 1)
+	if getErr != nil || !reflect.DeepEqual(msg.Tags, []string{"go", "news"}) {
+		t.Errorf("Get() = %v, error = %v, want the tags go and news", msg, getErr)
+	}
+}
+
+func TestMessageRepo_List_Tags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	//any tag is enough by default, all of them need as many matching tags as there are
+	rows := sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}).AddRow(1, nil, "title", "body", created_at, 0, "go")
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id IN (.+) FIND_IN_SET").ExpectQuery().WithArgs(DefaultTenant, 0, "%%", DefaultTenant, "go,news", 1, DefaultListLimit).WillReturnRows(rows)
+	msgs, listErr := s.List(context.Background(), ListOptions{Tags: []string{"go", "news"}})
+	if listErr != nil || len(msgs) != 1 {
+		t.Errorf("List() = %v, error = %v, want the message tagged go", msgs, listErr)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id IN (.+) FIND_IN_SET").WithArgs(DefaultTenant, 0, "%%", DefaultTenant, "go,news", 2, DefaultListLimit).WillReturnRows(sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}))
+	msgs, listErr = s.List(context.Background(), ListOptions{Tags: []string{"go", "news"}, Match: MatchAll})
+	if listErr != nil || len(msgs) != 0 {
+		t.Errorf("List() = %v, error = %v, want an empty page", msgs, listErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageRepo_Create_Tags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO messages").WithArgs(DefaultTenant, nil, "title", "body", created_at).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE message_tags").WithArgs(1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO tags").WithArgs(DefaultTenant, "go").WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("INSERT INTO message_tags").WithArgs(7, 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	msg, createErr := s.Create(context.Background(), &Message{Title: "title", Body: "body", CreatedAt: created_at, Tags: []string{"go"}})
+	if createErr != nil || msg.Id != 1 {
+		t.Errorf("Create() = %v, error = %v, want the message with id 1", msg, createErr)
+	}
+
+	//the message is not saved without its tags
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO messages").WithArgs(DefaultTenant, nil, "title", "body", created_at).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("DELETE message_tags").WithArgs(2, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO tags").WithArgs(DefaultTenant, "go").WillReturnError(errors.New("database is down"))
+	mock.ExpectRollback()
+	if _, createErr := s.Create(context.Background(), &Message{Title: "title", Body: "body", CreatedAt: created_at, Tags: []string{"go"}}); createErr == nil || createErr.Status() != 500 {
+		t.Errorf("Create() error = %v, want a server error", createErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageRepo_Update_Tags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	//empty tags clear them
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages").WithArgs("title", "body", 1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE message_tags").WithArgs(1, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	if _, updateErr := s.Update(context.Background(), &Message{Id: 1, Title: "title", Body: "body", Tags: []string{}}); updateErr != nil {
+		t.Errorf("Update() error = %v", updateErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageRepo_Tags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Name", "Count"}).AddRow("go", 2).AddRow("news", 1)
+	mock.ExpectPrepare("SELECT tags.name, COUNT(.+) FROM tags").ExpectQuery().WithArgs(DefaultTenant).WillReturnRows(rows)
+	tags, tagsErr := s.Tags(context.Background())
+	want := []TagCount{{Name: "go", Count: 2}, {Name: "news", Count: 1}}
+	if tagsErr != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags() = %v, error = %v, want %v", tags, tagsErr, want)
+	}
+}
+
+func TestMessageRepo_RenameTag(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	tests := []struct {
+		name   string
+		mock   func()
+		status int
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND NOT EXISTS").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "golang").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "go").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectExec("UPDATE tags SET name").WithArgs("go", DefaultTenant, "golang").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Not Found",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND NOT EXISTS").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "golang").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectRollback()
+			},
+			status: 404,
+		},
+		{
+			name: "Taken",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND NOT EXISTS").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "golang").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "go").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectRollback()
+			},
+			status: 409,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			renameErr := s.RenameTag(context.Background(), "golang", "go")
+			if tt.status == 0 && renameErr != nil {
+				t.Errorf("RenameTag() error = %v", renameErr)
+			}
+			if tt.status != 0 && (renameErr == nil || renameErr.Status() != tt.status) {
+				t.Errorf("RenameTag() error = %v, want a %d", renameErr, tt.status)
+			}
+			if tt.status == 409 && renameErr.Code() != error_utils.CodeTagTaken {
+				t.Errorf("RenameTag() code = %s, want %s", renameErr.Code(), error_utils.CodeTagTaken)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestMessageRepo_MergeTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND NOT EXISTS").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "go-lang,golang").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO tags").WithArgs(DefaultTenant, "go").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT IGNORE INTO message_tags").WithArgs(3, DefaultTenant, "go-lang,golang").WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND FIND_IN_SET").WithArgs(DefaultTenant, "go-lang,golang", "go").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	if mergeErr := s.MergeTags(context.Background(), []string{"go-lang", "golang"}, "go"); mergeErr != nil {
+		t.Errorf("MergeTags() error = %v", mergeErr)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM tags WHERE tenant_id=(.+) AND NOT EXISTS").WithArgs(DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM tags").WithArgs(DefaultTenant, "golang").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+	if mergeErr := s.MergeTags(context.Background(), []string{"golang"}, "go"); mergeErr == nil || mergeErr.Status() != 404 {
+		t.Errorf("MergeTags() error = %v, want a not found error", mergeErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+//Every statement is given the tenant of the context, so that it only sees the rows of that tenant
+func TestMessageRepo_Scoped_To_Tenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := NewMessageRepository(db)
+	ctx := WithTenant(context.Background(), "acme")
+
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE id").ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}))
+	mock.ExpectPrepare("INSERT INTO messages").ExpectExec().WithArgs("acme", nil, "title", "body", created_at).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("UPDATE messages").ExpectExec().WithArgs("title", "body", 1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(1, "acme", "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("SELECT (.+) FROM messages WHERE tenant_id=(.+) AND id >").ExpectQuery().WithArgs("acme", 0, "%%", DefaultListLimit).WillReturnRows(sqlmock.NewRows([]string{"Id", "ParentId", "Title", "Body", "CreatedAt", "ReplyCount", "Tags"}))
+
+	if _, getErr := s.Get(ctx, 1); getErr == nil || getErr.Status() != 404 {
+		t.Errorf("Get() error = %v, want a not found error", getErr)
+	}
+	if _, createErr := s.Create(ctx, &Message{Title: "title", Body: "body", CreatedAt: created_at}); createErr != nil {
+		t.Errorf("Create() error = %v", createErr)
+	}
+	if _, updateErr := s.Update(ctx, &Message{Id: 1, Title: "title", Body: "body"}); updateErr != nil {
+		t.Errorf("Update() error = %v", updateErr)
+	}
+	if deleteErr := s.Delete(ctx, 1); deleteErr != nil {
+		t.Errorf("Delete() error = %v", deleteErr)
+	}
+	if _, listErr := s.List(ctx, ListOptions{}); listErr != nil {
+		t.Errorf("List() error = %v", listErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestMessageRepo_Delete(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -366,7 +1024,7 @@ func TestMessageRepo_Delete(t *testing.T) {
 
 	tests := []struct {
 		name    string
-		s       messageRepoInterface
+		s       MessageRepository
 		msgId   int64
 		mock    func()
 		want    *Message
@@ -378,7 +1036,7 @@ func TestMessageRepo_Delete(t *testing.T) {
 			s:     s,
 			msgId: 1,
 			mock: func() {
-				mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(1, DefaultTenant, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			wantErr: false,
 		},
@@ -387,7 +1045,7 @@ func TestMessageRepo_Delete(t *testing.T) {
 			s:     s,
 			msgId: 1,
 			mock: func() {
-				mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(100).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectPrepare("DELETE FROM messages").ExpectExec().WithArgs(100, DefaultTenant, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			wantErr: true,
 		},
@@ -396,15 +1054,17 @@ func TestMessageRepo_Delete(t *testing.T) {
 			s:     s,
 			msgId: 1,
 			mock: func() {
-				mock.ExpectPrepare("DELETE FROMSSSS messages").ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectPrepare("DELETE FROMSSSS messages").ExpectExec().WithArgs(1, DefaultTenant, DefaultTenant).WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			//statements are prepared once per repository, so every case gets its own
+			tt.s = NewMessageRepository(db)
 			tt.mock()
-			err := tt.s.Delete(tt.msgId)
+			err := tt.s.Delete(context.Background(), tt.msgId)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Delete() error new = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -416,12 +1076,12 @@ func TestMessageRepo_Delete(t *testing.T) {
 //When the right number of arguments are passed
 //This test is just to improve coverage
 func TestMessageRepo_Initialize(t *testing.T) {
-	dbdriver :=  "mysql"
+	dbdriver := "mysql"
 	username := "username"
 	password := "password"
 	host := "host"
 	database := "database"
 	port := "port"
-	dbConnect := MessageRepo.Initialize(dbdriver, username, password, port, host, database)
+	dbConnect := (&messageRepo{}).Initialize(dbdriver, username, password, port, host, database)
 	fmt.Println("this is the pool: ", dbConnect)
-}
\ No newline at end of file
+}
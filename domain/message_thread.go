@@ -0,0 +1,56 @@
+package domain
+
+//How deep GetThread goes below the message asked for, when the caller does not say, and at most
+const (
+	DefaultThreadDepth = 5
+	MaxThreadDepth     = 20
+)
+
+//What to do with the replies of a deleted message
+const (
+	//OnDeleteRestrict refuses to delete a message that has replies
+	OnDeleteRestrict = "restrict"
+	//OnDeleteCascade deletes the replies too, at any depth
+	OnDeleteCascade = "cascade"
+	//OnDeleteDetach keeps the replies, which start threads of their own
+	OnDeleteDetach = "detach"
+)
+
+//ValidOnDelete reports whether policy is one of OnDeleteRestrict, OnDeleteCascade or OnDeleteDetach
+func ValidOnDelete(policy string) bool {
+	return policy == OnDeleteRestrict || policy == OnDeleteCascade || policy == OnDeleteDetach
+}
+
+//Thread is a message with its replies, each with their own replies, in id order.
+//A reply whose ReplyCount is greater than the length of its Replies was cut by the depth limit.
+type Thread struct {
+	Message
+	Replies []Thread `json:"replies"`
+}
+
+//NewThread nests msgs, given in id order, under the message with the id rootId. Messages that do not descend from it are left out.
+//It returns nil when rootId is not among msgs.
+func NewThread(rootId int64, msgs []Message) *Thread {
+	children := make(map[int64][]Message)
+	var root *Message
+	for i := range msgs {
+		if msgs[i].Id == rootId {
+			root = &msgs[i]
+			continue
+		}
+		children[msgs[i].ParentId] = append(children[msgs[i].ParentId], msgs[i])
+	}
+	if root == nil {
+		return nil
+	}
+	var build func(msg Message) Thread
+	build = func(msg Message) Thread {
+		t := Thread{Message: msg, Replies: make([]Thread, 0, len(children[msg.Id]))}
+		for _, reply := range children[msg.Id] {
+			t.Replies = append(t.Replies, build(reply))
+		}
+		return t
+	}
+	thread := build(*root)
+	return &thread
+}
@@ -0,0 +1,2 @@
+ALTER TABLE `messages` ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`;
+ALTER TABLE `messages` DROP INDEX `title_UNIQUE`, ADD UNIQUE INDEX `title_UNIQUE` (`tenant_id` ASC, `title` ASC);
@@ -0,0 +1 @@
+ALTER TABLE `messages` ADD COLUMN `parent_id` INT NULL AFTER `tenant_id`, ADD INDEX `parent_id_INDEX` (`parent_id` ASC);
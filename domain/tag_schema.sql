@@ -0,0 +1,13 @@
+CREATE TABLE `tags` (
+  `id` INT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+  `name` VARCHAR(32) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name_UNIQUE` (`tenant_id` ASC, `name` ASC));
+CREATE TABLE `message_tags` (
+  `message_id` INT NOT NULL,
+  `tag_id` INT NOT NULL,
+  PRIMARY KEY (`message_id`, `tag_id`),
+  INDEX `tag_id_INDEX` (`tag_id` ASC),
+  FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`) ON DELETE CASCADE);
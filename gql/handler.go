@@ -0,0 +1,75 @@
+package gql
+
+import (
+	"context"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"net/http"
+)
+
+type request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+type serviceKey struct{}
+
+func withService(ctx context.Context, service services.MessageService) context.Context {
+	return context.WithValue(ctx, serviceKey{}, service)
+}
+
+//serviceFrom returns the service the resolvers of the request work with
+func serviceFrom(ctx context.Context) services.MessageService {
+	return ctx.Value(serviceKey{}).(services.MessageService)
+}
+
+//NewHandler executes graphql requests against Schema, resolved with the given service. Each request gets its own message loader.
+//Requests that cannot be executed at all are answered like the rest of the api, through problems.
+func NewHandler(service services.MessageService, problems error_utils.Problems) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+			problems.Render(c.Writer, c.Request, error_utils.NewUnprocessibleEntityError("invalid json body"))
+			return
+		}
+		ctx := withService(c.Request.Context(), service)
+		result := graphql.Do(graphql.Params{
+			Schema:         Schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        withLoader(ctx, newMessageLoader(ctx, service)),
+		})
+		restoreExtensions(result.Errors)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+//restoreExtensions fills in the extensions of errors raised from thunks, which graphql-go drops
+//while wrapping them. The gqlError is still reachable through the chain of original errors.
+func restoreExtensions(errs []gqlerrors.FormattedError) {
+	for i := range errs {
+		if errs[i].Extensions != nil {
+			continue
+		}
+		var err error = errs[i]
+		for err != nil {
+			if e, ok := err.(*gqlError); ok {
+				errs[i].Extensions = e.Extensions()
+				break
+			}
+			switch wrapped := err.(type) {
+			case gqlerrors.FormattedError:
+				err = wrapped.OriginalError()
+			case *gqlerrors.Error:
+				err = wrapped.OriginalError
+			default:
+				err = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gql
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"sync"
+)
+
+type loaderKey struct{}
+
+type loadResult struct {
+	message *domain.Message
+	err     error_utils.MessageErr
+}
+
+//messageLoader collects the message ids requested while a level of the query is resolved and
+//fetches them in one batch the first time any of them is needed. Every id is fetched at most once per request.
+type messageLoader struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	pending []int64
+	results map[int64]*loadResult
+	batchFn func(context.Context, []int64) map[int64]*loadResult
+}
+
+func newMessageLoader(ctx context.Context, service services.MessageService) *messageLoader {
+	return &messageLoader{
+		ctx:     ctx,
+		results: make(map[int64]*loadResult),
+		batchFn: func(ctx context.Context, ids []int64) map[int64]*loadResult {
+			return getMessages(ctx, service, ids)
+		},
+	}
+}
+
+//getMessages is the batch function used by the loader. It fetches all the ids at once; the ids that were not found get a 404,
+//and every id gets the error when the fetch fails.
+func getMessages(ctx context.Context, service services.MessageService, ids []int64) map[int64]*loadResult {
+	results := make(map[int64]*loadResult, len(ids))
+	msgs, err := service.GetMessages(ctx, ids)
+	if err != nil {
+		for _, id := range ids {
+			results[id] = &loadResult{err: err}
+		}
+		return results
+	}
+	for i := range msgs {
+		results[msgs[i].Id] = &loadResult{message: &msgs[i]}
+	}
+	for _, id := range ids {
+		if _, ok := results[id]; !ok {
+			results[id] = &loadResult{err: error_utils.NewNotFoundError("no record matching given id")}
+		}
+	}
+	return results
+}
+
+func withLoader(ctx context.Context, l *messageLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFrom(ctx context.Context) *messageLoader {
+	if l, ok := ctx.Value(loaderKey{}).(*messageLoader); ok {
+		return l
+	}
+	return newMessageLoader(ctx, serviceFrom(ctx))
+}
+
+//Load queues the id and returns a thunk that resolves it, dispatching the batch if needed
+func (l *messageLoader) Load(id int64) func() (*domain.Message, error_utils.MessageErr) {
+	l.mu.Lock()
+	if _, ok := l.results[id]; !ok && !l.isPending(id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (*domain.Message, error_utils.MessageErr) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.results[id]; !ok {
+			l.dispatch()
+		}
+		res := l.results[id]
+		return res.message, res.err
+	}
+}
+
+//Prime stores a message that was fetched some other way, so later loads of it are free
+func (l *messageLoader) Prime(msg domain.Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.results[msg.Id] = &loadResult{message: &msg}
+}
+
+func (l *messageLoader) isPending(id int64) bool {
+	for _, p := range l.pending {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+//dispatch must be called with l.mu held
+func (l *messageLoader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+	for id, res := range l.batchFn(l.ctx, keys) {
+		l.results[id] = res
+	}
+}
@@ -0,0 +1,407 @@
+package gql
+
+import (
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"encoding/base64"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"strconv"
+	"strings"
+)
+
+const cursorPrefix = "message:"
+
+//gqlError carries a MessageErr into the "extensions" of a graphql error
+type gqlError struct {
+	err error_utils.MessageErr
+}
+
+func (e *gqlError) Error() string {
+	return e.err.Message()
+}
+
+func (e *gqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{
+		"status": e.err.Status(),
+		"error":  e.err.Error(),
+	}
+	if code := e.err.Code(); code != "" {
+		ext["code"] = code
+	}
+	if fields := e.err.Fields(); len(fields) > 0 {
+		ext["fields"] = fields
+	}
+	if details := e.err.Details(); len(details) > 0 {
+		ext["details"] = details
+	}
+	return ext
+}
+
+func toGraphQLError(err error_utils.MessageErr) error {
+	return &gqlError{err: err}
+}
+
+type edge struct {
+	Cursor string
+	Node   domain.Message
+}
+
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+type connection struct {
+	Edges    []edge
+	PageInfo pageInfo
+}
+
+func encodeCursor(id int64) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error_utils.MessageErr) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, error_utils.NewBadRequestError("invalid cursor")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, error_utils.NewBadRequestError("invalid cursor")
+	}
+	return id, nil
+}
+
+func parseId(arg interface{}) (int64, error_utils.MessageErr) {
+	id, err := strconv.ParseInt(fmt.Sprint(arg), 10, 64)
+	if err != nil {
+		return 0, error_utils.NewBadRequestError("message id should be a number")
+	}
+	return id, nil
+}
+
+var messageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Message",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return strconv.FormatInt(p.Source.(domain.Message).Id, 10), nil
+			},
+		},
+		"title": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Message).Title, nil
+			},
+		},
+		"body": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Message).Body, nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Message).CreatedAt, nil
+			},
+		},
+		//parentId is null for a message starting a thread
+		"parentId": &graphql.Field{
+			Type: graphql.ID,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if parentId := p.Source.(domain.Message).ParentId; parentId != 0 {
+					return strconv.FormatInt(parentId, 10), nil
+				}
+				return nil, nil
+			},
+		},
+		"replyCount": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return int(p.Source.(domain.Message).ReplyCount), nil
+			},
+		},
+		//tags is an empty list for a message without tags
+		"tags": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if tags := p.Source.(domain.Message).Tags; tags != nil {
+					return tags, nil
+				}
+				return []string{}, nil
+			},
+		},
+	},
+})
+
+var edgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MessageEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(edge).Cursor, nil
+			},
+		},
+		"node": &graphql.Field{
+			Type: graphql.NewNonNull(messageType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(edge).Node, nil
+			},
+		},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(pageInfo).HasNextPage, nil
+			},
+		},
+		"endCursor": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(pageInfo).EndCursor, nil
+			},
+		},
+	},
+})
+
+var connectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MessageConnection",
+	Fields: graphql.Fields{
+		"edges": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(connection).Edges, nil
+			},
+		},
+		"pageInfo": &graphql.Field{
+			Type: graphql.NewNonNull(pageInfoType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(connection).PageInfo, nil
+			},
+		},
+	},
+})
+
+var filterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "MessageFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"title": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "only messages whose title contains this text",
+		},
+		"tags": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "only messages carrying any of these tags, or all of them, see match",
+		},
+		"match": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "any, the default, or all",
+		},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"message": &graphql.Field{
+			Type: messageType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveMessage,
+		},
+		"messages": &graphql.Field{
+			Type: graphql.NewNonNull(connectionType),
+			Args: graphql.FieldConfigArgument{
+				"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: domain.DefaultListLimit},
+				"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				"filter": &graphql.ArgumentConfig{Type: filterType},
+			},
+			Resolve: resolveMessages,
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createMessage": &graphql.Field{
+			Type: messageType,
+			Args: graphql.FieldConfigArgument{
+				"title":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"body":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"parentId": &graphql.ArgumentConfig{Type: graphql.ID},
+				"tags":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			},
+			Resolve: resolveCreateMessage,
+		},
+		"updateMessage": &graphql.Field{
+			Type: messageType,
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"title": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"body":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				//leaving tags out keeps the tags of the message, an empty list removes them
+				"tags": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			},
+			Resolve: resolveUpdateMessage,
+		},
+		"deleteMessage": &graphql.Field{
+			Type: graphql.Boolean,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveDeleteMessage,
+		},
+	},
+})
+
+//Schema is the graphql schema served on POST /graphql
+var Schema = mustSchema(graphql.SchemaConfig{
+	Query:    queryType,
+	Mutation: mutationType,
+})
+
+//mustSchema fails at init when the schema is invalid, rather than serving every query with an empty one
+func mustSchema(config graphql.SchemaConfig) graphql.Schema {
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		panic(fmt.Sprintf("gql: invalid schema: %s", err.Error()))
+	}
+	return schema
+}
+
+//resolveMessage goes through the request's loader, so several message(id) lookups in one query are batched
+func resolveMessage(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseId(p.Args["id"])
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	thunk := loaderFrom(p.Context).Load(id)
+	return func() (interface{}, error) {
+		msg, err := thunk()
+		if err != nil {
+			return nil, toGraphQLError(err)
+		}
+		return *msg, nil
+	}, nil
+}
+
+func resolveMessages(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+	if first <= 0 || first > domain.MaxListLimit {
+		return nil, toGraphQLError(error_utils.NewBadRequestError(fmt.Sprintf("first should be between 1 and %d", domain.MaxListLimit)))
+	}
+	opts := domain.ListOptions{Limit: first + 1} //one extra row tells us whether there is a next page
+	if after, ok := p.Args["after"].(string); ok {
+		afterId, err := decodeCursor(after)
+		if err != nil {
+			return nil, toGraphQLError(err)
+		}
+		opts.AfterId = afterId
+	}
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		opts.Title, _ = filter["title"].(string)
+		tags, err := domain.FilterTags(stringList(filter["tags"]))
+		if err != nil {
+			return nil, toGraphQLError(err)
+		}
+		opts.Tags = tags
+		opts.Match, _ = filter["match"].(string)
+		if opts.Match != "" && opts.Match != domain.MatchAny && opts.Match != domain.MatchAll {
+			return nil, toGraphQLError(error_utils.NewBadRequestError("match should be one of any or all"))
+		}
+	}
+	messages, err := serviceFrom(p.Context).ListMessages(p.Context, opts)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	conn := connection{Edges: make([]edge, 0, len(messages))}
+	if len(messages) > first {
+		conn.PageInfo.HasNextPage = true
+		messages = messages[:first]
+	}
+	loader := loaderFrom(p.Context)
+	for _, msg := range messages {
+		loader.Prime(msg)
+		conn.Edges = append(conn.Edges, edge{Cursor: encodeCursor(msg.Id), Node: msg})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
+}
+
+func resolveCreateMessage(p graphql.ResolveParams) (interface{}, error) {
+	message := &domain.Message{
+		Title: p.Args["title"].(string),
+		Body:  p.Args["body"].(string),
+	}
+	if arg, ok := p.Args["parentId"]; ok && arg != nil {
+		parentId, err := parseId(arg)
+		if err != nil {
+			return nil, toGraphQLError(err)
+		}
+		message.ParentId = parentId
+	}
+	message.Tags = stringList(p.Args["tags"])
+	msg, err := serviceFrom(p.Context).CreateMessage(p.Context, message)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return *msg, nil
+}
+
+func resolveUpdateMessage(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseId(p.Args["id"])
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	message := &domain.Message{
+		Id:    id,
+		Title: p.Args["title"].(string),
+		Body:  p.Args["body"].(string),
+		Tags:  stringList(p.Args["tags"]),
+	}
+	msg, err := serviceFrom(p.Context).UpdateMessage(p.Context, message)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return *msg, nil
+}
+
+func resolveDeleteMessage(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseId(p.Args["id"])
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	if err := serviceFrom(p.Context).DeleteMessage(p.Context, id); err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return true, nil
+}
+
+//stringList converts a list argument to strings. An argument left out is nil, an empty list is not.
+func stringList(arg interface{}) []string {
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
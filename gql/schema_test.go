@@ -0,0 +1,317 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func doQuery(t *testing.T, sm *messagestest.Service, query string, variables map[string]interface{}) response {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	r := gin.Default()
+	r.POST("/graphql", NewHandler(sm, error_utils.Problems{}))
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+
+	var resp response
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("cannot decode graphql response: %v", err)
+	}
+	return resp
+}
+
+func TestMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessagesFunc = func(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Title: "the title", Body: "the body"}}, nil
+	}
+	resp := doQuery(t, sm, `{ message(id: 1) { id title body } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	msg := resp.Data["message"].(map[string]interface{})
+	assert.EqualValues(t, "1", msg["id"])
+	assert.EqualValues(t, "the title", msg["title"])
+	assert.EqualValues(t, "the body", msg["body"])
+}
+
+//Several lookups in one round trip should reach the service once, with every distinct id
+func TestMessage_Batched_Lookups(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessagesFunc = func(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		msgs := make([]domain.Message, 0, len(msgIds))
+		for _, id := range msgIds {
+			msgs = append(msgs, domain.Message{Id: id, Title: "the title", Body: "the body"})
+		}
+		return msgs, nil
+	}
+	resp := doQuery(t, sm, `{ a: message(id: 1) { id } b: message(id: 2) { id } c: message(id: 1) { title } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	assert.EqualValues(t, "1", resp.Data["a"].(map[string]interface{})["id"])
+	assert.EqualValues(t, "2", resp.Data["b"].(map[string]interface{})["id"])
+	assert.EqualValues(t, "the title", resp.Data["c"].(map[string]interface{})["title"])
+	sm.AssertNumberOfCalls(t, "GetMessages", 1)
+	sm.AssertNotCalled(t, "GetMessage")
+	assert.ElementsMatch(t, []int64{1, 2}, sm.CallsTo("GetMessages")[0].Args[0])
+}
+
+func TestMessage_Not_Found(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessagesFunc = func(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+	resp := doQuery(t, sm, `{ message(id: 1) { id } }`, nil)
+
+	assert.EqualValues(t, 1, len(resp.Errors))
+	assert.EqualValues(t, "no record matching given id", resp.Errors[0].Message)
+	assert.EqualValues(t, http.StatusNotFound, resp.Errors[0].Extensions["status"])
+	assert.EqualValues(t, "not_found", resp.Errors[0].Extensions["error"])
+}
+
+func TestMessage_Service_Error(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessagesFunc = func(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewServiceUnavailableError("database unavailable")
+	}
+	resp := doQuery(t, sm, `{ a: message(id: 1) { id } b: message(id: 2) { id } }`, nil)
+
+	assert.EqualValues(t, 2, len(resp.Errors))
+	for _, e := range resp.Errors {
+		assert.EqualValues(t, "database unavailable", e.Message)
+		assert.EqualValues(t, http.StatusServiceUnavailable, e.Extensions["status"])
+	}
+}
+
+func TestMessages_Pagination(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	var gotOpts domain.ListOptions
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		gotOpts = opts
+		return []domain.Message{
+			{Id: 3, Title: "third title", Body: "third body"},
+			{Id: 4, Title: "fourth title", Body: "fourth body"},
+			{Id: 5, Title: "fifth title", Body: "fifth body"},
+		}, nil
+	}
+	resp := doQuery(t, sm, `query($after: String) {
+		messages(first: 2, after: $after, filter: {title: "title"}) {
+			edges { cursor node { id title } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`, map[string]interface{}{"after": encodeCursor(2)})
+
+	assert.Empty(t, resp.Errors)
+	assert.EqualValues(t, domain.ListOptions{Limit: 3, AfterId: 2, Title: "title"}, gotOpts)
+
+	conn := resp.Data["messages"].(map[string]interface{})
+	edges := conn["edges"].([]interface{})
+	assert.EqualValues(t, 2, len(edges))
+	assert.EqualValues(t, "3", edges[0].(map[string]interface{})["node"].(map[string]interface{})["id"])
+	page := conn["pageInfo"].(map[string]interface{})
+	assert.EqualValues(t, true, page["hasNextPage"])
+	assert.EqualValues(t, encodeCursor(4), page["endCursor"])
+}
+
+func TestMessages_Invalid_Cursor(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	resp := doQuery(t, sm, `{ messages(after: "nonsense") { edges { cursor } } }`, nil)
+
+	assert.EqualValues(t, 1, len(resp.Errors))
+	assert.EqualValues(t, "invalid cursor", resp.Errors[0].Message)
+	assert.EqualValues(t, http.StatusBadRequest, resp.Errors[0].Extensions["status"])
+}
+
+func TestCreateMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		message.Id = 1
+		return message, nil
+	}
+	resp := doQuery(t, sm, `mutation { createMessage(title: "the title", body: "the body") { id title body } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	msg := resp.Data["createMessage"].(map[string]interface{})
+	assert.EqualValues(t, "1", msg["id"])
+	assert.EqualValues(t, "the title", msg["title"])
+}
+
+func TestCreateMessage_Reply(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		message.Id = 2
+		return message, nil
+	}
+	resp := doQuery(t, sm, `mutation { createMessage(title: "the reply", body: "the body", parentId: "1") { id parentId replyCount } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	msg := resp.Data["createMessage"].(map[string]interface{})
+	assert.EqualValues(t, "1", msg["parentId"])
+	assert.EqualValues(t, 0, msg["replyCount"])
+}
+
+func TestCreateMessage_Tags(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		message.Id = 1
+		return message, nil
+	}
+	resp := doQuery(t, sm, `mutation { createMessage(title: "the title", body: "the body", tags: ["go", "news"]) { id tags } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	msg := resp.Data["createMessage"].(map[string]interface{})
+	assert.EqualValues(t, []interface{}{"go", "news"}, msg["tags"])
+}
+
+func TestUpdateMessage_Keeps_Tags(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return message, nil
+	}
+	resp := doQuery(t, sm, `mutation { updateMessage(id: "1", title: "the title", body: "the body") { tags } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	//the tags left out are nil for the service, which keeps them, and an empty list in the response
+	sm.AssertCalled(t, "UpdateMessage", &domain.Message{Id: 1, Title: "the title", Body: "the body"})
+	assert.EqualValues(t, []interface{}{}, resp.Data["updateMessage"].(map[string]interface{})["tags"])
+}
+
+func TestMessages_Tag_Filter(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ListMessagesFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+	resp := doQuery(t, sm, `{ messages(first: 2, filter: {tags: ["News", "go"], match: "all"}) { edges { cursor } } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	sm.AssertCalled(t, "ListMessages", domain.ListOptions{Limit: 3, Tags: []string{"go", "news"}, Match: domain.MatchAll})
+
+	resp = doQuery(t, sm, `{ messages(filter: {match: "some"}) { edges { cursor } } }`, nil)
+	assert.NotEmpty(t, resp.Errors)
+}
+
+//A tag the controller would refuse is refused here too, before it reaches the FIND_IN_SET of the tagged queries
+func TestMessages_Invalid_Tag_Filter(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	resp := doQuery(t, sm, `{ messages(first: 2, filter: {tags: ["go,news"]}) { edges { cursor } } }`, nil)
+	assert.EqualValues(t, 1, len(resp.Errors))
+	assert.EqualValues(t, http.StatusBadRequest, resp.Errors[0].Extensions["status"])
+	assert.EqualValues(t, "bad_request", resp.Errors[0].Extensions["error"])
+
+	tags := make([]interface{}, domain.MaxTags+1)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag%d", i)
+	}
+	query := `query($tags: [String!]) { messages(first: 2, filter: {tags: $tags}) { edges { cursor } } }`
+	resp = doQuery(t, sm, query, map[string]interface{}{"tags": tags})
+	assert.EqualValues(t, 1, len(resp.Errors))
+	assert.EqualValues(t, http.StatusBadRequest, resp.Errors[0].Extensions["status"])
+	sm.AssertNotCalled(t, "ListMessages")
+}
+
+func TestCreateMessage_Invalid_Request(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid title")
+	}
+	resp := doQuery(t, sm, `mutation { createMessage(title: "", body: "the body") { id } }`, nil)
+
+	assert.EqualValues(t, 1, len(resp.Errors))
+	assert.EqualValues(t, "Please enter a valid title", resp.Errors[0].Message)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, resp.Errors[0].Extensions["status"])
+	assert.EqualValues(t, "invalid_request", resp.Errors[0].Extensions["error"])
+}
+
+func TestUpdateMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return message, nil
+	}
+	resp := doQuery(t, sm, `mutation { updateMessage(id: 1, title: "update title", body: "update body") { id title body } }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	msg := resp.Data["updateMessage"].(map[string]interface{})
+	assert.EqualValues(t, "update title", msg["title"])
+	assert.EqualValues(t, "update body", msg["body"])
+}
+
+func TestDeleteMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.DeleteMessageFunc = func(ctx context.Context, msgId int64) error_utils.MessageErr {
+		return nil
+	}
+	resp := doQuery(t, sm, `mutation { deleteMessage(id: 1) }`, nil)
+
+	assert.Empty(t, resp.Errors)
+	assert.EqualValues(t, true, resp.Data["deleteMessage"])
+}
+
+func TestHandler_Invalid_Json(t *testing.T) {
+	t.Parallel()
+	r := gin.Default()
+	r.POST("/graphql", NewHandler(&messagestest.Service{}, error_utils.Problems{}))
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(`{"query": 123}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, apiErr.Status())
+	assert.EqualValues(t, "invalid json body", apiErr.Message())
+}
+
+//A client asking for problem documents gets one, like from the rest of the api
+func TestHandler_Invalid_Json_Problem(t *testing.T) {
+	t.Parallel()
+	r := gin.Default()
+	r.POST("/graphql", NewHandler(&messagestest.Service{}, error_utils.Problems{}))
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(`{"query": 123}`))
+	req.Header.Set("Accept", error_utils.ProblemContentType)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.EqualValues(t, error_utils.ProblemContentType, rr.Header().Get("Content-Type"))
+	var problem map[string]interface{}
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.EqualValues(t, "invalid json body", problem["detail"])
+}
+
+func TestMustSchema_Invalid(t *testing.T) {
+	t.Parallel()
+	assert.Panics(t, func() { mustSchema(graphql.SchemaConfig{}) })
+}
@@ -0,0 +1,45 @@
+package integration__tests
+
+import (
+	"context"
+	"efficient-api/conformance"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+//The MySQL repository is the reference the other implementations are held to. Every case gets a database of its own.
+func TestMySQLRepository_Conformance(t *testing.T) {
+	conformance.Repository(t, func(t *testing.T) domain.MessageRepository {
+		return newTestDB(t).repo
+	})
+}
+
+func TestMySQLService_Conformance(t *testing.T) {
+	conformance.Service(t, func(t *testing.T) services.MessageService {
+		return services.NewMessagesService(newTestDB(t).repo)
+	})
+}
+
+//A reply written straight in the database under another tenant is neither read nor deleted with the thread of its parent
+func TestMySQLRepository_Thread_Stays_In_Tenant(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	parent := db.seed(t, aMessage("parent"))[0]
+	if _, err := db.conn.Exec("INSERT INTO messages(tenant_id, parent_id, title, body, created_at) VALUES(?, ?, ?, ?, ?);", "acme", parent.Id, "foreign reply", "body", time.Now()); err != nil {
+		t.Fatalf("Error seeding the foreign reply: %v", err)
+	}
+	ctx := context.Background()
+
+	thread, err := db.repo.Thread(ctx, parent.Id, 5)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(thread))
+	assert.EqualValues(t, 0, thread[0].ReplyCount)
+
+	assert.Nil(t, db.repo.Delete(ctx, parent.Id))
+	msgs, err := db.repo.GetAll(domain.WithTenant(ctx, "acme"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(msgs))
+}
@@ -2,7 +2,6 @@ package integration__tests
 
 import (
 	"bytes"
-	"efficient-api/controllers"
 	"efficient-api/domain"
 	"encoding/json"
 	"fmt"
@@ -16,15 +15,8 @@ import (
 )
 
 func TestCreateMessage(t *testing.T) {
-
-	database()
-
-	gin.SetMode(gin.TestMode)
-
-	err := refreshMessagesTable()
-	if err != nil {
-		log.Fatal(err)
-	}
+	t.Parallel()
+	db := newTestDB(t)
 	samples := []struct {
 		inputJSON  string
 		statusCode int
@@ -41,7 +33,7 @@ func TestCreateMessage(t *testing.T) {
 		},
 		{
 			inputJSON:  `{"title":"the title", "body": "the body"}`,
-			statusCode: 500,
+			statusCode: 409,
 			errMessage: "title already taken",
 		},
 		{
@@ -69,7 +61,7 @@ func TestCreateMessage(t *testing.T) {
 	}
 	for _, v := range samples {
 		r := gin.Default()
-		r.POST("/messages", controllers.CreateMessage)
+		r.POST("/messages", db.controller.CreateMessage)
 		req, err := http.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(v.inputJSON))
 		if err != nil {
 			t.Errorf("this is the error: %v\n", err)
@@ -89,26 +81,16 @@ func TestCreateMessage(t *testing.T) {
 			assert.Equal(t, responseMap["title"], v.title)
 			assert.Equal(t, responseMap["body"], v.body)
 		}
-		if v.statusCode == 400 || v.statusCode == 422 || v.statusCode == 500 && v.errMessage != "" {
+		if v.statusCode == 400 || v.statusCode == 409 || v.statusCode == 422 || v.statusCode == 500 && v.errMessage != "" {
 			assert.Equal(t, responseMap["message"], v.errMessage)
 		}
 	}
 }
 
 func TestGetMessageByID(t *testing.T) {
-
-	database()
-
-	gin.SetMode(gin.TestMode)
-
-	err := refreshMessagesTable()
-	if err != nil {
-		log.Fatal(err)
-	}
-	message, err := seedOneMessage()
-	if err != nil {
-		t.Errorf("Error while seeding table: %s", err)
-	}
+	t.Parallel()
+	db := newTestDB(t)
+	message := db.seed(t, aMessage("the"))[0]
 
 	samples := []struct {
 		id         string
@@ -137,7 +119,7 @@ func TestGetMessageByID(t *testing.T) {
 	}
 	for _, v := range samples {
 		r := gin.Default()
-		r.GET("/messages/:message_id", controllers.GetMessage)
+		r.GET("/messages/:message_id", db.controller.GetMessage)
 		req, err := http.NewRequest(http.MethodGet, "/messages/"+v.id, nil)
 		if err != nil {
 			t.Errorf("this is the error: %v\n", err)
@@ -164,19 +146,9 @@ func TestGetMessageByID(t *testing.T) {
 }
 
 func TestUpdateMessage(t *testing.T) {
-
-	database()
-
-	gin.SetMode(gin.TestMode)
-
-	err := refreshMessagesTable()
-	if err != nil {
-		log.Fatal(err)
-	}
-	messages, err := seedMessages()
-	if err != nil {
-		t.Errorf("Error while seeding table: %s", err)
-	}
+	t.Parallel()
+	db := newTestDB(t)
+	messages := db.seed(t, aMessage("first"), aMessage("second"))
 
 	//Get only the first message id
 	firstId := messages[0].Id
@@ -201,7 +173,7 @@ func TestUpdateMessage(t *testing.T) {
 			// "second title" belongs to the second message so, the cannot be used for the first message
 			id:         strconv.Itoa(int(firstId)),
 			inputJSON:  `{"title":"second title", "body": "update body"}`,
-			statusCode: 500,
+			statusCode: 409,
 			errMessage: "title already taken",
 		},
 		{
@@ -246,7 +218,7 @@ func TestUpdateMessage(t *testing.T) {
 	}
 	for _, v := range samples {
 		r := gin.Default()
-		r.PUT("/messages/:message_id", controllers.UpdateMessage)
+		r.PUT("/messages/:message_id", db.controller.UpdateMessage)
 		req, err := http.NewRequest(http.MethodPut, "/messages/"+v.id, bytes.NewBufferString(v.inputJSON))
 		if err != nil {
 			t.Errorf("this is the error: %v\n", err)
@@ -265,28 +237,18 @@ func TestUpdateMessage(t *testing.T) {
 			assert.Equal(t, responseMap["title"], v.title)
 			assert.Equal(t, responseMap["body"], v.body)
 		}
-		if v.statusCode == 400 || v.statusCode == 422 || v.statusCode == 500 && v.errMessage != "" {
+		if v.statusCode == 400 || v.statusCode == 409 || v.statusCode == 422 || v.statusCode == 500 && v.errMessage != "" {
 			assert.Equal(t, responseMap["message"], v.errMessage)
 		}
 	}
 }
 
 func TestGetAllMessage(t *testing.T) {
-
-	database()
-
-	gin.SetMode(gin.TestMode)
-
-	err := refreshMessagesTable()
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = seedMessages()
-	if err != nil {
-		t.Errorf("Error while seeding table: %s", err)
-	}
+	t.Parallel()
+	db := newTestDB(t)
+	db.seed(t, aMessage("first"), aMessage("second"))
 	r := gin.Default()
-	r.GET("/messages", controllers.GetAllMessages)
+	r.GET("/messages", db.controller.GetAllMessages)
 
 	req, err := http.NewRequest(http.MethodGet, "/messages", nil)
 	if err != nil {
@@ -306,19 +268,9 @@ func TestGetAllMessage(t *testing.T) {
 }
 
 func TestDeleteMessage(t *testing.T) {
-
-	database()
-
-	gin.SetMode(gin.TestMode)
-
-	err := refreshMessagesTable()
-	if err != nil {
-		log.Fatal(err)
-	}
-	message, err := seedOneMessage()
-	if err != nil {
-		t.Errorf("Error while seeding table: %s", err)
-	}
+	t.Parallel()
+	db := newTestDB(t)
+	message := db.seed(t, aMessage("the"))[0]
 	samples := []struct {
 		id         string
 		statusCode int
@@ -344,7 +296,7 @@ func TestDeleteMessage(t *testing.T) {
 	}
 	for _, v := range samples {
 		r := gin.Default()
-		r.DELETE("/messages/:message_id", controllers.DeleteMessage)
+		r.DELETE("/messages/:message_id", db.controller.DeleteMessage)
 		req, err := http.NewRequest(http.MethodDelete, "/messages/"+v.id, nil)
 		if err != nil {
 			t.Errorf("this is the error: %v\n", err)
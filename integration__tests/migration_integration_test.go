@@ -0,0 +1,99 @@
+package integration__tests
+
+import (
+	"context"
+	"database/sql"
+	"efficient-api/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+//legacySchema is the messages table as it was created before tenants and threads
+const legacySchema = "CREATE TABLE `messages` (\n" +
+	"  `id` INT NOT NULL AUTO_INCREMENT,\n" +
+	"  `title` VARCHAR(100) NULL,\n" +
+	"  `body` VARCHAR(200) NULL,\n" +
+	"  `created_at` TIMESTAMP NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE INDEX `title_UNIQUE` (`title` ASC));\n"
+
+//migrationStatements reads the statements of the files of domain/migrations, in the order of their names
+func migrationStatements(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("../domain/migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Error finding the migrations: %v", err)
+	}
+	sort.Strings(files)
+	var statements []string
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Error reading migration %s: %v", file, err)
+		}
+		for _, statement := range strings.SplitAfter(string(b), ";\n") {
+			if strings.TrimSpace(statement) != "" {
+				statements = append(statements, statement)
+			}
+		}
+	}
+	return statements
+}
+
+//A database created before tenants and threads gets them from the migrations, its messages going to the default tenant
+func TestMigrations_Upgrade_A_Legacy_Database(t *testing.T) {
+	t.Parallel()
+	name := createDB(t)
+	conn, err := sql.Open(os.Getenv("DBDRIVER_TEST"), dsn(name))
+	if err != nil {
+		t.Fatalf("Error connecting to database %s: %v", name, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(legacySchema); err != nil {
+		t.Fatalf("Error creating the legacy table: %v", err)
+	}
+	if _, err := conn.Exec("INSERT INTO messages(title, body, created_at) VALUES(?, ?, ?);", "the title", "the body", time.Now()); err != nil {
+		t.Fatalf("Error seeding the legacy table: %v", err)
+	}
+
+	for _, statement := range migrationStatements(t) {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("Error running migration %q: %v", statement, err)
+		}
+	}
+
+	var tenant string
+	assert.Nil(t, conn.QueryRow("SELECT tenant_id FROM messages WHERE title=?;", "the title").Scan(&tenant))
+	assert.EqualValues(t, domain.DefaultTenant, tenant)
+	//titles are only unique within a tenant now
+	_, err = conn.Exec("INSERT INTO messages(tenant_id, title, body, created_at) VALUES(?, ?, ?, ?);", "acme", "the title", "the body", time.Now())
+	assert.Nil(t, err)
+
+	//the tags come from their schema, as for a new database, and then the repository works on the old messages
+	for _, statement := range domain.TagSchema() {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("Error creating the tags: %v", err)
+		}
+	}
+	repo, db := domain.Connect(os.Getenv("DBDRIVER_TEST"), os.Getenv("USERNAME_TEST"), os.Getenv("PASSWORD_TEST"), os.Getenv("PORT_TEST"), os.Getenv("HOST_TEST"), name, domain.DefaultReplicaSettings())
+	defer db.Close()
+	ctx := context.Background()
+	old, getErr := repo.GetByTitle(ctx, "the title")
+	if getErr != nil {
+		t.Fatalf("Error getting the migrated message: %s", getErr.Message())
+	}
+	reply, createErr := repo.Create(ctx, &domain.Message{ParentId: old.Id, Title: "the reply", Body: "the body", CreatedAt: time.Now()})
+	if createErr != nil {
+		t.Fatalf("Error replying to the migrated message: %s", createErr.Message())
+	}
+	thread, threadErr := repo.Thread(ctx, old.Id, 5)
+	assert.Nil(t, threadErr)
+	assert.EqualValues(t, 2, len(thread))
+	assert.EqualValues(t, reply.Id, thread[1].Id)
+}
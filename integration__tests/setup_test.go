@@ -2,22 +2,33 @@ package integration__tests
 
 import (
 	"database/sql"
+	"efficient-api/controllers"
 	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/error_utils"
+	"fmt"
+	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-const (
-	queryTruncateMessage = "TRUNCATE TABLE messages;"
-	queryInsertMessage  = "INSERT INTO messages(title, body, created_at) VALUES(?, ?, ?);"
-	queryGetAllMessages = "SELECT id, title, body, created_at FROM messages;"
-)
+const queryInsertMessage = "INSERT INTO messages(title, body, created_at) VALUES(?, ?, ?);"
+
+//migrations create the tables of a new database, in the order they were added
+var migrations = append([]string{
+	domain.Schema(),
+}, domain.TagSchema()...)
+
 var (
-	dbConn  *sql.DB
+	//server is connected to the MySQL server without picking a database, to create and drop the ones of the tests
+	server *sql.DB
+	//databases counts the databases created by this run, to name each one differently
+	databases int64
 )
 
 func TestMain(m *testing.M) {
@@ -26,99 +37,105 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatalf("Error getting env %v\n", err)
 	}
-	os.Exit(m.Run())
+	gin.SetMode(gin.TestMode)
+
+	server, err = sql.Open(os.Getenv("DBDRIVER_TEST"), dsn(""))
+	if err != nil {
+		log.Fatalf("Error connecting to the database server: %v\n", err)
+	}
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
 }
 
-func database() {
-	dbDriver := os.Getenv("DBDRIVER_TEST")
-	username := os.Getenv("USERNAME_TEST")
-	password := os.Getenv("PASSWORD_TEST")
-	host := os.Getenv("HOST_TEST")
-	database := os.Getenv("DATABASE_TEST")
-	port := os.Getenv("PORT_TEST")
+func dsn(database string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
+		os.Getenv("USERNAME_TEST"), os.Getenv("PASSWORD_TEST"), os.Getenv("HOST_TEST"), os.Getenv("PORT_TEST"), database)
+}
 
-	dbConn = domain.MessageRepo.Initialize(dbDriver, username, password, port, host, database)
+//testDB is a database of its own, so that the tests using it can run in parallel and leave nothing behind
+type testDB struct {
+	name string
+	conn *sql.DB
+	repo domain.MessageRepository
+	//the controller under test, backed by the real service and repository
+	controller *controllers.MessagesController
 }
 
-func refreshMessagesTable() error {
+//newTestDB creates a database for the test, applies the migrations to it, and drops it once the test is over,
+//whether it passed or not. Its name starts with DATABASE_TEST.
+func newTestDB(t *testing.T) *testDB {
+	t.Helper()
+	name := createDB(t)
+	migrate(t, name)
 
-	stmt, err := dbConn.Prepare(queryTruncateMessage)
-	if err != nil {
-		panic(err.Error())
-	}
-	_, err = stmt.Exec()
-	if err != nil {
-		log.Fatalf("Error truncating messages table: %s", err)
-	}
-	return nil
+	db := &testDB{name: name}
+	db.repo, db.conn = domain.Connect(os.Getenv("DBDRIVER_TEST"), os.Getenv("USERNAME_TEST"), os.Getenv("PASSWORD_TEST"), os.Getenv("PORT_TEST"), os.Getenv("HOST_TEST"), name, domain.DefaultReplicaSettings())
+	t.Cleanup(func() {
+		db.conn.Close()
+	})
+	db.controller = controllers.NewMessagesController(services.NewMessagesService(db.repo), error_utils.Problems{})
+	return db
 }
 
-func seedOneMessage() (domain.Message, error) {
-	msg := domain.Message{
-		Title:     "the title",
-		Body:      "the body",
-		CreatedAt: time.Now(),
-	}
-	stmt, err := dbConn.Prepare(queryInsertMessage)
-	if err != nil {
-		panic(err.Error())
-	}
-	insertResult, createErr := stmt.Exec(msg.Title, msg.Body, msg.CreatedAt)
-	if createErr != nil {
-		log.Fatalf("Error creating message: %s", createErr)
+//createDB creates an empty database, dropped once the test is over, and returns its name
+func createDB(t *testing.T) string {
+	t.Helper()
+	name := fmt.Sprintf("%s_%d_%d", os.Getenv("DATABASE_TEST"), time.Now().UnixNano(), atomic.AddInt64(&databases, 1))
+	if _, err := server.Exec("CREATE DATABASE `" + name + "`;"); err != nil {
+		t.Fatalf("Error creating database %s: %v", name, err)
 	}
-	msgId, err := insertResult.LastInsertId()
-	if err != nil {
-		log.Fatalf("Error creating message: %s", createErr)
-	}
-	msg.Id = msgId
-	return msg, nil
+	t.Cleanup(func() {
+		if _, err := server.Exec("DROP DATABASE IF EXISTS `" + name + "`;"); err != nil {
+			t.Errorf("Error dropping database %s: %v", name, err)
+		}
+	})
+	return name
 }
 
-func seedMessages() ([]domain.Message, error) {
-	msgs := []domain.Message{
-		{
-			Title:     "first title",
-			Body:      "first body",
-			CreatedAt: time.Now(),
-		},
-		{
-			Title:     "second title",
-			Body:      "second body",
-			CreatedAt: time.Now(),
-		},
-	}
-	stmt, err := dbConn.Prepare(queryInsertMessage)
+//migrate runs before the repository connects, so that it finds the tables when it prepares its statements
+func migrate(t *testing.T, name string) {
+	t.Helper()
+	conn, err := sql.Open(os.Getenv("DBDRIVER_TEST"), dsn(name))
 	if err != nil {
-		panic(err.Error())
+		t.Fatalf("Error connecting to database %s: %v", name, err)
 	}
-	for i, _ := range msgs {
-		_, createErr := stmt.Exec(msgs[i].Title, msgs[i].Body, msgs[i].CreatedAt)
-		if createErr != nil {
-			return nil, createErr
+	defer conn.Close()
+	for _, migration := range migrations {
+		if _, err := conn.Exec(migration); err != nil {
+			t.Fatalf("Error migrating database %s: %v", name, err)
 		}
 	}
-	get_stmt, err := dbConn.Prepare(queryGetAllMessages)
-	if err != nil {
-		return nil, err
+}
+
+//aMessage builds a message to seed, with a title and body made from name. Change the fields the test cares about.
+func aMessage(name string) domain.Message {
+	return domain.Message{
+		Title:     name + " title",
+		Body:      name + " body",
+		CreatedAt: time.Now(),
 	}
-	defer stmt.Close()
+}
 
-	rows, err := get_stmt.Query()
+//seed saves the messages as they are, without going through the repository, and returns them with their ids
+func (db *testDB) seed(t *testing.T, msgs ...domain.Message) []domain.Message {
+	t.Helper()
+	stmt, err := db.conn.Prepare(queryInsertMessage)
 	if err != nil {
-		return nil,  err
+		t.Fatalf("Error preparing seed: %v", err)
 	}
-	defer rows.Close()
-
-	results := make([]domain.Message, 0)
+	defer stmt.Close()
 
-	for rows.Next() {
-		var msg domain.Message
-		if getError := rows.Scan(&msg.Id, &msg.Title, &msg.Body, &msg.CreatedAt); getError != nil {
-			return nil, err
+	seeded := make([]domain.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		insertResult, err := stmt.Exec(msg.Title, msg.Body, msg.CreatedAt)
+		if err != nil {
+			t.Fatalf("Error seeding message %q: %v", msg.Title, err)
+		}
+		if msg.Id, err = insertResult.LastInsertId(); err != nil {
+			t.Fatalf("Error seeding message %q: %v", msg.Title, err)
 		}
-		results = append(results, msg)
+		seeded = append(seeded, msg)
 	}
-	return results, nil
+	return seeded
 }
-
@@ -2,11 +2,24 @@ package main
 
 import (
 	"efficient-api/app"
+	"efficient-api/rpc"
+	"efficient-api/services"
 	"fmt"
+	"log"
+	"net/http"
 )
 
 func main() {
 	fmt.Println("Welcome to the app")
-	app.StartApp()
-}
+	cfg := app.ConfigFromEnv()
+
+	repo := app.NewRepository(cfg)
+	fmt.Println("DATABASE STARTED")
+	service := services.NewMessagesService(repo, app.ServiceOptions(cfg)...)
 
+	//the grpc api is served on its own port, alongside the REST api
+	go rpc.StartServer(":"+cfg.GRPCPort, service, cfg.TenantAuth)
+	fmt.Println("GRPC SERVER STARTED ON PORT", cfg.GRPCPort)
+
+	log.Fatal(http.ListenAndServe(cfg.HTTPAddr, app.New(cfg, app.Deps{Repository: repo, Service: service})))
+}
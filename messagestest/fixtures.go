@@ -0,0 +1,71 @@
+package messagestest
+
+import (
+	"context"
+	"efficient-api/domain"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+//fixtureFile is the content of a fixture file:
+//
+//	messages:
+//	  - title: first title
+//	    body: first body
+//	    created_at: 2020-01-01T10:00:00Z
+//	    tags: [go, news]
+//	  - title: a reply
+//	    body: a reply body
+//	    parent_id: 1
+//
+//parent_id is the id the message replied to gets when the file is seeded in an empty repository
+type fixtureFile struct {
+	Messages []struct {
+		ParentId  int64     `yaml:"parent_id"`
+		Title     string    `yaml:"title"`
+		Body      string    `yaml:"body"`
+		Tags      []string  `yaml:"tags"`
+		CreatedAt time.Time `yaml:"created_at"`
+	} `yaml:"messages"`
+}
+
+//LoadFixtures reads the messages of a yaml fixture file, in the order they are listed
+func LoadFixtures(path string) ([]domain.Message, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file fixtureFile
+	if err := yaml.UnmarshalStrict(b, &file); err != nil {
+		return nil, fmt.Errorf("invalid fixture file %s: %s", path, err.Error())
+	}
+	msgs := make([]domain.Message, 0, len(file.Messages))
+	for _, m := range file.Messages {
+		msgs = append(msgs, domain.Message{ParentId: m.ParentId, Title: m.Title, Body: m.Body, Tags: m.Tags, CreatedAt: m.CreatedAt})
+	}
+	return msgs, nil
+}
+
+//Seed creates the messages of the fixture files in the repository, file after file, and returns them with their ids.
+//It fails the test when a file cannot be read or a message cannot be created.
+func Seed(t testing.TB, repo domain.MessageRepository, paths ...string) []domain.Message {
+	t.Helper()
+	seeded := make([]domain.Message, 0)
+	for _, path := range paths {
+		msgs, err := LoadFixtures(path)
+		if err != nil {
+			t.Fatalf("messagestest: %s", err.Error())
+		}
+		for i := range msgs {
+			created, createErr := repo.Create(context.Background(), &msgs[i])
+			if createErr != nil {
+				t.Fatalf("messagestest: cannot seed %q from %s: %s", msgs[i].Title, path, createErr.Message())
+			}
+			seeded = append(seeded, *created)
+		}
+	}
+	return seeded
+}
@@ -0,0 +1,212 @@
+package messagestest
+
+import (
+	"bytes"
+	"efficient-api/domain"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//scenario is the content of a request file:
+//
+//	fixtures: [../fixtures/messages.yaml]
+//	request:
+//	  method: POST
+//	  path: /messages
+//	  headers:
+//	    Accept: application/problem+json
+//	  body: '{"title": "the title", "body": "the body"}'
+//	ignore: [created_at]
+//
+//The fixtures are relative to the request file. The fields listed in ignore are left out of the golden file, at any depth.
+type scenario struct {
+	Fixtures []string `yaml:"fixtures"`
+	Request  struct {
+		Method  string            `yaml:"method"`
+		Path    string            `yaml:"path"`
+		Headers map[string]string `yaml:"headers"`
+		Body    string            `yaml:"body"`
+	} `yaml:"request"`
+	Ignore []string `yaml:"ignore"`
+}
+
+//golden is what is kept of a response
+type golden struct {
+	Status      int         `json:"status"`
+	ContentType string      `json:"content_type,omitempty"`
+	Body        interface{} `json:"body,omitempty"`
+}
+
+//GoldenOption changes how RunGolden checks the responses
+type GoldenOption func(*goldenOptions)
+
+type goldenOptions struct {
+	update bool
+}
+
+//Update makes RunGolden write the golden files from the responses received, instead of comparing them, when update is set.
+//The package defines no flag, tests pass one of their own, e.g. Update(*update) with var update = flag.Bool("update", false, "...").
+func Update(update bool) GoldenOption {
+	return func(o *goldenOptions) {
+		o.update = update
+	}
+}
+
+//RunGolden replays every request file (*.yaml) of dir, each in a subtest of its own, against the handler built by newHandler
+//on top of an in-memory repository seeded with the fixtures of the file. The status, content type and body of the response
+//are compared with the golden file next to it, named after it with a .golden.json extension.
+//Pass Update to write the golden files from the responses received, then review the diff.
+func RunGolden(t *testing.T, dir string, newHandler func(repo domain.MessageRepository) http.Handler, opts ...GoldenOption) {
+	t.Helper()
+	var o goldenOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("messagestest: no request file in %s", dir)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".yaml"), func(t *testing.T) {
+			runScenario(t, path, newHandler, o)
+		})
+	}
+}
+
+func runScenario(t *testing.T, path string, newHandler func(repo domain.MessageRepository) http.Handler, o goldenOptions) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("messagestest: %s", err.Error())
+	}
+	var sc scenario
+	if err := yaml.UnmarshalStrict(b, &sc); err != nil {
+		t.Fatalf("messagestest: invalid request file %s: %s", path, err.Error())
+	}
+
+	repo := domain.NewMemoryRepository()
+	for _, fixture := range sc.Fixtures {
+		Seed(t, repo, filepath.Join(filepath.Dir(path), fixture))
+	}
+
+	req := httptest.NewRequest(sc.Request.Method, sc.Request.Path, strings.NewReader(sc.Request.Body))
+	if sc.Request.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range sc.Request.Headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	newHandler(repo).ServeHTTP(rr, req)
+
+	got, err := encodeGolden(rr, sc.Ignore)
+	if err != nil {
+		t.Fatalf("messagestest: cannot encode the response to %s: %s", path, err.Error())
+	}
+	goldenPath := strings.TrimSuffix(path, ".yaml") + ".golden.json"
+	if o.update {
+		if err := ioutil.WriteFile(goldenPath, got, 0644); err != nil {
+			t.Fatalf("messagestest: %s", err.Error())
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(goldenPath)
+	if os.IsNotExist(err) {
+		t.Fatalf("messagestest: %s does not exist, run the test with Update to create it", goldenPath)
+	}
+	if err != nil {
+		t.Fatalf("messagestest: %s", err.Error())
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("the response does not match %s, run the test with Update if the change is expected:\n%s", goldenPath, diff(string(want), string(got)))
+	}
+}
+
+//encodeGolden writes the response as indented json. A body that is not json is kept as a string.
+func encodeGolden(rr *httptest.ResponseRecorder, ignore []string) ([]byte, error) {
+	g := golden{Status: rr.Code, ContentType: rr.Header().Get("Content-Type")}
+	if rr.Body.Len() > 0 {
+		dec := json.NewDecoder(bytes.NewReader(rr.Body.Bytes()))
+		dec.UseNumber()
+		var body interface{}
+		if dec.Decode(&body) == nil {
+			g.Body = mask(body, ignore)
+		} else {
+			g.Body = rr.Body.String()
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//mask replaces the values of the ignored fields, so that the golden file still shows they are there
+func mask(v interface{}, ignore []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			v[k] = mask(field, ignore)
+			for _, name := range ignore {
+				if k == name {
+					v[k] = "<ignored>"
+				}
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = mask(v[i], ignore)
+		}
+	}
+	return v
+}
+
+//diff lists the lines of want and got, prefixing the ones only in want with - and the ones only in got with +
+func diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	//lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, "  %s\n", a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
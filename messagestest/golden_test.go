@@ -0,0 +1,99 @@
+package messagestest
+
+import (
+	"efficient-api/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	t.Parallel()
+	msgs, err := LoadFixtures("testdata/messages.yaml")
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.Message{
+		{Title: "first title", Body: "first body", CreatedAt: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{Title: "second title", Body: "second body"},
+	}, msgs)
+}
+
+func TestLoadFixtures_Missing_File(t *testing.T) {
+	t.Parallel()
+	msgs, err := LoadFixtures("testdata/unknown.yaml")
+	assert.Nil(t, msgs)
+	assert.NotNil(t, err)
+}
+
+func TestSeed(t *testing.T) {
+	t.Parallel()
+	repo := domain.NewMemoryRepository()
+	msgs := Seed(t, repo, "testdata/messages.yaml")
+	assert.EqualValues(t, 2, len(msgs))
+	assert.EqualValues(t, 1, msgs[0].Id)
+	assert.EqualValues(t, 2, msgs[1].Id)
+	assert.EqualValues(t, "second title", msgs[1].Title)
+}
+
+func TestEncodeGolden_Masks_Ignored_Fields(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/json")
+	rr.WriteHeader(http.StatusOK)
+	rr.Write([]byte(`[{"id": 1, "created_at": "2020-01-01T10:00:00Z", "nested": {"created_at": "now"}}]`))
+
+	b, err := encodeGolden(rr, []string{"created_at"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, `{
+  "status": 200,
+  "content_type": "application/json",
+  "body": [
+    {
+      "created_at": "<ignored>",
+      "id": 1,
+      "nested": {
+        "created_at": "<ignored>"
+      }
+    }
+  ]
+}
+`, string(b))
+}
+
+func TestEncodeGolden_Body_Not_Json(t *testing.T) {
+	t.Parallel()
+	rr := httptest.NewRecorder()
+	rr.WriteHeader(http.StatusBadGateway)
+	rr.Write([]byte("bad gateway"))
+
+	b, err := encodeGolden(rr, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"body": "bad gateway"`)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	assert.EqualValues(t, "  {\n-   \"status\": 200\n+   \"status\": 404\n  }\n", diff("{\n  \"status\": 200\n}", "{\n  \"status\": 404\n}"))
+}
+
+func TestRunGolden_Update(t *testing.T) {
+	dir := t.TempDir()
+	request := "request:\n  method: GET\n  path: /messages/1\n"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "get.yaml"), []byte(request), 0644))
+	handler := func(repo domain.MessageRepository) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 1}`))
+		})
+	}
+
+	//the golden file is written, then matched without the option
+	RunGolden(t, dir, handler, Update(true))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "get.golden.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"status": 200`)
+	RunGolden(t, dir, handler)
+}
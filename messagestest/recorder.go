@@ -0,0 +1,79 @@
+//Package messagestest helps testing code that uses the messages api: Service and Repository are mocks
+//of services.MessageService and domain.MessageRepository that record their calls, and Server is a fake
+//of the REST api with scripted responses and injected faults.
+package messagestest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+//Call is one call made to a mock, with its arguments but without the context
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+//recorder keeps the calls made to a mock. It is safe for concurrent use.
+type recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (r *recorder) record(method string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+//Calls returns every call made so far, in order
+func (r *recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+//CallsTo returns the calls made to the given method, in order
+func (r *recorder) CallsTo(method string) []Call {
+	calls := make([]Call, 0)
+	for _, call := range r.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+//AssertCalled fails the test unless the method was called with the given arguments at least once
+func (r *recorder) AssertCalled(t testing.TB, method string, args ...interface{}) bool {
+	t.Helper()
+	calls := r.CallsTo(method)
+	for _, call := range calls {
+		if reflect.DeepEqual(call.Args, args) {
+			return true
+		}
+	}
+	t.Errorf("expected %s to be called with %v, got %v", method, args, calls)
+	return false
+}
+
+//AssertNotCalled fails the test if the method was called
+func (r *recorder) AssertNotCalled(t testing.TB, method string) bool {
+	t.Helper()
+	if calls := r.CallsTo(method); len(calls) > 0 {
+		t.Errorf("expected %s not to be called, got %v", method, calls)
+		return false
+	}
+	return true
+}
+
+//AssertNumberOfCalls fails the test unless the method was called n times
+func (r *recorder) AssertNumberOfCalls(t testing.TB, method string, n int) bool {
+	t.Helper()
+	if calls := r.CallsTo(method); len(calls) != n {
+		t.Errorf("expected %s to be called %d times, got %d", method, n, len(calls))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package messagestest
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+)
+
+var _ domain.MessageRepository = (*Repository)(nil)
+
+//Repository is a mock of domain.MessageRepository. Each method calls the function set for it, and returns a 500 when there is none.
+//Every call is recorded, with the arguments given after the context.
+type Repository struct {
+	recorder
+
+	GetFunc        func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr)
+	GetManyFunc    func(ctx context.Context, messageIds []int64) ([]domain.Message, error_utils.MessageErr)
+	CreateFunc     func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr)
+	UpdateFunc     func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr)
+	DeleteFunc     func(ctx context.Context, messageId int64) error_utils.MessageErr
+	DeleteLeafFunc func(ctx context.Context, messageId int64) error_utils.MessageErr
+	GetAllFunc     func(ctx context.Context) ([]domain.Message, error_utils.MessageErr)
+	ListFunc       func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	GetByTitleFunc func(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr)
+	StreamFunc     func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr
+	CountFunc      func(ctx context.Context) (int64, error_utils.MessageErr)
+	RepliesFunc    func(ctx context.Context, parentId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	ThreadFunc     func(ctx context.Context, messageId int64, depth int) ([]domain.Message, error_utils.MessageErr)
+	DetachFunc     func(ctx context.Context, messageId int64) error_utils.MessageErr
+	TagsFunc       func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr)
+	RenameTagFunc  func(ctx context.Context, from, to string) error_utils.MessageErr
+	MergeTagsFunc  func(ctx context.Context, from []string, into string) error_utils.MessageErr
+}
+
+func (r *Repository) Get(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
+	r.record("Get", messageId)
+	if r.GetFunc == nil {
+		return nil, notSet("Get")
+	}
+	return r.GetFunc(ctx, messageId)
+}
+
+func (r *Repository) GetMany(ctx context.Context, messageIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	r.record("GetMany", messageIds)
+	if r.GetManyFunc == nil {
+		return nil, notSet("GetMany")
+	}
+	return r.GetManyFunc(ctx, messageIds)
+}
+
+func (r *Repository) Create(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	r.record("Create", msg)
+	if r.CreateFunc == nil {
+		return nil, notSet("Create")
+	}
+	return r.CreateFunc(ctx, msg)
+}
+
+func (r *Repository) Update(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	r.record("Update", msg)
+	if r.UpdateFunc == nil {
+		return nil, notSet("Update")
+	}
+	return r.UpdateFunc(ctx, msg)
+}
+
+func (r *Repository) Delete(ctx context.Context, messageId int64) error_utils.MessageErr {
+	r.record("Delete", messageId)
+	if r.DeleteFunc == nil {
+		return notSet("Delete")
+	}
+	return r.DeleteFunc(ctx, messageId)
+}
+
+func (r *Repository) DeleteLeaf(ctx context.Context, messageId int64) error_utils.MessageErr {
+	r.record("DeleteLeaf", messageId)
+	if r.DeleteLeafFunc == nil {
+		return notSet("DeleteLeaf")
+	}
+	return r.DeleteLeafFunc(ctx, messageId)
+}
+
+func (r *Repository) GetAll(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
+	r.record("GetAll")
+	if r.GetAllFunc == nil {
+		return nil, notSet("GetAll")
+	}
+	return r.GetAllFunc(ctx)
+}
+
+func (r *Repository) List(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	r.record("List", opts)
+	if r.ListFunc == nil {
+		return nil, notSet("List")
+	}
+	return r.ListFunc(ctx, opts)
+}
+
+func (r *Repository) GetByTitle(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr) {
+	r.record("GetByTitle", title)
+	if r.GetByTitleFunc == nil {
+		return nil, notSet("GetByTitle")
+	}
+	return r.GetByTitleFunc(ctx, title)
+}
+
+//Stream records the call without fn, which cannot be compared
+func (r *Repository) Stream(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+	r.record("Stream")
+	if r.StreamFunc == nil {
+		return notSet("Stream")
+	}
+	return r.StreamFunc(ctx, fn)
+}
+
+func (r *Repository) Count(ctx context.Context) (int64, error_utils.MessageErr) {
+	r.record("Count")
+	if r.CountFunc == nil {
+		return 0, notSet("Count")
+	}
+	return r.CountFunc(ctx)
+}
+
+func (r *Repository) Replies(ctx context.Context, parentId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	r.record("Replies", parentId, opts)
+	if r.RepliesFunc == nil {
+		return nil, notSet("Replies")
+	}
+	return r.RepliesFunc(ctx, parentId, opts)
+}
+
+func (r *Repository) Thread(ctx context.Context, messageId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+	r.record("Thread", messageId, depth)
+	if r.ThreadFunc == nil {
+		return nil, notSet("Thread")
+	}
+	return r.ThreadFunc(ctx, messageId, depth)
+}
+
+func (r *Repository) Detach(ctx context.Context, messageId int64) error_utils.MessageErr {
+	r.record("Detach", messageId)
+	if r.DetachFunc == nil {
+		return notSet("Detach")
+	}
+	return r.DetachFunc(ctx, messageId)
+}
+
+func (r *Repository) Tags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	r.record("Tags")
+	if r.TagsFunc == nil {
+		return nil, notSet("Tags")
+	}
+	return r.TagsFunc(ctx)
+}
+
+func (r *Repository) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	r.record("RenameTag", from, to)
+	if r.RenameTagFunc == nil {
+		return notSet("RenameTag")
+	}
+	return r.RenameTagFunc(ctx, from, to)
+}
+
+func (r *Repository) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	r.record("MergeTags", from, into)
+	if r.MergeTagsFunc == nil {
+		return notSet("MergeTags")
+	}
+	return r.MergeTagsFunc(ctx, from, into)
+}
@@ -0,0 +1,158 @@
+package messagestest
+
+import (
+	"bytes"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+//Request is a request received by a Server
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+//Response is a scripted answer of a Server. The zero Status passes the request on to the handler,
+//after Delay, so that a slow server can be faked without changing what it answers.
+type Response struct {
+	Status int
+	Header http.Header
+	//Body is sent as json, except for []byte which is sent as it is. An error_utils.MessageErr is sent as the api sends it.
+	Body  interface{}
+	Delay time.Duration
+	//Drop closes the connection without answering, as a server that went away would
+	Drop bool
+}
+
+//Fault answers with the given error, as the api would
+func Fault(err error_utils.MessageErr) Response {
+	return Response{Status: err.Status(), Body: err}
+}
+
+//Slow passes the request on to the handler after the given delay
+func Slow(delay time.Duration) Response {
+	return Response{Delay: delay}
+}
+
+//Drop closes the connection without answering
+func Drop() Response {
+	return Response{Drop: true}
+}
+
+//Server is a fake of the messages REST api for the tests of its clients. It serves the given handler,
+//unless the request was scripted with Script, and records every request it receives. To serve the real api
+//on top of messages kept in memory:
+//
+//	srv := messagestest.NewServer(app.New(app.Config{}, app.Deps{Repository: domain.NewMemoryRepository()}))
+//	defer srv.Close()
+//	srv.Script(http.MethodGet, "/messages/1", messagestest.Fault(error_utils.NewServiceUnavailableError("try again")))
+type Server struct {
+	*httptest.Server
+	handler http.Handler
+
+	mu       sync.Mutex
+	scripts  []*script
+	requests []Request
+}
+
+type script struct {
+	method    string
+	path      string
+	responses []Response
+}
+
+//NewServer starts a Server in front of handler. A nil handler answers 404 to what is not scripted.
+func NewServer(handler http.Handler) *Server {
+	if handler == nil {
+		handler = http.NotFoundHandler()
+	}
+	s := &Server{handler: handler}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+//Script makes the next requests with the given method and path get the responses, one each and in order.
+//An empty method or path matches any. Once the responses are used up, the requests reach the handler again.
+func (s *Server) Script(method, path string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts = append(s.scripts, &script{method: method, path: path, responses: responses})
+}
+
+//Requests returns the requests received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+//next records the request and returns the scripted response for it, if any
+func (s *Server) next(r *http.Request, body []byte) (Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header.Clone(), Body: body})
+	for _, sc := range s.scripts {
+		if len(sc.responses) == 0 || (sc.method != "" && sc.method != r.Method) || (sc.path != "" && sc.path != r.URL.Path) {
+			continue
+		}
+		res := sc.responses[0]
+		sc.responses = sc.responses[1:]
+		return res, true
+	}
+	return Response{}, false
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res, ok := s.next(r, body)
+	if !ok {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+	if res.Delay > 0 {
+		select {
+		case <-time.After(res.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if res.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if res.Status == 0 {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+	for key, values := range res.Header {
+		w.Header()[key] = values
+	}
+	if err, ok := res.Body.(error_utils.MessageErr); ok {
+		error_utils.Problems{}.Render(w, r, err)
+		return
+	}
+	if raw, ok := res.Body.([]byte); ok || res.Body == nil {
+		w.WriteHeader(res.Status)
+		w.Write(raw)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(res.Status)
+	json.NewEncoder(w).Encode(res.Body)
+}
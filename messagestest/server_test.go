@@ -0,0 +1,148 @@
+package messagestest
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("cannot get %s: %v", url, err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func TestServer_Passes_Through_To_Handler(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from the handler"))
+	}))
+	defer srv.Close()
+
+	status, body := get(t, srv.URL+"/messages/1")
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.EqualValues(t, "from the handler", body)
+}
+
+func TestServer_Nil_Handler_Answers_Not_Found(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(nil)
+	defer srv.Close()
+
+	status, _ := get(t, srv.URL+"/messages/1")
+	assert.EqualValues(t, http.StatusNotFound, status)
+}
+
+func TestServer_Scripted_Responses_In_Order(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(nil)
+	defer srv.Close()
+	srv.Script(http.MethodGet, "/messages/1",
+		Response{Status: http.StatusOK, Body: domain.Message{Id: 1, Title: "the title"}},
+		Fault(error_utils.NewNotFoundError("no record matching given id")),
+	)
+
+	status, body := get(t, srv.URL+"/messages/1")
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.Contains(t, body, `"title":"the title"`)
+
+	status, body = get(t, srv.URL+"/messages/1")
+	assert.EqualValues(t, http.StatusNotFound, status)
+	assert.Contains(t, body, "no record matching given id")
+
+	//the script is used up
+	status, _ = get(t, srv.URL+"/messages/1")
+	assert.EqualValues(t, http.StatusNotFound, status)
+	assert.EqualValues(t, 3, len(srv.Requests()))
+}
+
+func TestServer_Script_Matches_Method_And_Path(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(nil)
+	defer srv.Close()
+	srv.Script(http.MethodPost, "/messages", Response{Status: http.StatusCreated, Body: []byte("created")})
+
+	status, _ := get(t, srv.URL+"/messages")
+	assert.EqualValues(t, http.StatusNotFound, status)
+
+	res, err := http.Post(srv.URL+"/messages", "application/json", strings.NewReader(`{"title":"the title"}`))
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.EqualValues(t, http.StatusCreated, res.StatusCode)
+
+	requests := srv.Requests()
+	assert.EqualValues(t, 2, len(requests))
+	assert.EqualValues(t, http.MethodPost, requests[1].Method)
+	assert.EqualValues(t, `{"title":"the title"}`, string(requests[1].Body))
+}
+
+func TestServer_Drop(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(nil)
+	defer srv.Close()
+	srv.Script("", "", Drop())
+
+	_, err := http.Get(srv.URL + "/messages/1")
+	assert.NotNil(t, err)
+}
+
+func TestServer_Slow(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(nil)
+	defer srv.Close()
+	srv.Script("", "", Slow(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/messages/1", nil)
+	_, err := http.DefaultClient.Do(req.WithContext(ctx))
+	assert.NotNil(t, err)
+}
+
+func TestService_Records_Calls(t *testing.T) {
+	t.Parallel()
+	sm := &Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId}, nil
+	}
+
+	msg, err := sm.GetMessage(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+
+	//a method without a function fails, but is still recorded
+	err = sm.DeleteMessage(context.Background(), 2)
+	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	assert.EqualValues(t, "messagestest: DeleteMessage is not set", err.Message())
+
+	sm.AssertCalled(t, "GetMessage", int64(1))
+	sm.AssertCalled(t, "DeleteMessage", int64(2))
+	sm.AssertNotCalled(t, "CreateMessage")
+	sm.AssertNumberOfCalls(t, "GetMessage", 1)
+	assert.EqualValues(t, []Call{{Method: "GetMessage", Args: []interface{}{int64(1)}}, {Method: "DeleteMessage", Args: []interface{}{int64(2)}}}, sm.Calls())
+}
+
+func TestRepository_Records_Calls(t *testing.T) {
+	t.Parallel()
+	repo := &Repository{}
+	repo.ListFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1}}, nil
+	}
+
+	msgs, err := repo.List(context.Background(), domain.ListOptions{Limit: 10})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(msgs))
+	repo.AssertCalled(t, "List", domain.ListOptions{Limit: 10})
+	assert.EqualValues(t, 1, len(repo.CallsTo("List")))
+}
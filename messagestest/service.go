@@ -0,0 +1,147 @@
+package messagestest
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+)
+
+//Service is a mock of services.MessageService. Each method calls the function set for it, and returns a 500 when there is none.
+//Every call is recorded, with the arguments given after the context.
+type Service struct {
+	recorder
+
+	GetMessageFunc     func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr)
+	GetMessagesFunc    func(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr)
+	CreateMessageFunc  func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr)
+	UpdateMessageFunc  func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr)
+	DeleteMessageFunc  func(ctx context.Context, msgId int64) error_utils.MessageErr
+	GetAllMessagesFunc func(ctx context.Context) ([]domain.Message, error_utils.MessageErr)
+	ListMessagesFunc   func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	ListRepliesFunc    func(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	GetThreadFunc      func(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr)
+	ListTagsFunc       func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr)
+	RenameTagFunc      func(ctx context.Context, from, to string) error_utils.MessageErr
+	MergeTagsFunc      func(ctx context.Context, from []string, into string) error_utils.MessageErr
+	ExportMessagesFunc func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr
+	ImportMessagesFunc func(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr)
+}
+
+func notSet(method string) error_utils.MessageErr {
+	return error_utils.NewInternalServerError("messagestest: " + method + " is not set")
+}
+
+func (s *Service) GetMessage(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	s.record("GetMessage", msgId)
+	if s.GetMessageFunc == nil {
+		return nil, notSet("GetMessage")
+	}
+	return s.GetMessageFunc(ctx, msgId)
+}
+
+func (s *Service) GetMessages(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	s.record("GetMessages", msgIds)
+	if s.GetMessagesFunc == nil {
+		return nil, notSet("GetMessages")
+	}
+	return s.GetMessagesFunc(ctx, msgIds)
+}
+
+func (s *Service) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	s.record("CreateMessage", message)
+	if s.CreateMessageFunc == nil {
+		return nil, notSet("CreateMessage")
+	}
+	return s.CreateMessageFunc(ctx, message)
+}
+
+func (s *Service) UpdateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	s.record("UpdateMessage", message)
+	if s.UpdateMessageFunc == nil {
+		return nil, notSet("UpdateMessage")
+	}
+	return s.UpdateMessageFunc(ctx, message)
+}
+
+func (s *Service) DeleteMessage(ctx context.Context, msgId int64) error_utils.MessageErr {
+	s.record("DeleteMessage", msgId)
+	if s.DeleteMessageFunc == nil {
+		return notSet("DeleteMessage")
+	}
+	return s.DeleteMessageFunc(ctx, msgId)
+}
+
+func (s *Service) GetAllMessages(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
+	s.record("GetAllMessages")
+	if s.GetAllMessagesFunc == nil {
+		return nil, notSet("GetAllMessages")
+	}
+	return s.GetAllMessagesFunc(ctx)
+}
+
+func (s *Service) ListMessages(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	s.record("ListMessages", opts)
+	if s.ListMessagesFunc == nil {
+		return nil, notSet("ListMessages")
+	}
+	return s.ListMessagesFunc(ctx, opts)
+}
+
+func (s *Service) ListReplies(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	s.record("ListReplies", msgId, opts)
+	if s.ListRepliesFunc == nil {
+		return nil, notSet("ListReplies")
+	}
+	return s.ListRepliesFunc(ctx, msgId, opts)
+}
+
+func (s *Service) GetThread(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr) {
+	s.record("GetThread", msgId, depth)
+	if s.GetThreadFunc == nil {
+		return nil, notSet("GetThread")
+	}
+	return s.GetThreadFunc(ctx, msgId, depth)
+}
+
+func (s *Service) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	s.record("ListTags")
+	if s.ListTagsFunc == nil {
+		return nil, notSet("ListTags")
+	}
+	return s.ListTagsFunc(ctx)
+}
+
+func (s *Service) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	s.record("RenameTag", from, to)
+	if s.RenameTagFunc == nil {
+		return notSet("RenameTag")
+	}
+	return s.RenameTagFunc(ctx, from, to)
+}
+
+func (s *Service) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	s.record("MergeTags", from, into)
+	if s.MergeTagsFunc == nil {
+		return notSet("MergeTags")
+	}
+	return s.MergeTagsFunc(ctx, from, into)
+}
+
+//ExportMessages records the call without fn, which cannot be compared
+func (s *Service) ExportMessages(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+	s.record("ExportMessages")
+	if s.ExportMessagesFunc == nil {
+		return notSet("ExportMessages")
+	}
+	return s.ExportMessagesFunc(ctx, fn)
+}
+
+//ImportMessages records the call with onDuplicate only, as the reader is consumed by the call
+func (s *Service) ImportMessages(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+	s.record("ImportMessages", onDuplicate)
+	if s.ImportMessagesFunc == nil {
+		return nil, notSet("ImportMessages")
+	}
+	return s.ImportMessagesFunc(ctx, r, onDuplicate)
+}
@@ -0,0 +1,89 @@
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+//The assets of Swagger UI are the files of its dist, version 3.38.0, under the Apache License in swagger-ui/LICENSE.
+//They are built into the binary, so /docs works without internet access.
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js swagger-ui/LICENSE
+var swaggerUIAssets embed.FS
+
+//swaggerUI is the page served on /docs. It loads Swagger UI from /docs/assets and points it at /openapi.json
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>Messages API</title>
+  <link rel="stylesheet" href="/docs/assets/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/assets/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>`
+
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+//Spec returns the OpenAPI document
+func Spec() []byte {
+	return []byte(specJSON)
+}
+
+//Operations lists every documented operation as "METHOD /path", using the OpenAPI {param} syntax
+func Operations() ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(Spec(), &doc); err != nil {
+		return nil, err
+	}
+	ops := make([]string, 0)
+	for path, item := range doc.Paths {
+		for _, method := range methods {
+			if _, ok := item[method]; ok {
+				ops = append(ops, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	sort.Strings(ops)
+	return ops, nil
+}
+
+//SpecHandler serves the OpenAPI document on /openapi.json
+func SpecHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", Spec())
+}
+
+//UIHandler serves Swagger UI on /docs
+func UIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
+
+//AssetsHandler serves the assets of Swagger UI on /docs/assets/*filepath
+func AssetsHandler(c *gin.Context) {
+	name := path.Join("swagger-ui", path.Clean("/"+c.Param("filepath")))
+	b, err := swaggerUIAssets.ReadFile(name)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, b)
+}
@@ -0,0 +1,146 @@
+package openapi
+
+import (
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+//schemaProperties returns the sorted property names of a schema in components/schemas
+func schemaProperties(t *testing.T, name string) []string {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(Spec(), &doc); err != nil {
+		t.Fatalf("the spec is not valid json: %v", err)
+	}
+	props := make([]string, 0)
+	for prop := range doc.Components.Schemas[name].Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	return props
+}
+
+//jsonKeys returns the sorted keys v is encoded with
+func jsonKeys(t *testing.T, v interface{}) []string {
+	b, _ := json.Marshal(v)
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("cannot decode %s: %v", b, err)
+	}
+	keys := make([]string, 0)
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOperations(t *testing.T) {
+	ops, err := Operations()
+	assert.Nil(t, err)
+	assert.Contains(t, ops, "GET /messages/{message_id}")
+	assert.Contains(t, ops, "POST /messages")
+}
+
+func TestMessageSchema_Matches_Domain(t *testing.T) {
+	//parent_id is left out of messages starting a thread
+	assert.EqualValues(t, jsonKeys(t, domain.Message{ParentId: 1, Tags: []string{"go"}}), schemaProperties(t, "Message"))
+}
+
+func TestMessageErrSchema_Matches_Error_Utils(t *testing.T) {
+	field := error_utils.FieldError{Field: "title", Code: error_utils.CodeTitleRequired, Message: "Please enter a valid title"}
+	err := error_utils.WithDetails(error_utils.NewValidationError(field), map[string]interface{}{"max": 100})
+	assert.EqualValues(t, jsonKeys(t, err), schemaProperties(t, "MessageErr"))
+	assert.EqualValues(t, jsonKeys(t, field), schemaProperties(t, "FieldError"))
+}
+
+func TestProblemSchema_Matches_Error_Utils(t *testing.T) {
+	field := error_utils.FieldError{Field: "title", Code: error_utils.CodeTitleRequired, Message: "Please enter a valid title"}
+	err := error_utils.WithDetails(error_utils.NewValidationError(field), map[string]interface{}{"max": 100})
+	assert.EqualValues(t, jsonKeys(t, error_utils.Problems{}.New(err, "/messages")), schemaProperties(t, "Problem"))
+}
+
+func TestMessageInputSchema_Matches_Columns(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					MaxLength int `json:"maxLength"`
+					MaxItems  int `json:"maxItems"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.Nil(t, json.Unmarshal(Spec(), &doc))
+	input := doc.Components.Schemas["MessageInput"].Properties
+	assert.EqualValues(t, domain.TitleMaxLength, input["title"].MaxLength)
+	assert.EqualValues(t, domain.BodyMaxLength, input["body"].MaxLength)
+	assert.EqualValues(t, domain.MaxTags, input["tags"].MaxItems)
+}
+
+func TestSpecHandler(t *testing.T) {
+	r := gin.Default()
+	r.GET("/openapi.json", SpecHandler)
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, Spec(), rr.Body.Bytes())
+}
+
+func TestUIHandler(t *testing.T) {
+	r := gin.Default()
+	r.GET("/docs", UIHandler)
+	req, _ := http.NewRequest(http.MethodGet, "/docs", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `url: "/openapi.json"`)
+	//the page loads nothing from another host
+	assert.NotContains(t, rr.Body.String(), "://")
+}
+
+func TestAssetsHandler(t *testing.T) {
+	r := gin.Default()
+	r.GET("/docs", UIHandler)
+	r.GET("/docs/assets/*filepath", AssetsHandler)
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/docs/assets/swagger-ui-bundle.js", http.StatusOK, "javascript"},
+		{"/docs/assets/swagger-ui.css", http.StatusOK, "text/css"},
+		{"/docs/assets/LICENSE", http.StatusOK, "text/plain"},
+		{"/docs/assets/", http.StatusNotFound, ""},
+		{"/docs/assets/unknown.js", http.StatusNotFound, ""},
+		{"/docs/assets/../openapi.go", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.EqualValues(t, tt.status, rr.Code, tt.path)
+		if tt.status == http.StatusOK {
+			assert.Contains(t, rr.Header().Get("Content-Type"), tt.contentType, tt.path)
+			assert.NotZero(t, rr.Body.Len(), tt.path)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package openapi
+
+//specJSON is the OpenAPI 3 description of the REST api. Keep it in step with app/routes.go,
+//TestRoutesMatchOpenAPI in the app package fails when the two drift apart.
+const specJSON = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Messages API",
+    "description": "A simple api to create, read, update and delete messages.",
+    "version": "1.0.0"
+  },
+  "security": [{}, {"bearerAuth": []}],
+  "paths": {
+    "/messages": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Get all messages, or one page of them",
+        "description": "Without any parameter every message is returned, and an empty table is a 404. With any of limit, after, title, tag or match, one page of messages ordered by id is returned, and an empty page is not an error. Keep passing the id of the last message as after to walk through the pages.",
+        "operationId": "getAllMessages",
+        "parameters": [
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+          {"name": "after", "in": "query", "description": "Only messages with a greater id", "schema": {"type": "integer", "format": "int64"}},
+          {"name": "title", "in": "query", "description": "Only messages whose title contains this text", "schema": {"type": "string"}},
+          {"name": "tag", "in": "query", "description": "Only messages carrying this tag; repeat it for several tags, see match", "schema": {"type": "array", "maxItems": 10, "items": {"$ref": "#/components/schemas/Tag"}}, "style": "form", "explode": true},
+          {"name": "match", "in": "query", "description": "Whether a message must carry any of the tags, or all of them", "schema": {"type": "string", "enum": ["any", "all"], "default": "any"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The messages",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Message"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      },
+      "post": {
+        "summary": "Create a message",
+        "operationId": "createMessage",
+        "requestBody": {"$ref": "#/components/requestBodies/MessageInput"},
+        "responses": {
+          "201": {
+            "description": "The created message",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/Message"}}
+            }
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {"$ref": "#/components/responses/Conflict"},
+          "422": {"$ref": "#/components/responses/InvalidRequest"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/messages/{message_id}": {
+      "parameters": [{"$ref": "#/components/parameters/MessageId"}, {"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Get a message",
+        "operationId": "getMessage",
+        "responses": {
+          "200": {
+            "description": "The message",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/Message"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      },
+      "put": {
+        "summary": "Update a message",
+        "operationId": "updateMessage",
+        "requestBody": {"$ref": "#/components/requestBodies/MessageInput"},
+        "responses": {
+          "200": {
+            "description": "The updated message",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/Message"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "409": {"$ref": "#/components/responses/Conflict"},
+          "422": {"$ref": "#/components/responses/InvalidRequest"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a message",
+        "description": "What happens to the replies of the message depends on how the server is set up: by default a message with replies cannot be deleted (409, code has_replies); the server may instead delete the replies too, at any depth, or keep them as messages starting threads of their own.",
+        "operationId": "deleteMessage",
+        "responses": {
+          "200": {
+            "description": "The message was deleted",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {"status": {"type": "string", "example": "deleted"}}
+                }
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "409": {"$ref": "#/components/responses/HasReplies"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/messages/{message_id}/replies": {
+      "parameters": [{"$ref": "#/components/parameters/MessageId"}, {"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Get one page of the replies to a message",
+        "description": "Only the direct replies are returned, ordered by id. Keep passing the id of the last reply as after to walk through the pages.",
+        "operationId": "listReplies",
+        "parameters": [
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+          {"name": "after", "in": "query", "description": "Only replies with a greater id", "schema": {"type": "integer", "format": "int64"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The replies",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Message"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/messages/{message_id}/thread": {
+      "parameters": [{"$ref": "#/components/parameters/MessageId"}, {"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Get a message with its replies, nested",
+        "description": "A reply whose reply_count is greater than the number of its replies was cut by the depth limit; ask for its own thread to read further.",
+        "operationId": "getThread",
+        "parameters": [
+          {"name": "depth", "in": "query", "description": "How many levels of replies to return below the message", "schema": {"type": "integer", "minimum": 1, "maximum": 20, "default": 5}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The thread",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/Thread"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/messages/export": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Export every message",
+        "description": "Messages are streamed in id order as they are read, as json lines (one message per line) or csv with an id,title,body,created_at,parent_id,tags header, the tags separated by spaces.",
+        "operationId": "exportMessages",
+        "parameters": [{"$ref": "#/components/parameters/Format"}],
+        "responses": {
+          "200": {
+            "description": "The messages",
+            "content": {
+              "application/x-ndjson": {"schema": {"$ref": "#/components/schemas/Message"}},
+              "text/csv": {"schema": {"type": "string"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/messages/import": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "post": {
+        "summary": "Import messages",
+        "description": "Every line is validated and saved on its own, a line that cannot be saved is reported and does not stop the import. An input that cannot be read any further stops it, the messages saved before are kept. Ids in the input are not kept, a reply is linked to the message its parent_id names in the input, which must come before it. created_at is kept when given.",
+        "operationId": "importMessages",
+        "parameters": [
+          {"$ref": "#/components/parameters/Format"},
+          {
+            "name": "on_duplicate",
+            "in": "query",
+            "description": "What to do with a message whose title is already taken: skip it, overwrite the body of the existing message, or report it as failed",
+            "schema": {"type": "string", "enum": ["skip", "overwrite", "fail"], "default": "fail"}
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/x-ndjson": {"schema": {"$ref": "#/components/schemas/MessageInput"}},
+            "text/csv": {"schema": {"type": "string"}}
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "What became of every line",
+            "content": {
+              "application/json": {"schema": {"$ref": "#/components/schemas/ImportReport"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "413": {"$ref": "#/components/responses/TooLarge"}
+        }
+      }
+    },
+    "/tags": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "get": {
+        "summary": "Get the tags of the messages",
+        "description": "Every tag carried by at least one message, ordered by name, with the number of messages carrying it.",
+        "operationId": "listTags",
+        "responses": {
+          "200": {
+            "description": "The tags",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/TagCount"}}
+              }
+            }
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/tags/{tag}": {
+      "parameters": [
+        {"name": "tag", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Tag"}},
+        {"$ref": "#/components/parameters/TenantId"}
+      ],
+      "put": {
+        "summary": "Rename a tag",
+        "description": "Every message carrying the tag carries the new name instead. A tag no message carries is a 404, and a new name some message carries already is a 409 with the code tag_taken; merge the tags instead.",
+        "operationId": "renameTag",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["name"],
+                "properties": {"name": {"$ref": "#/components/schemas/Tag"}}
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/Status"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "409": {"$ref": "#/components/responses/Conflict"},
+          "422": {"$ref": "#/components/responses/InvalidRequest"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/tags/merge": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "post": {
+        "summary": "Merge tags into one",
+        "description": "Every message carrying any of the tags from carries the tag into instead, which is created when needed. It is a 404 when no message carries any of the tags from.",
+        "operationId": "mergeTags",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["from", "into"],
+                "properties": {
+                  "from": {"type": "array", "minItems": 1, "items": {"$ref": "#/components/schemas/Tag"}},
+                  "into": {"$ref": "#/components/schemas/Tag"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/Status"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/InvalidRequest"},
+          "500": {"$ref": "#/components/responses/ServerError"},
+          "503": {"$ref": "#/components/responses/Unavailable"}
+        }
+      }
+    },
+    "/graphql": {
+      "parameters": [{"$ref": "#/components/parameters/TenantId"}],
+      "post": {
+        "summary": "Run a GraphQL query or mutation",
+        "operationId": "graphql",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["query"],
+                "properties": {
+                  "query": {"type": "string"},
+                  "variables": {"type": "object"},
+                  "operationName": {"type": "string"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "The GraphQL result. Errors are reported in its errors list.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "data": {"type": "object"},
+                    "errors": {"type": "array", "items": {"type": "object"}}
+                  }
+                }
+              }
+            }
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "422": {"$ref": "#/components/responses/InvalidRequest"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "bearerFormat": "JWT",
+        "description": "Required when the server is set up with a token secret. The tenant_id claim names the tenant of the request."
+      }
+    },
+    "parameters": {
+      "TenantId": {
+        "name": "X-Tenant-Id",
+        "in": "header",
+        "description": "The tenant whose messages the request reads and changes. Without a token it defaults to default; with one, the tenant of the token is used and this header must match it.",
+        "schema": {"type": "string", "pattern": "^[A-Za-z0-9_-]{1,64}$"}
+      },
+      "Format": {
+        "name": "format",
+        "in": "query",
+        "description": "Defaults to csv for a text/csv request body, jsonl otherwise",
+        "schema": {"type": "string", "enum": ["jsonl", "csv"]}
+      },
+      "MessageId": {
+        "name": "message_id",
+        "in": "path",
+        "required": true,
+        "schema": {"type": "integer", "format": "int64"}
+      }
+    },
+    "requestBodies": {
+      "MessageInput": {
+        "required": true,
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageInput"}}
+        }
+      }
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "The message id, a query parameter or the tenant id is not valid",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Unauthorized": {
+        "description": "The server requires a token, and the request carries none or an invalid one",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Forbidden": {
+        "description": "X-Tenant-Id names another tenant than the token, or the tenant holds as many messages as its quota allows (code quota_exceeded, with the limit in details)",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "NotFound": {
+        "description": "No message or tag matches the request",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "InvalidRequest": {
+        "description": "The body is not valid json or the message is invalid, e.g. it replies to a message that does not exist",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Conflict": {
+        "description": "Another message already has the title (code title_taken), or a message already carries the new name of a tag (code tag_taken)",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "HasReplies": {
+        "description": "The message has replies and the server does not delete messages with replies (code has_replies, with the number of replies in details)",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Status": {
+        "description": "The change was made",
+        "content": {
+          "application/json": {
+            "schema": {"type": "object", "properties": {"status": {"type": "string"}}}
+          }
+        }
+      },
+      "TooLarge": {
+        "description": "A line of the input is longer than the server accepts",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "ServerError": {
+        "description": "The request could not be processed",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Unavailable": {
+        "description": "The database is unavailable or the request clashed with another one. The same request may succeed if it is sent again.",
+        "content": {
+          "application/json": {"schema": {"$ref": "#/components/schemas/MessageErr"}},
+          "application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}
+        }
+      }
+    },
+    "schemas": {
+      "Message": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer", "format": "int64"},
+          "parent_id": {"type": "integer", "format": "int64", "description": "The message this one replies to, left out for a message starting a thread"},
+          "title": {"type": "string"},
+          "body": {"type": "string"},
+          "tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}, "description": "Sorted, left out when the message has none"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "reply_count": {"type": "integer", "format": "int64", "description": "The number of direct replies"}
+        }
+      },
+      "Tag": {
+        "type": "string",
+        "pattern": "^[a-z0-9][a-z0-9_-]{0,31}$",
+        "description": "Tags are trimmed and lowercased before use, so Go and go are the same tag"
+      },
+      "TagCount": {
+        "type": "object",
+        "properties": {
+          "name": {"$ref": "#/components/schemas/Tag"},
+          "count": {"type": "integer", "format": "int64", "description": "The number of messages carrying the tag"}
+        }
+      },
+      "Thread": {
+        "allOf": [
+          {"$ref": "#/components/schemas/Message"},
+          {
+            "type": "object",
+            "properties": {
+              "replies": {"type": "array", "items": {"$ref": "#/components/schemas/Thread"}}
+            }
+          }
+        ]
+      },
+      "MessageInput": {
+        "type": "object",
+        "required": ["title", "body"],
+        "properties": {
+          "title": {"type": "string", "minLength": 1, "maxLength": 100, "description": "Unique within the tenant. Control characters are not allowed."},
+          "body": {"type": "string", "minLength": 1, "maxLength": 200, "description": "Control characters other than line breaks and tabs are not allowed."},
+          "parent_id": {"type": "integer", "format": "int64", "description": "The message this one replies to, which must exist (code parent_not_found otherwise). Updates ignore it."},
+          "tags": {
+            "type": "array",
+            "maxItems": 10,
+            "items": {"$ref": "#/components/schemas/Tag"},
+            "description": "Duplicates are dropped (code tag_invalid for an invalid tag, too_many_tags for more than 10). On update, leaving them out keeps the tags of the message and an empty list removes them."
+          }
+        }
+      },
+      "ImportReport": {
+        "type": "object",
+        "properties": {
+          "created": {"type": "integer"},
+          "updated": {"type": "integer"},
+          "skipped": {"type": "integer"},
+          "failed": {"type": "integer"},
+          "errors": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "line": {"type": "integer"},
+                "message": {"type": "string"}
+              }
+            }
+          }
+        }
+      },
+      "MessageErr": {
+        "type": "object",
+        "properties": {
+          "message": {"type": "string", "description": "A human readable description of the error"},
+          "status": {"type": "integer", "description": "The http status code"},
+          "error": {
+            "type": "string",
+            "description": "The kind of error",
+            "enum": ["bad_request", "not_found", "invalid_request", "conflict", "request_too_large", "server_error", "service_unavailable"]
+          },
+          "code": {
+            "type": "string",
+            "description": "A stable, machine readable code, when there is one",
+            "example": "validation_failed"
+          },
+          "fields": {
+            "type": "array",
+            "description": "What is wrong with each invalid field of the request",
+            "items": {"$ref": "#/components/schemas/FieldError"}
+          },
+          "details": {
+            "type": "object",
+            "description": "Additional information about the error",
+            "additionalProperties": true
+          }
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "description": "An RFC 7807 problem document, sent when the Accept header prefers application/problem+json",
+        "properties": {
+          "type": {"type": "string", "description": "A URI identifying the kind of problem", "example": "about:blank"},
+          "title": {"type": "string", "description": "The text of the http status", "example": "Not Found"},
+          "status": {"type": "integer", "description": "The http status code"},
+          "detail": {"type": "string", "description": "A human readable description of the error"},
+          "instance": {"type": "string", "description": "The uri of the request", "example": "/messages/1"},
+          "error": {"type": "string", "description": "The kind of error"},
+          "code": {"type": "string", "description": "A stable, machine readable code, when there is one"},
+          "fields": {
+            "type": "array",
+            "items": {"$ref": "#/components/schemas/FieldError"}
+          },
+          "details": {"type": "object", "additionalProperties": true}
+        }
+      },
+      "FieldError": {
+        "type": "object",
+        "properties": {
+          "field": {"type": "string", "example": "title"},
+          "code": {
+            "type": "string",
+            "enum": ["title_required", "title_too_long", "title_taken", "body_required", "body_too_long", "tag_invalid", "too_many_tags", "tag_taken"]
+          },
+          "message": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
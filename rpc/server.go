@@ -0,0 +1,156 @@
+//go:generate protoc --go_out=plugins=grpc:. message.proto
+
+package rpc
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/services"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"net/http"
+)
+
+type messageServer struct {
+	service services.MessageService
+}
+
+//NewServer returns a grpc server with the MessageService registered, backed by the given service.
+//auth tells how the tenant of each call is resolved, see controllers.Tenant.
+func NewServer(service services.MessageService, auth auth_utils.TenantAuth) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(scopeUnary(auth)), grpc.StreamInterceptor(scopeStream(auth)))
+	RegisterMessageServiceServer(s, &messageServer{service: service})
+	return s
+}
+
+//StartServer serves the MessageService on the given address. It blocks, just like http.ListenAndServe does for the REST api.
+func StartServer(addr string, service services.MessageService, auth auth_utils.TenantAuth) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("This is the error starting the grpc server:", err)
+	}
+	if err := NewServer(service, auth).Serve(lis); err != nil {
+		log.Fatal("This is the error serving grpc:", err)
+	}
+}
+
+func (s *messageServer) GetMessage(ctx context.Context, req *GetMessageRequest) (*Message, error) {
+	msg, err := s.service.GetMessage(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(msg)
+}
+
+//ListMessages sends the messages as they are read from the database. No message at all is an empty stream, not an error
+func (s *messageServer) ListMessages(req *ListMessagesRequest, stream MessageService_ListMessagesServer) error {
+	//the error of a send, which the export only sees as the reason it stopped
+	var sendErr error
+	err := s.service.ExportMessages(stream.Context(), func(m domain.Message) error {
+		msg, err := toProto(&m)
+		if err == nil {
+			err = stream.Send(msg)
+		}
+		sendErr = err
+		return err
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+func (s *messageServer) CreateMessage(ctx context.Context, req *CreateMessageRequest) (*Message, error) {
+	message := &domain.Message{
+		Title: req.GetTitle(),
+		Body:  req.GetBody(),
+	}
+	msg, err := s.service.CreateMessage(ctx, message)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(msg)
+}
+
+func (s *messageServer) UpdateMessage(ctx context.Context, req *UpdateMessageRequest) (*Message, error) {
+	message := &domain.Message{
+		Id:    req.GetId(),
+		Title: req.GetTitle(),
+		Body:  req.GetBody(),
+	}
+	msg, err := s.service.UpdateMessage(ctx, message)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(msg)
+}
+
+func (s *messageServer) DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error) {
+	if err := s.service.DeleteMessage(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &DeleteMessageResponse{Status: "deleted"}, nil
+}
+
+func toProto(msg *domain.Message) (*Message, error) {
+	createdAt, err := ptypes.TimestampProto(msg.CreatedAt)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid created_at: %s", err.Error())
+	}
+	return &Message{
+		Id:        msg.Id,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+//toStatus maps the http status carried by a MessageErr to the closest grpc code
+func toStatus(err error_utils.MessageErr) error {
+	var code codes.Code
+	switch err.Status() {
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+		if err.Code() == error_utils.CodeQuotaExceeded {
+			code = codes.ResourceExhausted
+		}
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		code = codes.Unknown
+	}
+	st := status.New(code, err.Message())
+	if fields := err.Fields(); len(fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Code + ": " + f.Message,
+			})
+		}
+		if withDetails, detailsErr := st.WithDetails(badRequest); detailsErr == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
@@ -0,0 +1,292 @@
+package rpc
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+var tm = time.Now()
+
+//dialServer starts the grpc server on an in-memory listener and returns a client connected to it
+func dialServer(t *testing.T, sm *messagestest.Service) (MessageServiceClient, func()) {
+	return dialServerWithAuth(t, sm, auth_utils.TenantAuth{})
+}
+
+func dialServerWithAuth(t *testing.T, sm *messagestest.Service, auth auth_utils.TenantAuth) (MessageServiceClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := NewServer(sm, auth)
+	go s.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when dialing the grpc server", err)
+	}
+	return NewMessageServiceClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestGetMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "the title", Body: "the body", CreatedAt: tm}, nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.GetMessage(context.Background(), &GetMessageRequest{Id: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "the title", msg.Title)
+	assert.EqualValues(t, "the body", msg.Body)
+	assert.EqualValues(t, tm.Unix(), msg.CreatedAt.Seconds)
+}
+
+func TestGetMessage_Not_Found(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("message not found")
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.GetMessage(context.Background(), &GetMessageRequest{Id: 1})
+	assert.Nil(t, msg)
+	assert.EqualValues(t, codes.NotFound, status.Code(err))
+	assert.EqualValues(t, "message not found", status.Convert(err).Message())
+}
+
+func TestCreateMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		message.Id = 1
+		message.CreatedAt = tm
+		return message, nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.CreateMessage(context.Background(), &CreateMessageRequest{Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "the title", msg.Title)
+	assert.EqualValues(t, "the body", msg.Body)
+}
+
+func TestCreateMessage_Invalid_Request(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewUnprocessibleEntityError("Please enter a valid title")
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.CreateMessage(context.Background(), &CreateMessageRequest{Body: "the body"})
+	assert.Nil(t, msg)
+	assert.EqualValues(t, codes.InvalidArgument, status.Code(err))
+	assert.EqualValues(t, "Please enter a valid title", status.Convert(err).Message())
+}
+
+func TestUpdateMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return message, nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.UpdateMessage(context.Background(), &UpdateMessageRequest{Id: 1, Title: "update title", Body: "update body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+	assert.EqualValues(t, "update title", msg.Title)
+	assert.EqualValues(t, "update body", msg.Body)
+}
+
+func TestUpdateMessage_Error_Updating(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.UpdateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error when updating message")
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	msg, err := client.UpdateMessage(context.Background(), &UpdateMessageRequest{Id: 1, Title: "update title", Body: "update body"})
+	assert.Nil(t, msg)
+	assert.EqualValues(t, codes.Internal, status.Code(err))
+}
+
+func TestDeleteMessage_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.DeleteMessageFunc = func(ctx context.Context, msgId int64) error_utils.MessageErr {
+		return nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	resp, err := client.DeleteMessage(context.Background(), &DeleteMessageRequest{Id: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "deleted", resp.Status)
+}
+
+func TestListMessages_Success(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		for _, msg := range []domain.Message{
+			{Id: 1, Title: "first title", Body: "first body", CreatedAt: tm},
+			{Id: 2, Title: "second title", Body: "second body", CreatedAt: tm},
+		} {
+			if err := fn(msg); err != nil {
+				return error_utils.NewInternalServerError(err.Error())
+			}
+		}
+		return nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	stream, err := client.ListMessages(context.Background(), &ListMessagesRequest{})
+	assert.Nil(t, err)
+
+	var msgs []*Message
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		msgs = append(msgs, msg)
+	}
+	assert.EqualValues(t, 2, len(msgs))
+	assert.EqualValues(t, "first title", msgs[0].Title)
+	assert.EqualValues(t, "second title", msgs[1].Title)
+	sm.AssertNotCalled(t, "GetAllMessages")
+}
+
+func TestListMessages_Empty(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		return nil
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	stream, err := client.ListMessages(context.Background(), &ListMessagesRequest{})
+	assert.Nil(t, err)
+	//the stream ends with OK, which the client sees as io.EOF
+	_, err = stream.Recv()
+	assert.EqualValues(t, io.EOF, err)
+}
+
+func TestListMessages_Failure(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		return error_utils.NewInternalServerError("error when trying to get messages")
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	stream, err := client.ListMessages(context.Background(), &ListMessagesRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.EqualValues(t, codes.Internal, status.Code(err))
+}
+
+func TestTenant_From_Metadata(t *testing.T) {
+	t.Parallel()
+	tenants := make(chan string, 2)
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		tenants <- domain.TenantFrom(ctx)
+		return &domain.Message{Id: msgId, CreatedAt: tm}, nil
+	}
+	sm.ExportMessagesFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		tenants <- domain.TenantFrom(ctx)
+		return nil
+	}
+	client, closeFn := dialServerWithAuth(t, sm, auth_utils.TenantAuth{TrustHeader: true})
+	defer closeFn()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), tenantMetadata, "acme")
+	_, err := client.GetMessage(ctx, &GetMessageRequest{Id: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "acme", <-tenants)
+
+	stream, err := client.ListMessages(ctx, &ListMessagesRequest{})
+	assert.Nil(t, err)
+	stream.Recv()
+	assert.EqualValues(t, "acme", <-tenants)
+}
+
+func TestTenant_Untrusted_Metadata(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), tenantMetadata, "acme")
+	_, err := client.GetMessage(ctx, &GetMessageRequest{Id: 1})
+	assert.EqualValues(t, codes.PermissionDenied, status.Code(err))
+	sm.AssertNotCalled(t, "GetMessage")
+}
+
+func TestTenant_Requires_Token(t *testing.T) {
+	t.Parallel()
+	secret := []byte("the secret")
+	sm := &messagestest.Service{}
+	sm.GetMessageFunc = func(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId, Title: domain.TenantFrom(ctx), CreatedAt: tm}, nil
+	}
+	client, closeFn := dialServerWithAuth(t, sm, auth_utils.TenantAuth{Secret: secret})
+	defer closeFn()
+
+	_, err := client.GetMessage(context.Background(), &GetMessageRequest{Id: 1})
+	assert.EqualValues(t, codes.Unauthenticated, status.Code(err))
+	sm.AssertNotCalled(t, "GetMessage")
+
+	token, _ := auth_utils.NewToken(auth_utils.Claims{Tenant: "acme"}, secret)
+	msg, err := client.GetMessage(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token), &GetMessageRequest{Id: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "acme", msg.Title)
+
+	_, err = client.GetMessage(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token, tenantMetadata, "globex"), &GetMessageRequest{Id: 1})
+	assert.EqualValues(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestCreateMessage_Quota_Exceeded(t *testing.T) {
+	t.Parallel()
+	sm := &messagestest.Service{}
+	sm.CreateMessageFunc = func(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.WithCode(error_utils.NewForbiddenError("the message quota is reached"), error_utils.CodeQuotaExceeded)
+	}
+	client, closeFn := dialServer(t, sm)
+	defer closeFn()
+
+	_, err := client.CreateMessage(context.Background(), &CreateMessageRequest{Title: "the title", Body: "the body"})
+	assert.EqualValues(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+	"efficient-api/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+)
+
+//sessionMetadata is the grpc counterpart of the X-Session-Id header of the REST api
+const sessionMetadata = "x-session-id"
+
+//withSession tags the call with the client it comes from, so that a client reads its own writes
+func withSession(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(sessionMetadata); len(values) > 0 && values[0] != "" {
+			return domain.WithSession(ctx, values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		return domain.WithSession(ctx, addr)
+	}
+	return ctx
+}
+
+func sessionUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withSession(ctx), req)
+}
+
+//contextStream is a stream whose context was changed by an interceptor
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func sessionStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: stream, ctx: withSession(stream.Context())})
+}
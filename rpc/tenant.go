@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"context"
+	"efficient-api/domain"
+	"efficient-api/utils/auth_utils"
+	"efficient-api/utils/error_utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//tenantMetadata is the grpc counterpart of the X-Tenant-Id header of the REST api
+const tenantMetadata = "x-tenant-id"
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+//withTenant scopes the call to the messages of its tenant, read as the REST api reads it
+func withTenant(ctx context.Context, auth auth_utils.TenantAuth) (context.Context, error_utils.MessageErr) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tenant, err := auth_utils.ResolveTenant(firstMetadata(md, "authorization"), firstMetadata(md, tenantMetadata), auth)
+	if err != nil {
+		return nil, err
+	}
+	return domain.WithTenant(ctx, tenant), nil
+}
+
+func tenantUnary(auth auth_utils.TenantAuth) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withTenant(ctx, auth)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func tenantStream(auth auth_utils.TenantAuth) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withTenant(stream.Context(), auth)
+		if err != nil {
+			return toStatus(err)
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+//scopeUnary tags the call with its session, then scopes it to its tenant. The grpc version used here takes a single interceptor.
+func scopeUnary(auth auth_utils.TenantAuth) grpc.UnaryServerInterceptor {
+	tenant := tenantUnary(auth)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return sessionUnary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return tenant(ctx, req, info, handler)
+		})
+	}
+}
+
+//scopeStream is scopeUnary for streams
+func scopeStream(auth auth_utils.TenantAuth) grpc.StreamServerInterceptor {
+	tenant := tenantStream(auth)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return sessionStream(srv, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+			return tenant(srv, stream, info, handler)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package services_test
+
+import (
+	"efficient-api/conformance"
+	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/services"
+	"testing"
+)
+
+//messagestest cannot import services, so its mock is checked against the interface here
+var _ services.MessageService = (*messagestest.Service)(nil)
+
+func TestMessagesService_Conformance(t *testing.T) {
+	conformance.Service(t, func(t *testing.T) services.MessageService {
+		return services.NewMessagesService(domain.NewMemoryRepository())
+	})
+}
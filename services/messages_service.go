@@ -1,81 +1,409 @@
 package services
 
 import (
+	"context"
 	"efficient-api/domain"
 	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
+	"fmt"
+	"io"
+	"net/http"
 	"time"
 )
 
-var (
-	MessagesService messageServiceInterface = &messagesService{}
-)
+//MessageService is what the REST, GraphQL and gRPC apis do with messages. NewMessagesService returns
+//the one backed by a domain.MessageRepository. Besides the errors of the repository, which are passed on as they are:
+//   - CreateMessage, UpdateMessage and the messages of ImportMessages return a 422 with a field error per invalid field
+//   - UpdateMessage, DeleteMessage, ListReplies and GetThread return a 404 when the message does not exist
+//   - CreateMessage returns a 422 with the error_utils.CodeParentNotFound code on parent_id when the message replies to one that does not exist
+//   - DeleteMessage returns a 409 with the error_utils.CodeHasReplies code when the message has replies and the delete policy
+//     is domain.OnDeleteRestrict, see WithDeletePolicy
+//   - RenameTag and MergeTags return a 422 with the error_utils.CodeTagInvalid code on the new name when it is not a valid tag,
+//     and a 404 when no message carries the tags to rename or merge; RenameTag returns a 409 with the error_utils.CodeTagTaken
+//     code when a message carries the new name already
+//   - CreateMessage returns a 403 with the error_utils.CodeQuotaExceeded code when the tenant already holds as many
+//     messages as its quota allows; ImportMessages reports the lines it could not create because of it
+//
+//Every method only sees the messages of the tenant of the context, see domain.WithTenant.
+//   - ImportMessages returns a 400 when onDuplicate is not one of domain.OnDuplicateSkip, OnDuplicateOverwrite or OnDuplicateFail;
+//     the lines that cannot be saved are listed in the report and do not fail the import, but an input that cannot be read
+//     any further does: it is a 400, or a 413 when a line is longer than message_formats.MaxLineLength.
+//     The messages saved before it are kept, and the error comes with the report of the lines read until then.
+//
+//The conformance package checks an implementation against this contract.
+type MessageService interface {
+	//GetMessage returns the message with the given id, or a 404
+	GetMessage(context.Context, int64) (*domain.Message, error_utils.MessageErr)
+	//GetMessages returns the messages with the given ids, in id order, leaving out the ids matching no message
+	GetMessages(context.Context, []int64) ([]domain.Message, error_utils.MessageErr)
+	//CreateMessage validates the message, stamps its CreatedAt and saves it
+	CreateMessage(context.Context, *domain.Message) (*domain.Message, error_utils.MessageErr)
+	//UpdateMessage validates the message and saves its title and body over the message with the same Id, and its tags unless they are nil
+	UpdateMessage(context.Context, *domain.Message) (*domain.Message, error_utils.MessageErr)
+	//DeleteMessage removes the message, and deals with its replies as the delete policy says
+	DeleteMessage(context.Context, int64) error_utils.MessageErr
+	//GetAllMessages returns every message, or a 404 when there are none
+	GetAllMessages(context.Context) ([]domain.Message, error_utils.MessageErr)
+	//ListMessages returns one page of messages, see domain.ListOptions
+	ListMessages(context.Context, domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	//ListReplies returns one page of the direct replies of the message with the given id
+	ListReplies(context.Context, int64, domain.ListOptions) ([]domain.Message, error_utils.MessageErr)
+	//GetThread returns the message with the given id and its replies, nested down to the given depth below it.
+	//The depth is brought within 1 and domain.MaxThreadDepth.
+	GetThread(context.Context, int64, int) (*domain.Thread, error_utils.MessageErr)
+	//ListTags returns the tags carried by the messages, by name, with the number of messages carrying each
+	ListTags(context.Context) ([]domain.TagCount, error_utils.MessageErr)
+	//RenameTag renames a tag on every message carrying it. Both names are normalized with domain.NormalizeTag.
+	RenameTag(ctx context.Context, from, to string) error_utils.MessageErr
+	//MergeTags replaces the tags from with the tag into on every message carrying them. The names are normalized with domain.NormalizeTag.
+	MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr
+	//ExportMessages calls fn with every message, in id order
+	ExportMessages(context.Context, func(domain.Message) error) error_utils.MessageErr
+	//ImportMessages saves every message read from the reader, handling the titles already taken as onDuplicate says
+	ImportMessages(context.Context, message_formats.Reader, string) (*domain.ImportReport, error_utils.MessageErr)
+}
+
+type messagesService struct {
+	repo     domain.MessageRepository
+	rules    domain.Rules
+	quotas   domain.Quotas
+	onDelete string
+}
+
+//Option changes how the service made by NewMessagesService behaves
+type Option func(*messagesService)
+
+//WithRules replaces the domain.DefaultRules the messages are validated against.
+//Rules letting through values longer than the columns are ignored, see domain.Rules.Validate.
+func WithRules(rules domain.Rules) Option {
+	return func(m *messagesService) {
+		if rules.Validate() == nil {
+			m.rules = rules
+		}
+	}
+}
+
+//WithQuotas caps the number of messages each tenant can create. The count is read before each create,
+//so concurrent creates may go a few messages over the quota.
+func WithQuotas(quotas domain.Quotas) Option {
+	return func(m *messagesService) {
+		m.quotas = quotas
+	}
+}
 
-type messagesService struct{}
+//WithDeletePolicy tells DeleteMessage what to do with the replies of a message: refuse the delete with domain.OnDeleteRestrict,
+//the default, delete them too with domain.OnDeleteCascade or keep them as threads of their own with domain.OnDeleteDetach.
+//An unknown policy is taken as domain.OnDeleteRestrict.
+func WithDeletePolicy(policy string) Option {
+	return func(m *messagesService) {
+		if domain.ValidOnDelete(policy) {
+			m.onDelete = policy
+		}
+	}
+}
+
+//NewMessagesService returns the service that validates messages and saves them in repo
+func NewMessagesService(repo domain.MessageRepository, opts ...Option) MessageService {
+	m := &messagesService{repo: repo, rules: domain.DefaultRules(), onDelete: domain.OnDeleteRestrict}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+//checkQuota returns a 403 when the tenant of the context cannot create another message
+func (m *messagesService) checkQuota(ctx context.Context) error_utils.MessageErr {
+	limit := m.quotas.Limit(domain.TenantFrom(ctx))
+	if limit <= 0 {
+		return nil
+	}
+	count, err := m.repo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		quotaErr := error_utils.WithCode(error_utils.NewForbiddenError("the message quota is reached"), error_utils.CodeQuotaExceeded)
+		return error_utils.WithDetails(quotaErr, map[string]interface{}{"limit": limit})
+	}
+	return nil
+}
 
-type messageServiceInterface interface {
-	GetMessage(int64) (*domain.Message, error_utils.MessageErr)
-	CreateMessage(*domain.Message) (*domain.Message, error_utils.MessageErr)
-	UpdateMessage(*domain.Message) (*domain.Message, error_utils.MessageErr)
-	DeleteMessage(int64) error_utils.MessageErr
-	GetAllMessages() ([]domain.Message, error_utils.MessageErr)
+//checkParent returns a 422 on parent_id when the message replies to one that does not exist
+func (m *messagesService) checkParent(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if message.ParentId == 0 {
+		return nil
+	}
+	_, err := m.repo.Get(ctx, message.ParentId)
+	if err != nil && err.Status() == http.StatusNotFound {
+		return error_utils.NewValidationError(error_utils.FieldError{Field: "parent_id", Code: error_utils.CodeParentNotFound, Message: "The message replied to does not exist"})
+	}
+	return err
 }
 
-func (m *messagesService) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
-	message, err := domain.MessageRepo.Get(msgId)
+func (m *messagesService) GetMessage(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	message, err := m.repo.Get(ctx, msgId)
 	if err != nil {
 		return nil, err
 	}
 	return message, nil
 }
 
-func (m *messagesService) GetAllMessages() ([]domain.Message, error_utils.MessageErr) {
-	messages, err := domain.MessageRepo.GetAll()
+func (m *messagesService) GetMessages(ctx context.Context, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	messages, err := m.repo.GetMany(ctx, msgIds)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (m *messagesService) GetAllMessages(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
+	messages, err := m.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (m *messagesService) ListMessages(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	messages, err := m.repo.List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	return messages, nil
 }
 
-func (m *messagesService) CreateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
-	if err := message.Validate(); err != nil {
+func (m *messagesService) ListReplies(ctx context.Context, msgId int64, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+	if _, err := m.repo.Get(ctx, msgId); err != nil {
+		return nil, err
+	}
+	replies, err := m.repo.Replies(ctx, msgId, opts)
+	if err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (m *messagesService) GetThread(ctx context.Context, msgId int64, depth int) (*domain.Thread, error_utils.MessageErr) {
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > domain.MaxThreadDepth {
+		depth = domain.MaxThreadDepth
+	}
+	messages, err := m.repo.Thread(ctx, msgId, depth)
+	if err != nil {
+		return nil, err
+	}
+	thread := domain.NewThread(msgId, messages)
+	if thread == nil {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return thread, nil
+}
+
+func (m *messagesService) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	if err := message.Validate(m.rules); err != nil {
+		return nil, err
+	}
+	if err := m.checkParent(ctx, message); err != nil {
+		return nil, err
+	}
+	if err := m.checkQuota(ctx); err != nil {
 		return nil, err
 	}
 	message.CreatedAt = time.Now()
-	message, err := domain.MessageRepo.Create(message)
+	message, err := m.repo.Create(ctx, message)
 	if err != nil {
 		return nil, err
 	}
 	return message, nil
 }
 
-func (m *messagesService) UpdateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (m *messagesService) UpdateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 
-	if err := message.Validate(); err != nil {
+	if err := message.Validate(m.rules); err != nil {
 		return nil, err
 	}
-	current, err := domain.MessageRepo.Get(message.Id)
+	current, err := m.repo.Get(ctx, message.Id)
 	if err != nil {
 		return nil, err
 	}
 	current.Title = message.Title
 	current.Body = message.Body
+	if message.Tags != nil {
+		current.Tags = message.Tags
+	}
 
-	updateMsg, err := domain.MessageRepo.Update(current)
+	updateMsg, err := m.repo.Update(ctx, current)
 	if err != nil {
 		return nil, err
 	}
 	return updateMsg, nil
 }
 
-func (m *messagesService) DeleteMessage(msgId int64) error_utils.MessageErr {
-	msg, err := domain.MessageRepo.Get(msgId)
+//How many times DeleteMessage detaches the replies of a message when new ones keep coming before it is deleted
+const detachAttempts = 3
+
+//DeleteMessage only deletes the replies with the cascade policy. The other policies delete the message with
+//domain.MessageRepository.DeleteLeaf, so that a reply added meanwhile is never deleted with it.
+func (m *messagesService) DeleteMessage(ctx context.Context, msgId int64) error_utils.MessageErr {
+	msg, err := m.repo.Get(ctx, msgId)
 	if err != nil {
 		return err
 	}
-	deleteErr := domain.MessageRepo.Delete(msg.Id)
-	if deleteErr != nil {
-		return deleteErr
+	if m.onDelete == domain.OnDeleteCascade {
+		return m.repo.Delete(ctx, msg.Id)
 	}
-	return nil
+	err = m.repo.DeleteLeaf(ctx, msg.Id)
+	for attempt := 1; m.onDelete == domain.OnDeleteDetach && err != nil && err.Code() == error_utils.CodeHasReplies && attempt <= detachAttempts; attempt++ {
+		if err = m.repo.Detach(ctx, msg.Id); err != nil {
+			return err
+		}
+		err = m.repo.DeleteLeaf(ctx, msg.Id)
+	}
+	return err
+}
+
+func (m *messagesService) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	tags, err := m.repo.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
 }
 
+//invalidTag is the 422 of a tag given as the field that is not a valid one
+func invalidTag(field string, tag string) error_utils.MessageErr {
+	return error_utils.NewValidationError(error_utils.FieldError{Field: field, Code: error_utils.CodeTagInvalid, Message: fmt.Sprintf("The tag %q is not valid", tag)})
+}
+
+//RenameTag refuses an invalid new name. An invalid old name is a 404, as no message can carry it.
+func (m *messagesService) RenameTag(ctx context.Context, from, to string) error_utils.MessageErr {
+	from, to = domain.NormalizeTag(from), domain.NormalizeTag(to)
+	if !domain.ValidTag(to) {
+		return invalidTag("name", to)
+	}
+	if !domain.ValidTag(from) {
+		return error_utils.NewNotFoundError("no tag matching given name")
+	}
+	return m.repo.RenameTag(ctx, from, to)
+}
+
+func (m *messagesService) MergeTags(ctx context.Context, from []string, into string) error_utils.MessageErr {
+	from, into = domain.NormalizeTags(from), domain.NormalizeTag(into)
+	if len(from) == 0 {
+		return error_utils.NewValidationError(error_utils.FieldError{Field: "from", Code: error_utils.CodeTagInvalid, Message: "The tags to merge are missing"})
+	}
+	for _, tag := range from {
+		if !domain.ValidTag(tag) {
+			return invalidTag("from", tag)
+		}
+	}
+	if !domain.ValidTag(into) {
+		return invalidTag("into", into)
+	}
+	return m.repo.MergeTags(ctx, from, into)
+}
+
+func (m *messagesService) ExportMessages(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+	return m.repo.Stream(ctx, fn)
+}
+
+//ImportMessages validates and saves every message read from r, one at a time. A line that cannot be saved
+//is reported and does not stop the import, an input that cannot be read any further does, with the report so far. onDuplicate tells what to do with a title that is already taken.
+//Ids are not kept, a reply is linked to the message its parent_id names in the input, which must come before it.
+func (m *messagesService) ImportMessages(ctx context.Context, r message_formats.Reader, onDuplicate string) (*domain.ImportReport, error_utils.MessageErr) {
+	switch onDuplicate {
+	case domain.OnDuplicateSkip, domain.OnDuplicateOverwrite, domain.OnDuplicateFail:
+	default:
+		return nil, error_utils.NewBadRequestError("on_duplicate should be one of skip, overwrite or fail")
+	}
+	report := &domain.ImportReport{Errors: make([]domain.ImportError, 0)}
+	//the id each message of the input got in the repository
+	ids := make(map[int64]int64)
+	saved := func(inputId int64, id int64) {
+		if inputId != 0 {
+			ids[inputId] = id
+		}
+	}
+	fail := func(message string) {
+		report.Failed++
+		report.Errors = append(report.Errors, domain.ImportError{Line: r.Line(), Message: message})
+	}
+	for {
+		message, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*message_formats.RecordError); ok {
+				fail(err.Error())
+				continue
+			}
+			return report, readError(err)
+		}
+		if err := message.Validate(m.rules); err != nil {
+			fail(err.Message())
+			continue
+		}
+		current, getErr := m.repo.GetByTitle(ctx, message.Title)
+		if getErr != nil && getErr.Status() != http.StatusNotFound {
+			fail(getErr.Message())
+			continue
+		}
+		if current != nil {
+			switch onDuplicate {
+			case domain.OnDuplicateSkip:
+				saved(message.Id, current.Id)
+				report.Skipped++
+			case domain.OnDuplicateFail:
+				fail("title already taken")
+			case domain.OnDuplicateOverwrite:
+				current.Body = message.Body
+				if message.Tags != nil {
+					current.Tags = message.Tags
+				}
+				if _, err := m.repo.Update(ctx, current); err != nil {
+					fail(err.Message())
+					continue
+				}
+				saved(message.Id, current.Id)
+				report.Updated++
+			}
+			continue
+		}
+		if message.ParentId != 0 {
+			parentId, ok := ids[message.ParentId]
+			if !ok {
+				fail("the message replied to is not part of the import")
+				continue
+			}
+			message.ParentId = parentId
+		}
+		if err := m.checkQuota(ctx); err != nil {
+			fail(err.Message())
+			continue
+		}
+		inputId := message.Id
+		message.Id = 0
+		if message.CreatedAt.IsZero() {
+			message.CreatedAt = time.Now()
+		}
+		createdMsg, createErr := m.repo.Create(ctx, message)
+		if createErr != nil {
+			fail(createErr.Message())
+			continue
+		}
+		saved(inputId, createdMsg.Id)
+		report.Created++
+	}
+	return report, nil
+}
+
+//readError is the error of an import whose input cannot be read any further
+func readError(err error) error_utils.MessageErr {
+	if err == message_formats.ErrLineTooLong {
+		return error_utils.NewRequestTooLargeError(err.Error())
+	}
+	return error_utils.NewBadRequestError(fmt.Sprintf("cannot read the messages: %s", err.Error()))
+}
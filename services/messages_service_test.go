@@ -1,53 +1,31 @@
 package services
 
 import (
-	"database/sql"
+	"context"
 	"efficient-api/domain"
+	"efficient-api/messagestest"
+	"efficient-api/utils/error_formats"
 	"efficient-api/utils/error_utils"
+	"efficient-api/utils/message_formats"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
 
 var (
 	tm = time.Now()
-	getMessageDomain func(messageId int64) (*domain.Message, error_utils.MessageErr)
-	createMessageDomain func(msg *domain.Message) (*domain.Message, error_utils.MessageErr)
-	updateMessageDomain func(msg *domain.Message) (*domain.Message, error_utils.MessageErr)
-	deleteMessageDomain func(messageId int64) error_utils.MessageErr
-	getAllMessagesDomain func() ([]domain.Message, error_utils.MessageErr)
 )
 
-type getDBMock struct {}
-
-func (m *getDBMock) Get(messageId int64) (*domain.Message, error_utils.MessageErr){
-	return getMessageDomain(messageId)
-}
-func (m *getDBMock) Create(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
-	return createMessageDomain(msg)
-}
-func (m *getDBMock) Update(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
-	return updateMessageDomain(msg)
-}
-func (m *getDBMock) Delete(messageId int64) error_utils.MessageErr {
-	return deleteMessageDomain(messageId)
-}
-func (m *getDBMock) GetAll() ([]domain.Message, error_utils.MessageErr) {
-	return getAllMessagesDomain()
-}
-func (m *getDBMock) Initialize(string, string, string, string, string, string) *sql.DB  {
-	return nil
-}
-
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of "GetMessage" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestMessagesService_GetMessage_Success(t *testing.T) {
-	domain.MessageRepo = &getDBMock{} //this is where we swapped the functionality
-	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{} //this is where we swapped the functionality
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
 			Id:        1,
 			Title:     "the title",
@@ -55,7 +33,7 @@ func TestMessagesService_GetMessage_Success(t *testing.T) {
 			CreatedAt: tm,
 		}, nil
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := NewMessagesService(repo).GetMessage(context.Background(), 1)
 	fmt.Println("this is the message: ", msg)
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
@@ -67,32 +45,44 @@ func TestMessagesService_GetMessage_Success(t *testing.T) {
 
 //Test the not found functionality
 func TestMessagesService_GetMessage_NotFoundID(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	//MessagesService = &serviceMock{}
+	t.Parallel()
+	repo := &messagestest.Repository{}
 
-	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewNotFoundError("the id is not found")
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := NewMessagesService(repo).GetMessage(context.Background(), 1)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, http.StatusNotFound, err.Status())
 	assert.EqualValues(t, "the id is not found", err.Message())
 	assert.EqualValues(t, "not_found", err.Error())
 }
+func TestMessagesService_GetMessages(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetManyFunc = func(ctx context.Context, messageIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Title: "the title"}}, nil
+	}
+	msgs, err := NewMessagesService(repo).GetMessages(context.Background(), []int64{1, 2})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.Message{{Id: 1, Title: "the title"}}, msgs)
+	repo.AssertCalled(t, "GetMany", []int64{1, 2})
+}
+
 ///////////////////////////////////////////////////////////////
 // End of "GetMessage" test cases
 ///////////////////////////////////////////////////////////////
 
-
 ///////////////////////////////////////////////////////////////
 // Start of	"CreateMessage" test cases
 ///////////////////////////////////////////////////////////////
 
 //Here we call the domain method, so we must mock it
 func TestMessagesService_CreateMessage_Success(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	createMessageDomain  = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.CreateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
 			Id:        1,
 			Title:     "the title",
@@ -105,7 +95,7 @@ func TestMessagesService_CreateMessage_Success(t *testing.T) {
 		Body:      "the body",
 		CreatedAt: tm,
 	}
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := NewMessagesService(repo).CreateMessage(context.Background(), request)
 	fmt.Println("this is the message: ", msg)
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
@@ -118,21 +108,22 @@ func TestMessagesService_CreateMessage_Success(t *testing.T) {
 //This is a table test that check both the title and the body
 //Since this will never call the domain "Get" method, no need to mock that method here
 func TestMessagesService_CreateMessage_Invalid_Request(t *testing.T) {
+	t.Parallel()
 	tests := []struct {
-		request *domain.Message
+		request    *domain.Message
 		statusCode int
-		errMsg string
-		errErr string
+		errMsg     string
+		errErr     string
 	}{
 		{
 			request: &domain.Message{
-			  Title:     "",
-			  Body:      "the body",
-			  CreatedAt: tm,
-		    },
-		    statusCode: http.StatusUnprocessableEntity,
-		    errMsg: "Please enter a valid title",
-		    errErr: "invalid_request",
+				Title:     "",
+				Body:      "the body",
+				CreatedAt: tm,
+			},
+			statusCode: http.StatusUnprocessableEntity,
+			errMsg:     "Please enter a valid title",
+			errErr:     "invalid_request",
 		},
 		{
 			request: &domain.Message{
@@ -141,12 +132,12 @@ func TestMessagesService_CreateMessage_Invalid_Request(t *testing.T) {
 				CreatedAt: tm,
 			},
 			statusCode: http.StatusUnprocessableEntity,
-			errMsg: "Please enter a valid body",
-			errErr: "invalid_request",
+			errMsg:     "Please enter a valid body",
+			errErr:     "invalid_request",
 		},
 	}
 	for _, tt := range tests {
-		msg, err := MessagesService.CreateMessage(tt.request)
+		msg, err := NewMessagesService(&messagestest.Repository{}).CreateMessage(context.Background(), tt.request)
 		assert.Nil(t, msg)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, tt.errMsg, err.Message())
@@ -155,58 +146,145 @@ func TestMessagesService_CreateMessage_Invalid_Request(t *testing.T) {
 	}
 }
 
+//Every invalid field is reported at once, with a stable code
+func TestMessagesService_CreateMessage_Reports_All_Fields(t *testing.T) {
+	t.Parallel()
+	request := &domain.Message{
+		Title: "",
+		Body:  strings.Repeat("b", domain.BodyMaxLength+1),
+	}
+	msg, err := NewMessagesService(&messagestest.Repository{}).CreateMessage(context.Background(), request)
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, error_utils.CodeValidationFailed, err.Code())
+	assert.EqualValues(t, []error_utils.FieldError{
+		{Field: "title", Code: error_utils.CodeTitleRequired, Message: "Please enter a valid title"},
+		{Field: "body", Code: error_utils.CodeBodyTooLong, Message: "The body should be at most 200 characters"},
+	}, err.Fields())
+}
+
+func TestMessagesService_CreateMessage_Rules(t *testing.T) {
+	t.Parallel()
+	rules := domain.DefaultRules()
+	rules.TitleMaxLength = 5
+	msg, err := NewMessagesService(&messagestest.Repository{}, WithRules(rules)).CreateMessage(context.Background(), &domain.Message{Title: "too long", Body: "the body"})
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, []error_utils.FieldError{
+		{Field: "title", Code: error_utils.CodeTitleTooLong, Message: "The title should be at most 5 characters"},
+	}, err.Fields())
+
+	//rules allowing more than the columns hold are ignored
+	rules.TitleMaxLength = domain.TitleMaxLength + 1
+	_, err = NewMessagesService(&messagestest.Repository{}, WithRules(rules)).CreateMessage(context.Background(), &domain.Message{Title: strings.Repeat("t", domain.TitleMaxLength+1), Body: "the body"})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, error_utils.CodeTitleTooLong, err.Fields()[0].Code)
+}
+
 //We mock the "Get" method in the domain here. What could go wrong?,
 //Since the title of the message must be unique, an error must be thrown,
 //Of course you can also mock when the sql query is wrong, etc(these where covered in the domain integration__tests),
 //For now, we have 100% coverage on the "CreateMessage" method in the service
 func TestMessagesService_CreateMessage_Failure(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	createMessageDomain  = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
-		return nil, error_utils.NewInternalServerError("title already taken")
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.CreateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewConflictError("title already taken")
 	}
 	request := &domain.Message{
 		Title:     "the title",
 		Body:      "the body",
 		CreatedAt: tm,
 	}
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := NewMessagesService(repo).CreateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "title already taken", err.Message())
-	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
-	assert.EqualValues(t, "server_error", err.Error())
+	assert.EqualValues(t, http.StatusConflict, err.Status())
+	assert.EqualValues(t, "conflict", err.Error())
 }
 
-///////////////////////////////////////////////////////////////
-// End of "CreateMessage" test cases
-///////////////////////////////////////////////////////////////
+func TestMessagesService_CreateMessage_Quota(t *testing.T) {
+	t.Parallel()
+	counts := map[string]int64{"acme": 2, "globex": 2}
+	repo := &messagestest.Repository{}
+	repo.CountFunc = func(ctx context.Context) (int64, error_utils.MessageErr) {
+		return counts[domain.TenantFrom(ctx)], nil
+	}
+	repo.CreateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		msg.Id = 1
+		return msg, nil
+	}
+	service := NewMessagesService(repo, WithQuotas(domain.Quotas{Default: 2, Tenants: map[string]int{"globex": 3}}))
 
+	msg, err := service.CreateMessage(domain.WithTenant(context.Background(), "acme"), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+	assert.EqualValues(t, error_utils.CodeQuotaExceeded, err.Code())
+	assert.EqualValues(t, 2, err.Details()["limit"])
+	repo.AssertNotCalled(t, "Create")
 
+	//globex has a quota of its own
+	msg, err = service.CreateMessage(domain.WithTenant(context.Background(), "globex"), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, msg.Id)
+}
+
+func TestMessagesService_CreateMessage_Without_Quota_Does_Not_Count(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.CreateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	_, err := NewMessagesService(repo, WithQuotas(domain.Quotas{Tenants: map[string]int{"acme": 1}})).CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	repo.AssertNotCalled(t, "Count")
+}
+
+func TestMessagesService_CreateMessage_Quota_Count_Failure(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.CountFunc = func(ctx context.Context) (int64, error_utils.MessageErr) {
+		return 0, error_utils.NewServiceUnavailableError("the database is not available")
+	}
+	msg, err := NewMessagesService(repo, WithQuotas(domain.Quotas{Default: 10})).CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusServiceUnavailable, err.Status())
+	repo.AssertNotCalled(t, "Create")
+}
 
 ///////////////////////////////////////////////////////////////
-// Start of	"UpdateMessage" test cases
+// End of "CreateMessage" test cases
 ///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of	"UpdateMessage" test cases
+// /////////////////////////////////////////////////////////////
 func TestMessagesService_UpdateMessage_Success(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "former title",
-			Body:      "former body",
+			Id:    1,
+			Title: "former title",
+			Body:  "former body",
 		}, nil
 	}
-	updateMessageDomain  = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
+	repo.UpdateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "the title update",
-			Body:      "the body update",
+			Id:    1,
+			Title: "the title update",
+			Body:  "the body update",
 		}, nil
 	}
 	request := &domain.Message{
-		Title:     "the title update",
-		Body:      "the body update",
+		Title: "the title update",
+		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := NewMessagesService(repo).UpdateMessage(context.Background(), request)
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
 	assert.EqualValues(t, 1, msg.Id)
@@ -217,33 +295,34 @@ func TestMessagesService_UpdateMessage_Success(t *testing.T) {
 //This is a validation test, it wont call the domain methods, so, we dont need to mock them.
 //It is also a table
 func TestMessagesService_UpdateMessage_Empty_Title_Or_Body(t *testing.T) {
+	t.Parallel()
 	tests := []struct {
-		request *domain.Message
+		request    *domain.Message
 		statusCode int
-		errMsg string
-		errErr string
+		errMsg     string
+		errErr     string
 	}{
 		{
 			request: &domain.Message{
-				Title:     "",
-				Body:      "the body",
+				Title: "",
+				Body:  "the body",
 			},
 			statusCode: http.StatusUnprocessableEntity,
-			errMsg: "Please enter a valid title",
-			errErr: "invalid_request",
+			errMsg:     "Please enter a valid title",
+			errErr:     "invalid_request",
 		},
 		{
 			request: &domain.Message{
-				Title:     "the title",
-				Body:      "",
+				Title: "the title",
+				Body:  "",
 			},
 			statusCode: http.StatusUnprocessableEntity,
-			errMsg: "Please enter a valid body",
-			errErr: "invalid_request",
+			errMsg:     "Please enter a valid body",
+			errErr:     "invalid_request",
 		},
 	}
 	for _, tt := range tests {
-		msg, err := MessagesService.UpdateMessage(tt.request)
+		msg, err := NewMessagesService(&messagestest.Repository{}).UpdateMessage(context.Background(), tt.request)
 		assert.Nil(t, msg)
 		assert.NotNil(t, err)
 		assert.EqualValues(t, tt.statusCode, err.Status())
@@ -256,15 +335,16 @@ func TestMessagesService_UpdateMessage_Empty_Title_Or_Body(t *testing.T) {
 //We need to test for that.
 //Here we checked for 500 error, you can also check for others if you have time.
 func TestMessagesService_UpdateMessage_Failure_Getting_Former_Message(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error getting message")
 	}
 	request := &domain.Message{
-		Title:     "the title update",
-		Body:      "the body update",
+		Title: "the title update",
+		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := NewMessagesService(repo).UpdateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "error getting message", err.Message())
@@ -274,60 +354,64 @@ func TestMessagesService_UpdateMessage_Failure_Getting_Former_Message(t *testing
 
 //We can get the former message, but we might have issues updating it. Here also, we tested using 500, you can also assert other possible failure status
 func TestMessagesService_UpdateMessage_Failure_Updating_Message(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
+	t.Parallel()
+	repo := &messagestest.Repository{}
 
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "former title",
-			Body:      "former body",
+			Id:    1,
+			Title: "former title",
+			Body:  "former body",
 		}, nil
 	}
-	updateMessageDomain  = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr){
+	repo.UpdateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error updating message")
 	}
 	request := &domain.Message{
-		Title:     "the title update",
-		Body:      "the body update",
+		Title: "the title update",
+		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := NewMessagesService(repo).UpdateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "error updating message", err.Message())
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	assert.EqualValues(t, "server_error", err.Error())
 }
+
 ///////////////////////////////////////////////////////////////
 // End of"UpdateMessage" test cases
 ///////////////////////////////////////////////////////////////
 
-
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 // Start of"DeleteMessage" test cases
-///////////////////////////////////////////////////////////////
+// /////////////////////////////////////////////////////////////
 func TestMessagesService_DeleteMessage_Success(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "former title",
-			Body:      "former body",
+			Id:    1,
+			Title: "former title",
+			Body:  "former body",
 		}, nil
 	}
-	deleteMessageDomain = func(messageId int64) error_utils.MessageErr {
+	repo.DeleteLeafFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
 		return nil
 	}
-	err := MessagesService.DeleteMessage(1)
+	err := NewMessagesService(repo).DeleteMessage(context.Background(), 1)
 	assert.Nil(t, err)
+	repo.AssertNotCalled(t, "Delete")
 }
 
 //It can range from a 500 error to a 404 error, we didnt mock deleting the message because we will not get there
 func TestMessagesService_DeleteMessage_Error_Getting_Message(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("Something went wrong getting message")
 	}
-	err := MessagesService.DeleteMessage(1)
+	err := NewMessagesService(repo).DeleteMessage(context.Background(), 1)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "Something went wrong getting message", err.Message())
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
@@ -335,49 +419,149 @@ func TestMessagesService_DeleteMessage_Error_Getting_Message(t *testing.T) {
 }
 
 func TestMessagesService_DeleteMessage_Error_Deleting_Message(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getMessageDomain  = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return &domain.Message{
-			Id:        1,
-			Title:     "former title",
-			Body:      "former body",
+			Id:    1,
+			Title: "former title",
+			Body:  "former body",
 		}, nil
 	}
-	deleteMessageDomain = func(messageId int64) error_utils.MessageErr {
+	repo.DeleteLeafFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
 		return error_utils.NewInternalServerError("error deleting message")
 	}
-	err := MessagesService.DeleteMessage(1)
+	err := NewMessagesService(repo).DeleteMessage(context.Background(), 1)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "error deleting message", err.Message())
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	assert.EqualValues(t, "server_error", err.Error())
 }
-///////////////////////////////////////////////////////////////
-// End of "DeleteMessage" test cases
-///////////////////////////////////////////////////////////////
+func TestMessagesService_DeleteMessage_With_Replies(t *testing.T) {
+	t.Parallel()
+	//seed creates a message with a reply, which has a reply of its own
+	seed := func(service MessageService) (*domain.Message, *domain.Message, *domain.Message) {
+		root, _ := service.CreateMessage(context.Background(), &domain.Message{Title: "root title", Body: "root body"})
+		reply, _ := service.CreateMessage(context.Background(), &domain.Message{ParentId: root.Id, Title: "reply title", Body: "reply body"})
+		nested, _ := service.CreateMessage(context.Background(), &domain.Message{ParentId: reply.Id, Title: "nested title", Body: "nested body"})
+		return root, reply, nested
+	}
+
+	t.Run("Restrict", func(t *testing.T) {
+		for _, service := range []MessageService{
+			NewMessagesService(domain.NewMemoryRepository()),
+			NewMessagesService(domain.NewMemoryRepository(), WithDeletePolicy("unknown")),
+		} {
+			root, _, _ := seed(service)
+			err := service.DeleteMessage(context.Background(), root.Id)
+			assert.NotNil(t, err)
+			assert.EqualValues(t, http.StatusConflict, err.Status())
+			assert.EqualValues(t, error_utils.CodeHasReplies, err.Code())
+			assert.EqualValues(t, 1, err.Details()["replies"])
+			_, getErr := service.GetMessage(context.Background(), root.Id)
+			assert.Nil(t, getErr)
+		}
+	})
+	t.Run("Cascade", func(t *testing.T) {
+		service := NewMessagesService(domain.NewMemoryRepository(), WithDeletePolicy(domain.OnDeleteCascade))
+		root, reply, nested := seed(service)
+		assert.Nil(t, service.DeleteMessage(context.Background(), root.Id))
+		for _, id := range []int64{root.Id, reply.Id, nested.Id} {
+			_, err := service.GetMessage(context.Background(), id)
+			assert.NotNil(t, err)
+			assert.EqualValues(t, http.StatusNotFound, err.Status())
+		}
+	})
+	t.Run("Detach", func(t *testing.T) {
+		service := NewMessagesService(domain.NewMemoryRepository(), WithDeletePolicy(domain.OnDeleteDetach))
+		root, reply, nested := seed(service)
+		assert.Nil(t, service.DeleteMessage(context.Background(), root.Id))
+		got, err := service.GetMessage(context.Background(), reply.Id)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, got.ParentId)
+		got, err = service.GetMessage(context.Background(), nested.Id)
+		assert.Nil(t, err)
+		assert.EqualValues(t, reply.Id, got.ParentId)
+	})
+}
+
+//A reply added after the message was read is neither deleted with it nor left without a parent
+func TestMessagesService_DeleteMessage_Reply_Added_Meanwhile(t *testing.T) {
+	t.Parallel()
+	newRepo := func() *messagestest.Repository {
+		repo := &messagestest.Repository{}
+		repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
+			return &domain.Message{Id: 1, Title: "former title", Body: "former body"}, nil
+		}
+		repo.DeleteLeafFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
+			if len(repo.CallsTo("Detach")) == 0 {
+				return error_formats.HasReplies(1)
+			}
+			return nil
+		}
+		repo.DetachFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
+			return nil
+		}
+		return repo
+	}
 
+	repo := newRepo()
+	err := NewMessagesService(repo).DeleteMessage(context.Background(), 1)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, error_utils.CodeHasReplies, err.Code())
+	repo.AssertNotCalled(t, "Delete")
+	repo.AssertNotCalled(t, "Detach")
 
+	repo = newRepo()
+	assert.Nil(t, NewMessagesService(repo, WithDeletePolicy(domain.OnDeleteDetach)).DeleteMessage(context.Background(), 1))
+	repo.AssertNumberOfCalls(t, "Detach", 1)
+	repo.AssertNumberOfCalls(t, "DeleteLeaf", 2)
+	repo.AssertNotCalled(t, "Delete")
+}
+
+func TestMessagesService_DeleteMessage_Error_Detaching_Replies(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "former title", Body: "former body", ReplyCount: 2}, nil
+	}
+	repo.DeleteLeafFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
+		return error_formats.HasReplies(2)
+	}
+	repo.DetachFunc = func(ctx context.Context, messageId int64) error_utils.MessageErr {
+		return error_utils.NewInternalServerError("error detaching replies")
+	}
+	err := NewMessagesService(repo, WithDeletePolicy(domain.OnDeleteDetach)).DeleteMessage(context.Background(), 1)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	repo.AssertNotCalled(t, "Delete")
+}
 
 ///////////////////////////////////////////////////////////////
-// Start of "GetAllMessage" test cases
+// End of "DeleteMessage" test cases
 ///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "GetAllMessage" test cases
+// /////////////////////////////////////////////////////////////
 func TestMessagesService_GetAllMessages(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getAllMessagesDomain  = func() ([]domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetAllFunc = func(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
 		return []domain.Message{
 			{
-				Id:        1,
-				Title:     "first title",
-				Body:      "first body",
+				Id:    1,
+				Title: "first title",
+				Body:  "first body",
 			},
 			{
-				Id:        2,
-				Title:     "second title",
-				Body:      "second body",
+				Id:    2,
+				Title: "second title",
+				Body:  "second body",
 			},
 		}, nil
 	}
-	messages, err := MessagesService.GetAllMessages()
+	messages, err := NewMessagesService(repo).GetAllMessages(context.Background())
 	assert.Nil(t, err)
 	assert.NotNil(t, messages)
 	assert.EqualValues(t, messages[0].Id, 1)
@@ -389,17 +573,275 @@ func TestMessagesService_GetAllMessages(t *testing.T) {
 }
 
 func TestMessagesService_GetAllMessages_Error_Getting_Messages(t *testing.T) {
-	domain.MessageRepo = &getDBMock{}
-	getAllMessagesDomain  = func() ([]domain.Message, error_utils.MessageErr) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetAllFunc = func(ctx context.Context) ([]domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error getting messages")
 	}
-	messages, err := MessagesService.GetAllMessages()
+	messages, err := NewMessagesService(repo).GetAllMessages(context.Background())
 	assert.NotNil(t, err)
 	assert.Nil(t, messages)
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	assert.EqualValues(t, "error getting messages", err.Message())
 	assert.EqualValues(t, "server_error", err.Error())
 }
+
 ///////////////////////////////////////////////////////////////
 // End of "GetAllMessage" test cases
-///////////////////////////////////////////////////////////////
\ No newline at end of file
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "ListMessages" test cases
+// /////////////////////////////////////////////////////////////
+func TestMessagesService_ListMessages(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.ListFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		assert.EqualValues(t, domain.ListOptions{Limit: 2, AfterId: 1}, opts)
+		return []domain.Message{
+			{
+				Id:    2,
+				Title: "second title",
+				Body:  "second body",
+			},
+		}, nil
+	}
+	messages, err := NewMessagesService(repo).ListMessages(context.Background(), domain.ListOptions{Limit: 2, AfterId: 1})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(messages))
+	assert.EqualValues(t, messages[0].Id, 2)
+	assert.EqualValues(t, messages[0].Title, "second title")
+}
+
+func TestMessagesService_ListMessages_Error_Getting_Messages(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.ListFunc = func(ctx context.Context, opts domain.ListOptions) ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error getting messages")
+	}
+	messages, err := NewMessagesService(repo).ListMessages(context.Background(), domain.ListOptions{})
+	assert.NotNil(t, err)
+	assert.Nil(t, messages)
+	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	assert.EqualValues(t, "error getting messages", err.Message())
+}
+
+///////////////////////////////////////////////////////////////
+// End of "ListMessages" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "Tags" test cases
+// /////////////////////////////////////////////////////////////
+func TestMessagesService_UpdateMessage_Tags(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetFunc = func(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "former title", Body: "former body", Tags: []string{"go"}}, nil
+	}
+	repo.UpdateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	service := NewMessagesService(repo)
+
+	//tags left out are kept
+	msg, err := service.UpdateMessage(context.Background(), &domain.Message{Id: 1, Title: "the title", Body: "the body"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"go"}, msg.Tags)
+
+	msg, err = service.UpdateMessage(context.Background(), &domain.Message{Id: 1, Title: "the title", Body: "the body", Tags: []string{"News"}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"news"}, msg.Tags)
+}
+
+func TestMessagesService_ListTags(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.TagsFunc = func(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error getting tags")
+	}
+	tags, err := NewMessagesService(repo).ListTags(context.Background())
+	assert.Nil(t, tags)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, "error getting tags", err.Message())
+}
+
+func TestMessagesService_RenameTag(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.RenameTagFunc = func(ctx context.Context, from, to string) error_utils.MessageErr {
+		return nil
+	}
+	service := NewMessagesService(repo)
+
+	assert.Nil(t, service.RenameTag(context.Background(), " GoLang ", "Go"))
+	repo.AssertCalled(t, "RenameTag", "golang", "go")
+
+	err := service.RenameTag(context.Background(), "golang", "go lang")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	repo.AssertNumberOfCalls(t, "RenameTag", 1)
+}
+
+func TestMessagesService_MergeTags(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.MergeTagsFunc = func(ctx context.Context, from []string, into string) error_utils.MessageErr {
+		return error_utils.NewNotFoundError("no tag matching given names")
+	}
+	err := NewMessagesService(repo).MergeTags(context.Background(), []string{"GoLang", "go-lang", "golang"}, " Go")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+	repo.AssertCalled(t, "MergeTags", []string{"go-lang", "golang"}, "go")
+}
+
+///////////////////////////////////////////////////////////////
+// End of "Tags" test cases
+///////////////////////////////////////////////////////////////
+
+// /////////////////////////////////////////////////////////////
+// Start of "ImportMessages" test cases
+// /////////////////////////////////////////////////////////////
+func importReader(lines string) message_formats.Reader {
+	r, _ := message_formats.NewReader(strings.NewReader(lines), message_formats.JSONLines)
+	return r
+}
+
+func TestMessagesService_ImportMessages(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.GetByTitleFunc = func(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr) {
+		if title == "taken title" {
+			return &domain.Message{Id: 1, Title: "taken title", Body: "old body"}, nil
+		}
+		return nil, error_utils.NewNotFoundError("no record matching given title")
+	}
+	created := make([]domain.Message, 0)
+	repo.CreateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		created = append(created, *msg)
+		return msg, nil
+	}
+	updated := make([]domain.Message, 0)
+	repo.UpdateFunc = func(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		updated = append(updated, *msg)
+		return msg, nil
+	}
+	input := `{"id": 10, "title": "new title", "body": "new body", "created_at": "2020-01-02T03:04:05Z"}
+{"title": "taken title", "body": "new body"}
+{"title": "", "body": "new body"}
+not json
+`
+	tests := []struct {
+		onDuplicate string
+		want        domain.ImportReport
+		updates     int
+	}{
+		{onDuplicate: domain.OnDuplicateSkip, want: domain.ImportReport{Created: 1, Skipped: 1, Failed: 2}},
+		{onDuplicate: domain.OnDuplicateOverwrite, want: domain.ImportReport{Created: 1, Updated: 1, Failed: 2}, updates: 1},
+		{onDuplicate: domain.OnDuplicateFail, want: domain.ImportReport{Created: 1, Failed: 3}},
+	}
+	for _, tt := range tests {
+		created, updated = created[:0], updated[:0]
+		report, err := NewMessagesService(repo).ImportMessages(context.Background(), importReader(input), tt.onDuplicate)
+		assert.Nil(t, err)
+		assert.EqualValues(t, tt.want.Created, report.Created, tt.onDuplicate)
+		assert.EqualValues(t, tt.want.Updated, report.Updated, tt.onDuplicate)
+		assert.EqualValues(t, tt.want.Skipped, report.Skipped, tt.onDuplicate)
+		assert.EqualValues(t, tt.want.Failed, report.Failed, tt.onDuplicate)
+		assert.EqualValues(t, tt.want.Failed, len(report.Errors), tt.onDuplicate)
+		assert.EqualValues(t, tt.updates, len(updated), tt.onDuplicate)
+		if tt.updates > 0 {
+			assert.EqualValues(t, "new body", updated[0].Body)
+		}
+
+		//the id from the file is dropped, but the creation date is kept
+		assert.EqualValues(t, 0, created[0].Id)
+		assert.EqualValues(t, 2020, created[0].CreatedAt.Year())
+	}
+	report, _ := NewMessagesService(repo).ImportMessages(context.Background(), importReader(input), domain.OnDuplicateFail)
+	assert.EqualValues(t, domain.ImportError{Line: 2, Message: "title already taken"}, report.Errors[0])
+	assert.EqualValues(t, domain.ImportError{Line: 3, Message: "Please enter a valid title"}, report.Errors[1])
+	assert.EqualValues(t, 4, report.Errors[2].Line)
+}
+
+func TestMessagesService_ImportMessages_Quota(t *testing.T) {
+	t.Parallel()
+	repo := domain.NewMemoryRepository()
+	service := NewMessagesService(repo, WithQuotas(domain.Quotas{Default: 1}))
+	report, err := service.ImportMessages(context.Background(), importReader(`{"title": "first title", "body": "first body"}
+{"title": "second title", "body": "second body"}
+`), domain.OnDuplicateSkip)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, report.Created)
+	assert.EqualValues(t, 1, report.Failed)
+	assert.EqualValues(t, domain.ImportError{Line: 2, Message: "the message quota is reached"}, report.Errors[0])
+}
+
+//An input that cannot be read any further stops the import, instead of failing every later read
+func TestMessagesService_ImportMessages_Unreadable(t *testing.T) {
+	t.Parallel()
+	repo := domain.NewMemoryRepository()
+	long := `{"title": "` + strings.Repeat("a", message_formats.MaxLineLength) + `"}`
+	report, err := NewMessagesService(repo).ImportMessages(context.Background(), importReader(`{"title": "first title", "body": "first body"}
+`+long+"\n"), domain.OnDuplicateSkip)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, err.Status())
+	//the report says how far the import went
+	assert.EqualValues(t, 1, report.Created)
+	count, _ := repo.Count(context.Background())
+	assert.EqualValues(t, 1, count)
+
+	r, _ := message_formats.NewReader(strings.NewReader("title,body\n"), message_formats.CSV)
+	report, err = NewMessagesService(repo).ImportMessages(context.Background(), r, domain.OnDuplicateSkip)
+	assert.EqualValues(t, 0, report.Created)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+//Replies are linked to the messages their parent_id names in the input, whatever ids these get
+func TestMessagesService_ImportMessages_Replies(t *testing.T) {
+	t.Parallel()
+	repo := domain.NewMemoryRepository()
+	repo.Create(context.Background(), &domain.Message{Title: "already there", Body: "the body"})
+	report, err := NewMessagesService(repo).ImportMessages(context.Background(), importReader(`{"id": 7, "title": "first title", "body": "first body", "tags": ["go"]}
+{"id": 8, "parent_id": 7, "title": "a reply", "body": "the reply body"}
+{"id": 9, "parent_id": 3, "title": "an orphan", "body": "the orphan body"}
+`), domain.OnDuplicateFail)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, report.Created)
+	assert.EqualValues(t, domain.ImportError{Line: 3, Message: "the message replied to is not part of the import"}, report.Errors[0])
+
+	reply, _ := repo.GetByTitle(context.Background(), "a reply")
+	assert.EqualValues(t, 2, reply.ParentId)
+	first, _ := repo.Get(context.Background(), 2)
+	assert.EqualValues(t, []string{"go"}, first.Tags)
+}
+
+func TestMessagesService_ImportMessages_Invalid_Policy(t *testing.T) {
+	t.Parallel()
+	report, err := NewMessagesService(&messagestest.Repository{}).ImportMessages(context.Background(), importReader(""), "ignore")
+	assert.Nil(t, report)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestMessagesService_ExportMessages(t *testing.T) {
+	t.Parallel()
+	repo := &messagestest.Repository{}
+	repo.StreamFunc = func(ctx context.Context, fn func(domain.Message) error) error_utils.MessageErr {
+		fn(domain.Message{Id: 1, Title: "the title", Body: "the body"})
+		return nil
+	}
+	exported := make([]domain.Message, 0)
+	err := NewMessagesService(repo).ExportMessages(context.Background(), func(msg domain.Message) error {
+		exported = append(exported, msg)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(exported))
+	assert.EqualValues(t, "the title", exported[0].Title)
+}
+
+///////////////////////////////////////////////////////////////
+// End of "ImportMessages" test cases
+///////////////////////////////////////////////////////////////
@@ -0,0 +1,113 @@
+//Package auth_utils reads who a request comes from. Tokens are JWTs signed with HS256, the tenant is the tenant_id claim.
+package auth_utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"efficient-api/domain"
+	"efficient-api/utils/error_utils"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//the header of every token, HS256 is the only algorithm accepted
+var tokenHeader = encode([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+//Claims are what a token says about its bearer
+type Claims struct {
+	Tenant string `json:"tenant_id"`
+	//ExpiresAt is in seconds since the epoch, 0 for a token that does not expire
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sign(unsigned string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+//NewToken returns the token carrying claims, signed with secret
+func NewToken(claims Claims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + encode(payload)
+	return unsigned + "." + encode(sign(unsigned, secret)), nil
+}
+
+//ParseToken checks the signature and expiry of token and returns its claims, or a 401
+func ParseToken(token string, secret []byte) (*Claims, error_utils.MessageErr) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, error_utils.NewUnauthorizedError("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return nil, error_utils.NewUnauthorizedError("malformed token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return nil, error_utils.NewUnauthorizedError("invalid token signature")
+	}
+	var claims Claims
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return nil, error_utils.NewUnauthorizedError("malformed token")
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return nil, error_utils.NewUnauthorizedError("token expired")
+	}
+	return &claims, nil
+}
+
+//TenantAuth tells how the tenant of a request is resolved. The zero TenantAuth serves DefaultTenant alone.
+type TenantAuth struct {
+	//Secret signs the tokens naming the tenant. With it, every request carries one.
+	Secret []byte
+	//TrustHeader lets requests name their tenant in the tenant header without a token. It is ignored with a Secret.
+	TrustHeader bool
+}
+
+//ResolveTenant returns the tenant a request acts for, given its Authorization header and its tenant header.
+//With a secret, the tenant is the one of the bearer token, which is required; a tenant header naming
+//another tenant is a 403. Without a secret, the tenant header is only trusted when auth.TrustHeader says so,
+//and else naming another tenant than DefaultTenant is a 403. DefaultTenant is used without a header.
+func ResolveTenant(authorization string, header string, auth TenantAuth) (string, error_utils.MessageErr) {
+	if len(auth.Secret) == 0 {
+		if header == "" {
+			return domain.DefaultTenant, nil
+		}
+		if !domain.ValidTenant(header) {
+			return "", error_utils.NewBadRequestError("invalid tenant id")
+		}
+		if !auth.TrustHeader && header != domain.DefaultTenant {
+			return "", error_utils.NewForbiddenError("the tenant header is not trusted without a token")
+		}
+		return header, nil
+	}
+	const prefix = "Bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", error_utils.NewUnauthorizedError("missing bearer token")
+	}
+	claims, err := ParseToken(authorization[len(prefix):], auth.Secret)
+	if err != nil {
+		return "", err
+	}
+	if !domain.ValidTenant(claims.Tenant) {
+		return "", error_utils.NewUnauthorizedError("the token names no valid tenant")
+	}
+	if header != "" && header != claims.Tenant {
+		return "", error_utils.NewForbiddenError("the token is not valid for this tenant")
+	}
+	return claims.Tenant, nil
+}
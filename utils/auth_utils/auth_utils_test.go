@@ -0,0 +1,96 @@
+package auth_utils
+
+import (
+	"efficient-api/domain"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("the secret")
+
+func token(t *testing.T, claims Claims, secret []byte) string {
+	token, err := NewToken(claims, secret)
+	if err != nil {
+		t.Fatalf("NewToken() error = %v", err)
+	}
+	return token
+}
+
+func TestParseToken(t *testing.T) {
+	claims, err := ParseToken(token(t, Claims{Tenant: "acme"}, secret), secret)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "acme", claims.Tenant)
+
+	valid := token(t, Claims{Tenant: "acme"}, secret)
+	parts := strings.Split(valid, ".")
+	tests := []struct {
+		name    string
+		token   string
+		message string
+	}{
+		{"Wrong_Secret", token(t, Claims{Tenant: "acme"}, []byte("another secret")), "invalid token signature"},
+		{"Changed_Claims", parts[0] + "." + encode([]byte(`{"tenant_id":"globex"}`)) + "." + parts[2], "invalid token signature"},
+		{"Other_Algorithm", encode([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", "malformed token"},
+		{"Not_A_Token", "not a token", "malformed token"},
+		{"Expired", token(t, Claims{Tenant: "acme", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, secret), "token expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, secret)
+			assert.Nil(t, claims)
+			if assert.NotNil(t, err) {
+				assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+				assert.EqualValues(t, tt.message, err.Message())
+			}
+		})
+	}
+}
+
+func TestParseToken_Not_Expired_Yet(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	claims, err := ParseToken(token(t, Claims{Tenant: "acme", ExpiresAt: expiresAt.Unix()}, secret), secret)
+	assert.Nil(t, err)
+	assert.EqualValues(t, expiresAt.Unix(), claims.ExpiresAt)
+}
+
+func TestResolveTenant(t *testing.T) {
+	bearer := "Bearer " + token(t, Claims{Tenant: "acme"}, secret)
+	tests := []struct {
+		name          string
+		authorization string
+		header        string
+		auth          TenantAuth
+		tenant        string
+		status        int
+	}{
+		{name: "Trusted_Header", header: "acme", auth: TenantAuth{TrustHeader: true}, tenant: "acme"},
+		{name: "Untrusted_Header", header: "acme", status: http.StatusForbidden},
+		{name: "Untrusted_Default_Header", header: domain.DefaultTenant, tenant: domain.DefaultTenant},
+		{name: "No_Header", tenant: domain.DefaultTenant},
+		{name: "Invalid_Header", header: "acme/../globex", auth: TenantAuth{TrustHeader: true}, status: http.StatusBadRequest},
+		{name: "Token_Ignored_Without_Secret", authorization: bearer, header: "globex", auth: TenantAuth{TrustHeader: true}, tenant: "globex"},
+		{name: "Token_Required_Although_Header_Trusted", header: "acme", auth: TenantAuth{Secret: secret, TrustHeader: true}, status: http.StatusUnauthorized},
+		{name: "Token", authorization: bearer, auth: TenantAuth{Secret: secret}, tenant: "acme"},
+		{name: "Token_And_Same_Header", authorization: bearer, header: "acme", auth: TenantAuth{Secret: secret}, tenant: "acme"},
+		{name: "Token_And_Other_Header", authorization: bearer, header: "globex", auth: TenantAuth{Secret: secret}, status: http.StatusForbidden},
+		{name: "No_Token", header: "acme", auth: TenantAuth{Secret: secret}, status: http.StatusUnauthorized},
+		{name: "Not_Bearer", authorization: "Basic YWNtZTp4", auth: TenantAuth{Secret: secret}, status: http.StatusUnauthorized},
+		{name: "Token_Without_Tenant", authorization: "Bearer " + token(t, Claims{}, secret), auth: TenantAuth{Secret: secret}, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := ResolveTenant(tt.authorization, tt.header, tt.auth)
+			if tt.status != 0 {
+				if assert.NotNil(t, err) {
+					assert.EqualValues(t, tt.status, err.Status())
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.EqualValues(t, tt.tenant, tenant)
+		})
+	}
+}
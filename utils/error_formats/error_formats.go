@@ -1,23 +1,137 @@
 package error_formats
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"efficient-api/utils/error_utils"
+	"errors"
 	"fmt"
 	"github.com/go-sql-driver/mysql"
-	"strings"
+	"log"
+	"net"
+	"regexp"
 )
 
+//MySQL error numbers we can tell the client something useful about
+const (
+	errDuplicateEntry     = 1062
+	errDataTooLong        = 1406
+	errLockWaitTimeout    = 1205
+	errDeadlock           = 1213
+	errTooManyConnections = 1040
+	errServerShutdown     = 1053
+	errServerGone         = 2006
+	errServerLostMidQuery = 2013
+)
+
+var (
+	tooLongColumn = regexp.MustCompile(`column '(\w+)'`)
+	//MySQL 8 names the key after its table, "messages.title_UNIQUE", older versions do not
+	duplicateKey = regexp.MustCompile(`for key '(?:\w+\.)?(\w+)'`)
+)
+
+//ParseError translates an error from the database into an error that is safe to send to clients.
+//The driver's own text is only ever logged, never returned.
 func ParseError(err error) error_utils.MessageErr {
-	sqlErr, ok := err.(*mysql.MySQLError)
-	if !ok {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			return error_utils.NewNotFoundError("no record matching given id")
+	if errors.Is(err, sql.ErrNoRows) {
+		return error_utils.NewNotFoundError("no record matching given id")
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return error_utils.NewServiceUnavailableError("the request was cancelled or took too long")
+	}
+	var sqlErr *mysql.MySQLError
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.Number {
+		case errDuplicateEntry:
+			return duplicateEntry(sqlErr)
+		case errDataTooLong:
+			return dataTooLong(sqlErr)
+		case errDeadlock:
+			log.Printf("database error: %s", err.Error())
+			return error_utils.WithCode(error_utils.NewServiceUnavailableError("the request conflicted with another one, please try again"), error_utils.CodeDeadlock)
+		case errLockWaitTimeout:
+			log.Printf("database error: %s", err.Error())
+			return error_utils.WithCode(error_utils.NewServiceUnavailableError("the request timed out waiting for another one, please try again"), error_utils.CodeLockTimeout)
+		case errTooManyConnections, errServerShutdown, errServerGone, errServerLostMidQuery:
+			return unavailable(err)
+		}
+	} else if isConnectionError(err) {
+		return unavailable(err)
+	}
+	log.Printf("database error: %s", err.Error())
+	return error_utils.NewInternalServerError("error when processing request")
+}
+
+//TitleTaken is the error of a write that would give two messages the same title
+func TitleTaken() error_utils.MessageErr {
+	return error_utils.WithFields(
+		error_utils.WithCode(error_utils.NewConflictError("title already taken"), error_utils.CodeTitleTaken),
+		error_utils.FieldError{Field: "title", Code: error_utils.CodeTitleTaken, Message: "title already taken"},
+	)
+}
+
+//TagTaken is the error of a rename that would give two tags the same name
+func TagTaken() error_utils.MessageErr {
+	return error_utils.WithFields(
+		error_utils.WithCode(error_utils.NewConflictError("tag already taken"), error_utils.CodeTagTaken),
+		error_utils.FieldError{Field: "name", Code: error_utils.CodeTagTaken, Message: "tag already taken"},
+	)
+}
+
+//HasReplies is the error of a delete refused because the message has the given number of replies
+func HasReplies(replies int64) error_utils.MessageErr {
+	err := error_utils.WithCode(error_utils.NewConflictError("the message has replies"), error_utils.CodeHasReplies)
+	return error_utils.WithDetails(err, map[string]interface{}{"replies": replies})
+}
+
+//duplicateKeys are the unique keys a client can run into, with the error telling it which value is taken
+var duplicateKeys = map[string]func() error_utils.MessageErr{
+	"title_UNIQUE": TitleTaken,
+	"name_UNIQUE":  TagTaken,
+}
+
+//duplicateEntry tells which value is taken from the key the duplicate entry was refused by
+func duplicateEntry(sqlErr *mysql.MySQLError) error_utils.MessageErr {
+	if match := duplicateKey.FindStringSubmatch(sqlErr.Message); match != nil {
+		if taken, ok := duplicateKeys[match[1]]; ok {
+			return taken()
+		}
+	}
+	log.Printf("database error: %s", sqlErr.Error())
+	return error_utils.NewConflictError("the record already exists")
+}
+
+var tooLongCodes = map[string]string{
+	"title": error_utils.CodeTitleTooLong,
+	"body":  error_utils.CodeBodyTooLong,
+}
+
+//TooLong is the error of a write with a title or a body that does not fit in its column
+func TooLong(field string) error_utils.MessageErr {
+	return error_utils.NewValidationError(error_utils.FieldError{Field: field, Code: tooLongCodes[field], Message: fmt.Sprintf("The %s is too long", field)})
+}
+
+//dataTooLong names the offending field when it is one the client knows about
+func dataTooLong(sqlErr *mysql.MySQLError) error_utils.MessageErr {
+	if match := tooLongColumn.FindStringSubmatch(sqlErr.Message); match != nil {
+		if _, ok := tooLongCodes[match[1]]; ok {
+			return TooLong(match[1])
 		}
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error()))
 	}
-	switch sqlErr.Number {
-	case 1062:
-		return error_utils.NewInternalServerError("title already taken")
+	log.Printf("database error: %s", sqlErr.Error())
+	return error_utils.NewUnprocessibleEntityError("a value is too long")
+}
+
+func unavailable(err error) error_utils.MessageErr {
+	log.Printf("database unavailable: %s", err.Error())
+	return error_utils.WithCode(error_utils.NewServiceUnavailableError("the database is unavailable, please try again"), error_utils.CodeUnavailable)
+}
+
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
 	}
-	return error_utils.NewInternalServerError(fmt.Sprintf("error when processing request: %s", err.Error()))
-}
\ No newline at end of file
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
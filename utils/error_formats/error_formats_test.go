@@ -0,0 +1,58 @@
+package error_formats
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"efficient-api/utils/error_utils"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{name: "No Rows", err: sql.ErrNoRows, status: http.StatusNotFound},
+		{name: "Wrapped No Rows", err: fmt.Errorf("get message: %w", sql.ErrNoRows), status: http.StatusNotFound},
+		{name: "Duplicate Title", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-the title' for key 'title_UNIQUE'"}, status: http.StatusConflict, code: error_utils.CodeTitleTaken},
+		{name: "Duplicate Title MySQL 8", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-the title' for key 'messages.title_UNIQUE'"}, status: http.StatusConflict, code: error_utils.CodeTitleTaken},
+		{name: "Duplicate Tag", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-go' for key 'tags.name_UNIQUE'"}, status: http.StatusConflict, code: error_utils.CodeTagTaken},
+		{name: "Duplicate Unknown Key", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-2' for key 'PRIMARY'"}, status: http.StatusConflict},
+		{name: "Title Too Long", err: &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'title' at row 1"}, status: http.StatusUnprocessableEntity, code: error_utils.CodeValidationFailed},
+		{name: "Unknown Column Too Long", err: &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'secret' at row 1"}, status: http.StatusUnprocessableEntity},
+		{name: "Deadlock", err: &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, status: http.StatusServiceUnavailable, code: error_utils.CodeDeadlock, retryable: true},
+		{name: "Lock Wait Timeout", err: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, status: http.StatusServiceUnavailable, code: error_utils.CodeLockTimeout, retryable: true},
+		{name: "Too Many Connections", err: &mysql.MySQLError{Number: 1040, Message: "Too many connections"}, status: http.StatusServiceUnavailable, code: error_utils.CodeUnavailable, retryable: true},
+		{name: "Bad Connection", err: driver.ErrBadConn, status: http.StatusServiceUnavailable, code: error_utils.CodeUnavailable, retryable: true},
+		{name: "Invalid Connection", err: mysql.ErrInvalidConn, status: http.StatusServiceUnavailable, code: error_utils.CodeUnavailable, retryable: true},
+		{name: "Network", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, status: http.StatusServiceUnavailable, code: error_utils.CodeUnavailable, retryable: true},
+		{name: "Deadline Exceeded", err: context.DeadlineExceeded, status: http.StatusServiceUnavailable, retryable: true},
+		{name: "Other MySQL Error", err: &mysql.MySQLError{Number: 1146, Message: "Table 'efficient.messages' doesn't exist"}, status: http.StatusInternalServerError},
+		{name: "Other Error", err: errors.New("sql: secret driver details"), status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseError(tt.err)
+			assert.EqualValues(t, tt.status, got.Status())
+			assert.EqualValues(t, tt.code, got.Code())
+			assert.EqualValues(t, tt.retryable, error_utils.Retryable(got))
+			//the driver's own text never reaches the client
+			assert.NotContains(t, got.Message(), tt.err.Error())
+		})
+	}
+}
+
+func TestParseError_Data_Too_Long_Names_The_Field(t *testing.T) {
+	got := ParseError(&mysql.MySQLError{Number: 1406, Message: "Data too long for column 'body' at row 1"})
+	assert.EqualValues(t, []error_utils.FieldError{{Field: "body", Code: error_utils.CodeBodyTooLong, Message: "The body is too long"}}, got.Fields())
+}
@@ -3,18 +3,62 @@ package error_utils
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
+//Stable, machine readable error codes. Unlike messages, these never change once published.
+const (
+	CodeValidationFailed      = "validation_failed"
+	CodeTitleRequired         = "title_required"
+	CodeTitleTooLong          = "title_too_long"
+	CodeTitleTaken            = "title_taken"
+	CodeBodyRequired          = "body_required"
+	CodeBodyTooLong           = "body_too_long"
+	CodeTitleInvalidCharacter = "title_invalid_character"
+	CodeBodyInvalidCharacter  = "body_invalid_character"
+	CodeTitleBlocked          = "title_blocked"
+	CodeBodyBlocked           = "body_blocked"
+	CodeDeadlock              = "deadlock"
+	CodeLockTimeout           = "lock_timeout"
+	CodeUnavailable           = "database_unavailable"
+	CodeCircuitOpen           = "circuit_open"
+	CodeQuotaExceeded         = "quota_exceeded"
+	CodeParentNotFound        = "parent_not_found"
+	CodeHasReplies            = "has_replies"
+	CodeTagInvalid            = "tag_invalid"
+	CodeTooManyTags           = "too_many_tags"
+	CodeTagTaken              = "tag_taken"
+	CodeCanceled              = "canceled"
+	CodeDeadlineExceeded      = "deadline_exceeded"
+)
+
+//StatusClientClosedRequest is the status of a request given up by its sender before the answer came.
+//It is not sent by any server, but it keeps such requests apart from the failures of the server.
+const StatusClientClosedRequest = 499
+
 type MessageErr interface {
 	Message() string
 	Status() int
 	Error() string
+	Code() string
+	Fields() []FieldError
+	Details() map[string]interface{}
+}
+
+//FieldError describes what is wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 type messageErr struct {
-	ErrMessage string `json:"message"`
-	ErrStatus  int    `json:"status"`
-	ErrError   string `json:"error"`
+	ErrMessage string                 `json:"message"`
+	ErrStatus  int                    `json:"status"`
+	ErrError   string                 `json:"error"`
+	ErrCode    string                 `json:"code,omitempty"`
+	ErrFields  []FieldError           `json:"fields,omitempty"`
+	ErrDetails map[string]interface{} `json:"details,omitempty"`
 }
 
 func (e *messageErr) Error() string {
@@ -29,6 +73,18 @@ func (e *messageErr) Status() int {
 	return e.ErrStatus
 }
 
+func (e *messageErr) Code() string {
+	return e.ErrCode
+}
+
+func (e *messageErr) Fields() []FieldError {
+	return e.ErrFields
+}
+
+func (e *messageErr) Details() map[string]interface{} {
+	return e.ErrDetails
+}
+
 func NewNotFoundError(message string) MessageErr {
 	return &messageErr{
 		ErrMessage: message,
@@ -52,6 +108,21 @@ func NewUnprocessibleEntityError(message string) MessageErr {
 	}
 }
 
+//NewValidationError reports every problem found in a request at once. The message joins the messages of the fields.
+func NewValidationError(fields ...FieldError) MessageErr {
+	messages := make([]string, 0, len(fields))
+	for _, f := range fields {
+		messages = append(messages, f.Message)
+	}
+	return &messageErr{
+		ErrMessage: strings.Join(messages, "; "),
+		ErrStatus:  http.StatusUnprocessableEntity,
+		ErrError:   "invalid_request",
+		ErrCode:    CodeValidationFailed,
+		ErrFields:  fields,
+	}
+}
+
 func NewApiErrFromBytes(body []byte) (MessageErr, error) {
 	var result messageErr
 	if err := json.Unmarshal(body, &result); err != nil {
@@ -60,6 +131,74 @@ func NewApiErrFromBytes(body []byte) (MessageErr, error) {
 	return &result, nil
 }
 
+//NewUnauthorizedError reports a request that does not say who it comes from, or cannot prove it
+func NewUnauthorizedError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnauthorized,
+		ErrError:   "unauthorized",
+	}
+}
+
+//NewForbiddenError reports a request that is understood but that its sender is not allowed to make
+func NewForbiddenError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusForbidden,
+		ErrError:   "forbidden",
+	}
+}
+
+func NewConflictError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusConflict,
+		ErrError:   "conflict",
+	}
+}
+
+//NewRequestTooLargeError reports a request, or a part of it, bigger than the server accepts
+func NewRequestTooLargeError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusRequestEntityTooLarge,
+		ErrError:   "request_too_large",
+	}
+}
+
+//NewServiceUnavailableError reports a temporary failure, the same request may succeed later
+func NewServiceUnavailableError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusServiceUnavailable,
+		ErrError:   "service_unavailable",
+	}
+}
+
+//Retryable reports whether the request that failed with err may succeed if it is sent again
+func Retryable(err MessageErr) bool {
+	return err != nil && err.Status() == http.StatusServiceUnavailable
+}
+
+//NewCanceledError reports a request its sender gave up on, because it was canceled or ran out of time
+func NewCanceledError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  StatusClientClosedRequest,
+		ErrError:   "canceled",
+	}
+}
+
+//NewStatusError reports a failure of a status without a constructor of its own, eg. a 502 answered by a proxy.
+//Its kind is the status text, "bad_gateway" for a 502.
+func NewStatusError(status int, message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  status,
+		ErrError:   strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+	}
+}
+
 func NewInternalServerError(message string) MessageErr {
 	return &messageErr{
 		ErrMessage: message,
@@ -67,3 +206,42 @@ func NewInternalServerError(message string) MessageErr {
 		ErrError:   "server_error",
 	}
 }
+
+//WithCode returns a copy of err carrying the given code
+func WithCode(err MessageErr, code string) MessageErr {
+	e := clone(err)
+	e.ErrCode = code
+	return e
+}
+
+//WithFields returns a copy of err carrying the given field errors
+func WithFields(err MessageErr, fields ...FieldError) MessageErr {
+	e := clone(err)
+	e.ErrFields = fields
+	return e
+}
+
+//WithDetails returns a copy of err carrying the given details, merged with the ones it already had
+func WithDetails(err MessageErr, details map[string]interface{}) MessageErr {
+	e := clone(err)
+	merged := make(map[string]interface{}, len(e.ErrDetails)+len(details))
+	for k, v := range e.ErrDetails {
+		merged[k] = v
+	}
+	for k, v := range details {
+		merged[k] = v
+	}
+	e.ErrDetails = merged
+	return e
+}
+
+func clone(err MessageErr) *messageErr {
+	return &messageErr{
+		ErrMessage: err.Message(),
+		ErrStatus:  err.Status(),
+		ErrError:   err.Error(),
+		ErrCode:    err.Code(),
+		ErrFields:  err.Fields(),
+		ErrDetails: err.Details(),
+	}
+}
@@ -0,0 +1,60 @@
+package error_utils
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestNewValidationError(t *testing.T) {
+	err := NewValidationError(
+		FieldError{Field: "title", Code: CodeTitleRequired, Message: "Please enter a valid title"},
+		FieldError{Field: "body", Code: CodeBodyRequired, Message: "Please enter a valid body"},
+	)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, "invalid_request", err.Error())
+	assert.EqualValues(t, CodeValidationFailed, err.Code())
+	assert.EqualValues(t, "Please enter a valid title; Please enter a valid body", err.Message())
+	assert.EqualValues(t, 2, len(err.Fields()))
+}
+
+func TestNewStatusError(t *testing.T) {
+	err := NewStatusError(http.StatusBadGateway, "bad gateway")
+	assert.EqualValues(t, http.StatusBadGateway, err.Status())
+	assert.EqualValues(t, "bad_gateway", err.Error())
+	assert.EqualValues(t, "too_many_requests", NewStatusError(http.StatusTooManyRequests, "slow down").Error())
+}
+
+func TestWith_Helpers_Copy(t *testing.T) {
+	base := NewInternalServerError("title already taken")
+	err := WithDetails(WithFields(WithCode(base, CodeTitleTaken), FieldError{Field: "title", Code: CodeTitleTaken}), map[string]interface{}{"title": "the title"})
+	err = WithDetails(err, map[string]interface{}{"id": 1})
+
+	assert.EqualValues(t, CodeTitleTaken, err.Code())
+	assert.EqualValues(t, "title", err.Fields()[0].Field)
+	assert.EqualValues(t, map[string]interface{}{"title": "the title", "id": 1}, err.Details())
+	//the original error is left alone
+	assert.EqualValues(t, "", base.Code())
+	assert.Nil(t, base.Fields())
+}
+
+func TestNewApiErrFromBytes_Rich_Error(t *testing.T) {
+	sent := WithDetails(NewValidationError(FieldError{Field: "title", Code: CodeTitleTooLong, Message: "too long"}), map[string]interface{}{"max": 100})
+	body, _ := json.Marshal(sent)
+
+	got, err := NewApiErrFromBytes(body)
+	assert.Nil(t, err)
+	assert.EqualValues(t, sent.Message(), got.Message())
+	assert.EqualValues(t, sent.Status(), got.Status())
+	assert.EqualValues(t, sent.Error(), got.Error())
+	assert.EqualValues(t, sent.Code(), got.Code())
+	assert.EqualValues(t, sent.Fields(), got.Fields())
+	assert.EqualValues(t, 100, got.Details()["max"])
+}
+
+//Errors without code, fields or details keep the original shape
+func TestMessageErr_Json_Shape(t *testing.T) {
+	body, _ := json.Marshal(NewNotFoundError("message not found"))
+	assert.JSONEq(t, `{"message": "message not found", "status": 404, "error": "not_found"}`, string(body))
+}
@@ -0,0 +1,145 @@
+package error_utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//ProblemContentType is the media type of RFC 7807 problem documents
+const ProblemContentType = "application/problem+json"
+
+//The problem type of errors whose kind has no registered type, as RFC 7807 recommends
+const DefaultProblemType = "about:blank"
+
+//The kinds of error made by this package, as returned by Error()
+var kinds = []string{"bad_request", "unauthorized", "forbidden", "not_found", "invalid_request", "conflict", "request_too_large", "server_error", "service_unavailable"}
+
+//Problems decides how errors are rendered. The zero value sends problem documents to the clients asking for them only,
+//all with the DefaultProblemType.
+type Problems struct {
+	//ByDefault sends problem documents to the clients whose Accept header has no preference
+	ByDefault bool
+	//TypeBaseURL, when set, followed by the kind of an error, e.g. "not_found", is the type URI of its problems
+	TypeBaseURL string
+	//Types sets the type URI of the problems made from errors of the given kinds, over TypeBaseURL
+	Types map[string]string
+}
+
+//Problem is an RFC 7807 problem document. Extensions are written next to the standard members.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		doc[k] = v
+	}
+	doc["type"] = p.Type
+	doc["title"] = p.Title
+	doc["status"] = p.Status
+	if p.Detail != "" {
+		doc["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		doc["instance"] = p.Instance
+	}
+	return json.Marshal(doc)
+}
+
+//Type returns the type URI of problems made from errors of the given kind
+func (p Problems) Type(kind string) string {
+	if uri, ok := p.Types[kind]; ok {
+		return uri
+	}
+	if p.TypeBaseURL != "" {
+		for _, k := range kinds {
+			if k == kind {
+				return strings.TrimSuffix(p.TypeBaseURL, "/") + "/" + kind
+			}
+		}
+	}
+	return DefaultProblemType
+}
+
+//New turns err into a problem document about the given instance, usually the request uri.
+//The kind, code, fields and details of the error become extensions.
+func (p Problems) New(err MessageErr, instance string) *Problem {
+	ext := map[string]interface{}{
+		"error": err.Error(),
+	}
+	if code := err.Code(); code != "" {
+		ext["code"] = code
+	}
+	if fields := err.Fields(); len(fields) > 0 {
+		ext["fields"] = fields
+	}
+	if details := err.Details(); len(details) > 0 {
+		ext["details"] = details
+	}
+	return &Problem{
+		Type:       p.Type(err.Error()),
+		Title:      http.StatusText(err.Status()),
+		Status:     err.Status(),
+		Detail:     err.Message(),
+		Instance:   instance,
+		Extensions: ext,
+	}
+}
+
+//Wants reports whether a client sending the given Accept header should get a problem document.
+//It compares the quality of application/problem+json and application/json, and falls back to ByDefault on a tie.
+func (p Problems) Wants(accept string) bool {
+	problemQ := acceptQuality(accept, ProblemContentType)
+	jsonQ := acceptQuality(accept, "application/json")
+	if problemQ != jsonQ {
+		return problemQ > jsonQ
+	}
+	return p.ByDefault
+}
+
+//acceptQuality returns the quality given to exactly the media type, or 0 when it is not listed
+func acceptQuality(accept string, mediaType string) float64 {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mediaType) {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		return q
+	}
+	return 0
+}
+
+//Render writes err to w, as a problem document when the request asks for one and as the message error otherwise
+func (p Problems) Render(w http.ResponseWriter, r *http.Request, err MessageErr) {
+	var body interface{} = err
+	contentType := "application/json; charset=utf-8"
+	if p.Wants(r.Header.Get("Accept")) {
+		body = p.New(err, r.URL.RequestURI())
+		contentType = ProblemContentType
+	}
+	payload, marshalErr := json.Marshal(body)
+	if marshalErr != nil {
+		http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(err.Status())
+	w.Write(payload)
+}
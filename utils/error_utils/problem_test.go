@@ -0,0 +1,87 @@
+package error_utils
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsProblem(t *testing.T) {
+	tests := []struct {
+		accept   string
+		fallback bool
+		want     bool
+	}{
+		{"", false, false},
+		{"", true, true},
+		{"*/*", true, true},
+		{"application/problem+json", false, true},
+		{"application/json", true, false},
+		{"application/json;q=0.5, application/problem+json", false, true},
+		{"application/problem+json;q=0.2, application/json;q=0.9", true, false},
+		{"Application/Problem+JSON", false, true},
+	}
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.want, Problems{ByDefault: tt.fallback}.Wants(tt.accept), tt.accept)
+	}
+}
+
+func TestNewProblem(t *testing.T) {
+	problems := Problems{Types: map[string]string{"not_found": "https://example.com/problems/not-found"}}
+	p := problems.New(WithCode(NewNotFoundError("no record matching given id"), "message_not_found"), "/messages/1")
+	body, err := json.Marshal(p)
+	assert.Nil(t, err)
+
+	var doc map[string]interface{}
+	assert.Nil(t, json.Unmarshal(body, &doc))
+	assert.EqualValues(t, map[string]interface{}{
+		"type":     "https://example.com/problems/not-found",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"detail":   "no record matching given id",
+		"instance": "/messages/1",
+		"error":    "not_found",
+		"code":     "message_not_found",
+	}, doc)
+}
+
+func TestNewProblem_Unregistered_Kind(t *testing.T) {
+	p := Problems{}.New(NewValidationError(FieldError{Field: "title", Code: CodeTitleRequired, Message: "Please enter a valid title"}), "")
+	assert.EqualValues(t, DefaultProblemType, p.Type)
+	assert.EqualValues(t, "Unprocessable Entity", p.Title)
+	assert.NotNil(t, p.Extensions["fields"])
+}
+
+func TestProblems_Type_Base_URL(t *testing.T) {
+	problems := Problems{TypeBaseURL: "https://example.com/problems/", Types: map[string]string{"not_found": "https://example.com/not-found"}}
+	assert.EqualValues(t, "https://example.com/problems/server_error", problems.Type("server_error"))
+	assert.EqualValues(t, "https://example.com/not-found", problems.Type("not_found"))
+	assert.EqualValues(t, DefaultProblemType, problems.Type("unknown"))
+	assert.EqualValues(t, DefaultProblemType, Problems{}.Type("server_error"))
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		key         string
+	}{
+		{"application/json", "application/json; charset=utf-8", "message"},
+		{"application/problem+json", ProblemContentType, "detail"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/messages/abc?x=1", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+
+		Problems{}.Render(w, r, NewBadRequestError("message id should be a number"))
+
+		assert.EqualValues(t, http.StatusBadRequest, w.Code)
+		assert.EqualValues(t, tt.contentType, w.Header().Get("Content-Type"))
+		var doc map[string]interface{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.EqualValues(t, "message id should be a number", doc[tt.key])
+	}
+}
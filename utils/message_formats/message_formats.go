@@ -0,0 +1,264 @@
+package message_formats
+
+import (
+	"bufio"
+	"efficient-api/domain"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	JSONLines = "jsonl"
+	CSV       = "csv"
+)
+
+//The columns of a csv file. Files written before parent_id and tags were added, with only the first four columns, can still be read.
+var csvHeader = []string{"id", "title", "body", "created_at", "parent_id", "tags"}
+
+const csvLegacyColumns = 4
+
+//Writer writes messages one at a time, so a whole table never has to be held in memory
+type Writer interface {
+	Write(domain.Message) error
+	Flush() error
+}
+
+//Reader reads messages one at a time. Read returns io.EOF when there are no more messages,
+//and a *RecordError when a message cannot be decoded, after which reading can go on with the next one.
+//Any other error ends the input: every later call returns it again.
+//Line reports the line of the input the last message was read from.
+type Reader interface {
+	Read() (*domain.Message, error)
+	Line() int
+}
+
+//RecordError is an error of Read about one message only
+type RecordError struct {
+	Message string
+}
+
+func (e *RecordError) Error() string {
+	return e.Message
+}
+
+func recordErrorf(format string, args ...interface{}) error {
+	return &RecordError{Message: fmt.Sprintf(format, args...)}
+}
+
+//IsSupported reports whether format is one of JSONLines or CSV
+func IsSupported(format string) bool {
+	return format == JSONLines || format == CSV
+}
+
+//FromFileName guesses the format from a file extension, defaulting to JSONLines
+func FromFileName(name string) string {
+	if strings.HasSuffix(strings.ToLower(name), ".csv") {
+		return CSV
+	}
+	return JSONLines
+}
+
+//ContentType returns the media type to serve a format with
+func ContentType(format string) string {
+	if format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case JSONLines:
+		bw := bufio.NewWriter(w)
+		return &jsonLinesWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case JSONLines:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), MaxLineLength)
+		return &jsonLinesReader{scanner: scanner}, nil
+	case CSV:
+		//the header sets the number of fields of every record
+		return &csvReader{r: csv.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type jsonLinesWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (jw *jsonLinesWriter) Write(msg domain.Message) error {
+	return jw.enc.Encode(msg)
+}
+
+func (jw *jsonLinesWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvWriter) Write(msg domain.Message) error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	return cw.w.Write([]string{
+		strconv.FormatInt(msg.Id, 10),
+		msg.Title,
+		msg.Body,
+		msg.CreatedAt.Format(time.RFC3339),
+		formatParentId(msg.ParentId),
+		strings.Join(msg.Tags, " "),
+	})
+}
+
+//A message that replies to none has an empty parent_id
+func formatParentId(parentId int64) string {
+	if parentId == 0 {
+		return ""
+	}
+	return strconv.FormatInt(parentId, 10)
+}
+
+func (cw *csvWriter) Flush() error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+//MaxLineLength is the longest line a jsonl reader accepts
+const MaxLineLength = 1024 * 1024
+
+//ErrLineTooLong ends the input of a jsonl reader meeting a line longer than MaxLineLength
+var ErrLineTooLong = fmt.Errorf("a line is longer than %d bytes", MaxLineLength)
+
+type jsonLinesReader struct {
+	scanner *bufio.Scanner
+	line    int
+	err     error
+}
+
+func (jr *jsonLinesReader) Read() (*domain.Message, error) {
+	//a scanner that failed would return what it buffered as one more line
+	if jr.err != nil {
+		return nil, jr.err
+	}
+	for jr.scanner.Scan() {
+		jr.line++
+		text := strings.TrimSpace(jr.scanner.Text())
+		if text == "" {
+			continue
+		}
+		var msg domain.Message
+		if err := json.Unmarshal([]byte(text), &msg); err != nil {
+			return nil, recordErrorf("invalid json: %s", err.Error())
+		}
+		return &msg, nil
+	}
+	jr.err = jr.scanner.Err()
+	if jr.err == bufio.ErrTooLong {
+		jr.err = ErrLineTooLong
+	}
+	if jr.err == nil {
+		jr.err = io.EOF
+	}
+	return nil, jr.err
+}
+
+func (jr *jsonLinesReader) Line() int {
+	return jr.line
+}
+
+type csvReader struct {
+	r          *csv.Reader
+	headerRead bool
+	line       int
+	err        error
+}
+
+func (cr *csvReader) Read() (*domain.Message, error) {
+	if !cr.headerRead {
+		cr.headerRead = true
+		header, err := cr.r.Read()
+		if err == nil {
+			err = checkHeader(header)
+		}
+		cr.err = err
+	}
+	if cr.err != nil {
+		return nil, cr.err
+	}
+	record, err := cr.r.Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			cr.line = parseErr.Line
+			return nil, &RecordError{Message: parseErr.Error()}
+		}
+		return nil, err
+	}
+	cr.line, _ = cr.r.FieldPos(0)
+	var msg domain.Message
+	if record[0] != "" {
+		if msg.Id, err = strconv.ParseInt(record[0], 10, 64); err != nil {
+			return nil, recordErrorf("invalid id %q", record[0])
+		}
+	}
+	msg.Title = record[1]
+	msg.Body = record[2]
+	if record[3] != "" {
+		if msg.CreatedAt, err = time.Parse(time.RFC3339, record[3]); err != nil {
+			return nil, recordErrorf("invalid created_at %q", record[3])
+		}
+	}
+	if len(record) > csvLegacyColumns {
+		if record[4] != "" {
+			if msg.ParentId, err = strconv.ParseInt(record[4], 10, 64); err != nil {
+				return nil, recordErrorf("invalid parent_id %q", record[4])
+			}
+		}
+		if tags := strings.Fields(record[5]); len(tags) > 0 {
+			msg.Tags = tags
+		}
+	}
+	return &msg, nil
+}
+
+//checkHeader accepts the columns of csvHeader, or its first csvLegacyColumns
+func checkHeader(header []string) error {
+	if len(header) != len(csvHeader) && len(header) != csvLegacyColumns {
+		return fmt.Errorf("invalid csv header, expected %s", strings.Join(csvHeader, ","))
+	}
+	for i := range header {
+		if strings.TrimSpace(header[i]) != csvHeader[i] {
+			return fmt.Errorf("invalid csv header, expected %s", strings.Join(csvHeader, ","))
+		}
+	}
+	return nil
+}
+
+func (cr *csvReader) Line() int {
+	return cr.line
+}
@@ -0,0 +1,124 @@
+package message_formats
+
+import (
+	"bytes"
+	"efficient-api/domain"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var tm = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestRoundTrip(t *testing.T) {
+	msgs := []domain.Message{
+		{Id: 1, Title: "first title", Body: "first body", CreatedAt: tm},
+		{Id: 2, Title: "second, \"quoted\" title", Body: "second\nbody", CreatedAt: tm},
+		{Id: 3, ParentId: 1, Title: "third title", Body: "third body", Tags: []string{"go", "news"}, CreatedAt: tm},
+	}
+	for _, format := range []string{JSONLines, CSV} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, format)
+		assert.Nil(t, err)
+		for _, msg := range msgs {
+			assert.Nil(t, w.Write(msg))
+		}
+		assert.Nil(t, w.Flush())
+
+		r, err := NewReader(&buf, format)
+		assert.Nil(t, err)
+		got := make([]domain.Message, 0)
+		for {
+			msg, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err, format)
+			got = append(got, *msg)
+		}
+		assert.EqualValues(t, msgs, got, format)
+	}
+}
+
+func TestCSVReader_Invalid_Header(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("title,body\nthe title,the body\n"), CSV)
+	_, err := r.Read()
+	assert.NotNil(t, err)
+}
+
+func TestCSVReader_Line(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("id,title,body,created_at\n,first title,first body,\n,second title,second body,nonsense\n"), CSV)
+	msg, err := r.Read()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "first title", msg.Title)
+	assert.EqualValues(t, 2, r.Line())
+
+	_, err = r.Read()
+	assert.EqualValues(t, `invalid created_at "nonsense"`, err.Error())
+	assert.EqualValues(t, 3, r.Line())
+}
+
+//Files exported before parent_id and tags were added can still be imported
+func TestCSVReader_Legacy_Header(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("id,title,body,created_at\n1,the title,the body,\n"), CSV)
+	msg, err := r.Read()
+	assert.Nil(t, err)
+	assert.EqualValues(t, &domain.Message{Id: 1, Title: "the title", Body: "the body"}, msg)
+}
+
+//A record that cannot be decoded is a RecordError, an invalid header ends the input
+func TestCSVReader_Errors(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("id,title,body,created_at\nabc,the title,the body,\n1,\"the title,the body,\n"), CSV)
+	_, err := r.Read()
+	assert.IsType(t, &RecordError{}, err)
+	_, err = r.Read()
+	assert.IsType(t, &RecordError{}, err)
+
+	r, _ = NewReader(strings.NewReader("title,body\nthe title,the body\n"), CSV)
+	_, err = r.Read()
+	assert.NotNil(t, err)
+	_, again := r.Read()
+	assert.EqualValues(t, err, again)
+}
+
+//A line too long for the reader ends the input, every later call returns the same error
+func TestJSONLinesReader_Line_Too_Long(t *testing.T) {
+	long := `{"title": "` + strings.Repeat("a", MaxLineLength) + `"}`
+	r, _ := NewReader(strings.NewReader("{\"title\": \"the title\"}\n"+long+"\n{\"title\": \"after\"}\n"), JSONLines)
+	_, err := r.Read()
+	assert.Nil(t, err)
+	_, err = r.Read()
+	assert.EqualValues(t, ErrLineTooLong, err)
+	_, err = r.Read()
+	assert.EqualValues(t, ErrLineTooLong, err)
+}
+
+func TestJSONLinesReader_Skips_Blank_Lines(t *testing.T) {
+	r, _ := NewReader(strings.NewReader("{\"title\": \"the title\"}\n\n{\"title\": 12}\n"), JSONLines)
+	msg, err := r.Read()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "the title", msg.Title)
+
+	_, err = r.Read()
+	assert.IsType(t, &RecordError{}, err)
+	assert.EqualValues(t, 3, r.Line())
+}
+
+func TestEmpty_CSV_Has_Header(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, CSV)
+	assert.Nil(t, w.Flush())
+	assert.EqualValues(t, "id,title,body,created_at,parent_id,tags\n", buf.String())
+}
+
+func TestUnsupported_Format(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "xml")
+	assert.NotNil(t, err)
+	_, err = NewReader(strings.NewReader(""), "xml")
+	assert.NotNil(t, err)
+	assert.False(t, IsSupported("xml"))
+	assert.EqualValues(t, CSV, FromFileName("messages.CSV"))
+	assert.EqualValues(t, JSONLines, FromFileName("messages.jsonl"))
+}